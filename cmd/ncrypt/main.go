@@ -0,0 +1,746 @@
+/*
+Command line interface for the vault, built on the same services as the server.
+
+The vault is unlocked once with `ncrypt unlock`, which prints a session token. Later commands read the token from
+NCRYPT_SESSION (or --session) so shell scripts can fetch secrets without the master password:
+
+	export NCRYPT_SESSION=$(ncrypt unlock < master_password.txt)
+	ncrypt get github --user abc
+
+Badger allows a single process per database, so the CLI cannot be used while the server is running.
+*/
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"ncrypt/models"
+	"ncrypt/services"
+	"ncrypt/utils/database"
+	"ncrypt/utils/logger"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/joho/godotenv"
+)
+
+const USAGE = `Usage: ncrypt [--session TOKEN] [--output table|json] <command> [arguments]
+
+Commands:
+  unlock                                    Read master password from stdin and print a session token
+  lock                                      End the cached session
+  list                                      List login data
+  get <name> [--user USERNAME]              Print account password
+  add <name> --user USERNAME [--url URL] [--password PASSWORD|-]
+                                            Add login data or an account to existing login data, password is generated if not given
+  edit <name> --user USERNAME [--new-user USERNAME] [--url URL] [--password PASSWORD|-]
+                                            Update an account
+  rm <name> [--user USERNAME]               Delete login data or only the given account
+  note list|get <id>|add <title>|rm <id>    Manage notes, content of new notes is read from stdin
+  generate                                  Generate a password using the saved preference
+  export <file.ncrypt>                      Export vault
+  import <file.ncrypt> [--strategy STRATEGY]
+                                            Import an export, master password of the export is read from stdin
+
+Password "-" is read from stdin. get and note get read the master password from stdin for entries that require it.
+`
+
+type cli struct {
+	session_token           string
+	output                  string
+	stdin                   *bufio.Reader
+	stdout                  io.Writer
+	system_service          *services.SystemService
+	master_password_service *services.MasterPasswordService
+	login_service           *services.LoginDataService
+	note_service            *services.NoteService
+}
+
+func main() {
+	godotenv.Load(".env")
+	logger.Configure()
+
+	obj := &cli{stdin: bufio.NewReader(os.Stdin), stdout: os.Stdout}
+
+	global_flags := flag.NewFlagSet("ncrypt", flag.ContinueOnError)
+	global_flags.Usage = func() { fmt.Fprint(os.Stderr, USAGE) }
+	global_flags.StringVar(&obj.session_token, "session", os.Getenv("NCRYPT_SESSION"), "session token printed by unlock")
+	global_flags.StringVar(&obj.output, "output", "table", "output format, table or json")
+
+	if err := global_flags.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+
+	if global_flags.NArg() == 0 {
+		global_flags.Usage()
+		os.Exit(2)
+	}
+
+	if obj.output != "table" && obj.output != "json" {
+		fmt.Fprintln(os.Stderr, "ERROR: output has to be table or json")
+		os.Exit(2)
+	}
+
+	if os.Getenv("STORAGE_FOLDER") == "" {
+		fmt.Fprintln(os.Stderr, "ERROR: STORAGE_FOLDER is not set")
+		os.Exit(1)
+	}
+
+	err := obj.run(global_flags.Arg(0), global_flags.Args()[1:])
+
+	database.Close()
+	logger.Close()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func (obj *cli) run(command string, args []string) error {
+	database.QuietLogging()
+
+	//Complete any transaction interrupted by a crash
+	if err := database.Recover(); err != nil {
+		return err
+	}
+
+	obj.system_service = new(services.SystemService)
+	obj.system_service.InitHeadless()
+
+	obj.master_password_service = services.InitBadgerMasterPasswordService()
+	obj.master_password_service.Init()
+
+	obj.login_service = services.InitBadgerLoginService()
+	obj.login_service.Init()
+
+	obj.note_service = services.InitBadgerNoteService()
+	obj.note_service.Init()
+
+	switch command {
+	case "unlock":
+		return obj.unlock()
+	case "lock":
+		return obj.master_password_service.EndSession()
+	}
+
+	if obj.session_token == "" {
+		return errors.New("vault is locked, run unlock and set NCRYPT_SESSION")
+	}
+
+	if err := obj.master_password_service.ResumeSession(obj.session_token); err != nil {
+		return err
+	}
+
+	switch command {
+	case "list":
+		return obj.list()
+	case "get":
+		return obj.get(args)
+	case "add":
+		return obj.add(args)
+	case "edit":
+		return obj.edit(args)
+	case "rm":
+		return obj.remove(args)
+	case "note":
+		return obj.note(args)
+	case "generate":
+		return obj.generate()
+	case "export":
+		return obj.export(args)
+	case "import":
+		return obj.importFile(args)
+	}
+
+	return errors.New("unknown command " + command)
+}
+
+func (obj *cli) unlock() error {
+	master_password, err := obj.readSecret("Master password: ")
+
+	if err != nil {
+		return err
+	}
+
+	result, err := obj.system_service.ValidateMasterPassword(master_password)
+
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return errors.New("master password not set, set up the vault using the app first")
+		}
+		return err
+	}
+
+	if !result {
+		return errors.New("invalid password")
+	}
+
+	session_duration_in_minutes := obj.system_service.SESSION_DURATION_IN_MINUTES
+	system_data, err := obj.system_service.GetSystemData()
+
+	if err == nil && system_data.SessionDurationInMinutes > 0 {
+		session_duration_in_minutes = system_data.SessionDurationInMinutes
+	}
+
+	token, err := obj.master_password_service.ExportSession(time.Duration(session_duration_in_minutes) * time.Minute)
+
+	if err != nil {
+		return err
+	}
+
+	if obj.output == "json" {
+		return obj.printJSON(map[string]interface{}{"session": token, "session_duration_in_minutes": session_duration_in_minutes})
+	}
+
+	fmt.Fprintln(obj.stdout, token)
+	return nil
+}
+
+func (obj *cli) list() error {
+	login_data_list, err := obj.login_service.GetAllLoginData()
+
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(login_data_list, func(i, j int) bool {
+		return strings.ToLower(login_data_list[i].Name) < strings.ToLower(login_data_list[j].Name)
+	})
+
+	type listItem struct {
+		Name        string   `json:"name"`
+		URL         string   `json:"url"`
+		Usernames   []string `json:"usernames"`
+		IsFavourite bool     `json:"is_favourite"`
+	}
+
+	items := []listItem{}
+	rows := [][]string{}
+	for _, login_data := range login_data_list {
+		item := listItem{Name: login_data.Name, URL: login_data.URL, Usernames: []string{}, IsFavourite: login_data.Attributes.IsFavourite}
+		for _, account := range login_data.Accounts {
+			item.Usernames = append(item.Usernames, account.Username)
+		}
+
+		items = append(items, item)
+		rows = append(rows, []string{item.Name, item.URL, strings.Join(item.Usernames, ", "), fmt.Sprint(item.IsFavourite)})
+	}
+
+	if obj.output == "json" {
+		return obj.printJSON(items)
+	}
+
+	return obj.printTable([]string{"NAME", "URL", "USERNAMES", "FAVOURITE"}, rows)
+}
+
+func (obj *cli) get(args []string) error {
+	flags := flag.NewFlagSet("get", flag.ContinueOnError)
+	username := flags.String("user", "", "account username, optional if login data has a single account")
+
+	positional, err := parseArgs(flags, args, 1)
+
+	if err != nil {
+		return err
+	}
+
+	login_data, err := obj.login_service.GetLoginData(positional[0])
+
+	if err != nil {
+		return notFound(err, positional[0])
+	}
+
+	if *username == "" {
+		if len(login_data.Accounts) != 1 {
+			return errors.New(login_data.Name + " has " + fmt.Sprint(len(login_data.Accounts)) + " accounts, pass --user")
+		}
+		*username = login_data.Accounts[0].Username
+	}
+
+	if login_data.Attributes.RequireMasterPassword {
+		if err := obj.confirmMasterPassword(); err != nil {
+			return err
+		}
+	}
+
+	password, err := obj.login_service.GetDecryptedAccountPassword(login_data.Name, *username)
+
+	if err != nil {
+		return err
+	}
+
+	if obj.output == "json" {
+		return obj.printJSON(map[string]string{"name": login_data.Name, "url": login_data.URL, "username": *username, "password": password})
+	}
+
+	// Only the password is printed so the output can be used as is in scripts
+	fmt.Fprintln(obj.stdout, password)
+	return nil
+}
+
+func (obj *cli) add(args []string) error {
+	flags := flag.NewFlagSet("add", flag.ContinueOnError)
+	username := flags.String("user", "", "account username")
+	url := flags.String("url", "", "login url")
+	password := flags.String("password", "", "account password, - to read from stdin, generated if empty")
+
+	positional, err := parseArgs(flags, args, 1)
+
+	if err != nil {
+		return err
+	}
+
+	if *username == "" {
+		return errors.New("--user is required")
+	}
+
+	account_password, err := obj.accountPassword(*password, true)
+
+	if err != nil {
+		return err
+	}
+
+	account := map[string]interface{}{"username": *username, "password": account_password}
+
+	existing_data, err := obj.login_service.GetLoginData(positional[0])
+
+	if err != nil && err != badger.ErrKeyNotFound {
+		return err
+	}
+
+	if err == badger.ErrKeyNotFound {
+		err = obj.login_service.AddLoginData(map[string]interface{}{
+			"name":       positional[0],
+			"url":        *url,
+			"accounts":   []interface{}{account},
+			"attributes": map[string]interface{}{"is_favourite": false, "require_master_password": false},
+		})
+	} else {
+		patch := map[string]interface{}{"add_accounts": []interface{}{account}}
+		if *url != "" {
+			patch["url"] = *url
+		}
+		_, err = obj.login_service.PatchLoginData(existing_data.Name, patch, "")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return obj.printResult(map[string]string{"name": positional[0], "username": *username, "password": account_password}, "Added "+*username+" to "+positional[0])
+}
+
+func (obj *cli) edit(args []string) error {
+	flags := flag.NewFlagSet("edit", flag.ContinueOnError)
+	username := flags.String("user", "", "account username")
+	new_username := flags.String("new-user", "", "new account username")
+	url := flags.String("url", "", "new login url")
+	password := flags.String("password", "", "new account password, - to read from stdin")
+
+	positional, err := parseArgs(flags, args, 1)
+
+	if err != nil {
+		return err
+	}
+
+	patch := make(map[string]interface{})
+	if *url != "" {
+		patch["url"] = *url
+	}
+
+	if *new_username != "" || *password != "" {
+		if *username == "" {
+			return errors.New("--user is required to update an account")
+		}
+
+		account_patch := map[string]interface{}{"username": *username}
+		if *new_username != "" {
+			account_patch["new_username"] = *new_username
+		}
+		if *password != "" {
+			account_password, err := obj.accountPassword(*password, false)
+
+			if err != nil {
+				return err
+			}
+			account_patch["password"] = account_password
+		}
+
+		patch["update_accounts"] = []interface{}{account_patch}
+	}
+
+	if len(patch) == 0 {
+		return errors.New("nothing to update")
+	}
+
+	_, err = obj.login_service.PatchLoginData(positional[0], patch, "")
+
+	if err != nil {
+		return notFound(err, positional[0])
+	}
+
+	return obj.printResult(map[string]string{"name": positional[0]}, "Updated "+positional[0])
+}
+
+func (obj *cli) remove(args []string) error {
+	flags := flag.NewFlagSet("rm", flag.ContinueOnError)
+	username := flags.String("user", "", "only remove the given account")
+
+	positional, err := parseArgs(flags, args, 1)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = obj.login_service.GetLoginData(positional[0]); err != nil {
+		return notFound(err, positional[0])
+	}
+
+	if *username != "" {
+		_, err = obj.login_service.PatchLoginData(positional[0], map[string]interface{}{"remove_accounts": []interface{}{*username}}, "")
+	} else {
+		err = obj.login_service.DeleteLoginData(positional[0])
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return obj.printResult(map[string]string{"name": positional[0], "username": *username}, "Removed "+strings.TrimSpace(*username+" "+positional[0]))
+}
+
+func (obj *cli) note(args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected note list, get, add or rm")
+	}
+
+	switch args[0] {
+	case "list":
+		notes, err := obj.note_service.GetAllNotes()
+
+		if err != nil {
+			return err
+		}
+
+		type noteItem struct {
+			CreatedDateTime string `json:"created_date_time"`
+			Title           string `json:"title"`
+		}
+
+		items := []noteItem{}
+		rows := [][]string{}
+		for _, note := range notes {
+			items = append(items, noteItem{CreatedDateTime: note.CreatedDateTime, Title: note.Title})
+			rows = append(rows, []string{note.CreatedDateTime, note.Title})
+		}
+
+		if obj.output == "json" {
+			return obj.printJSON(items)
+		}
+
+		return obj.printTable([]string{"ID", "TITLE"}, rows)
+	case "get":
+		positional, err := parseArgs(flag.NewFlagSet("note get", flag.ContinueOnError), args[1:], 1)
+
+		if err != nil {
+			return err
+		}
+
+		note, err := obj.findNote(positional[0])
+
+		if err != nil {
+			return err
+		}
+
+		if note.Attributes.RequireMasterPassword {
+			if err := obj.confirmMasterPassword(); err != nil {
+				return err
+			}
+		}
+
+		content, err := obj.note_service.GetDecryptedContent(note.CreatedDateTime)
+
+		if err != nil {
+			return err
+		}
+
+		if obj.output == "json" {
+			return obj.printJSON(map[string]string{"created_date_time": note.CreatedDateTime, "title": note.Title, "content": content})
+		}
+
+		fmt.Fprintln(obj.stdout, content)
+		return nil
+	case "add":
+		positional, err := parseArgs(flag.NewFlagSet("note add", flag.ContinueOnError), args[1:], 1)
+
+		if err != nil {
+			return err
+		}
+
+		content, err := io.ReadAll(obj.stdin)
+
+		if err != nil {
+			return err
+		}
+
+		created_date_time := time.Now().Format(time.RFC3339Nano)
+		err = obj.note_service.AddNote(map[string]interface{}{
+			"created_date_time": created_date_time,
+			"title":             positional[0],
+			"content":           string(content),
+			"attributes":        map[string]interface{}{"is_favourite": false, "require_master_password": false},
+		})
+
+		if err != nil {
+			return err
+		}
+
+		return obj.printResult(map[string]string{"created_date_time": created_date_time, "title": positional[0]}, "Added note "+created_date_time)
+	case "rm":
+		positional, err := parseArgs(flag.NewFlagSet("note rm", flag.ContinueOnError), args[1:], 1)
+
+		if err != nil {
+			return err
+		}
+
+		note, err := obj.findNote(positional[0])
+
+		if err != nil {
+			return err
+		}
+
+		err = obj.note_service.DeleteNote(note.CreatedDateTime)
+
+		if err != nil {
+			return err
+		}
+
+		return obj.printResult(map[string]string{"created_date_time": note.CreatedDateTime}, "Removed note "+note.CreatedDateTime)
+	}
+
+	return errors.New("unknown note command " + args[0])
+}
+
+// Find note by id (created date time) or by title if there is a single note with that title
+func (obj *cli) findNote(id_or_title string) (models.Note, error) {
+	note, err := obj.note_service.GetNote(id_or_title)
+
+	if err == nil {
+		return *note, nil
+	}
+	if err != badger.ErrKeyNotFound {
+		return models.Note{}, err
+	}
+
+	notes, err := obj.note_service.GetAllNotes()
+
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	var matches []models.Note
+	for _, note := range notes {
+		if note.Title == id_or_title {
+			matches = append(matches, note)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return models.Note{}, errors.New("note " + id_or_title + " not found")
+	case 1:
+		return matches[0], nil
+	}
+
+	return models.Note{}, errors.New("multiple notes titled " + id_or_title + ", use the id from note list")
+}
+
+func (obj *cli) generate() error {
+	generated_password, err := obj.system_service.GeneratePassword()
+
+	if err != nil {
+		return err
+	}
+
+	if obj.output == "json" {
+		return obj.printJSON(generated_password)
+	}
+
+	fmt.Fprintln(obj.stdout, generated_password.Password)
+	return nil
+}
+
+func (obj *cli) export(args []string) error {
+	positional, err := parseArgs(flag.NewFlagSet("export", flag.ContinueOnError), args, 1)
+
+	if err != nil {
+		return err
+	}
+
+	err = obj.system_service.Export(positional[0], "")
+
+	if err != nil {
+		return err
+	}
+
+	return obj.printResult(map[string]string{"file": positional[0]}, "Exported to "+positional[0])
+}
+
+func (obj *cli) importFile(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	strategy := flags.String("strategy", models.IMPORT_KEEP_BOTH, "REPLACE, KEEP_EXISTING, OVERWRITE or KEEP_BOTH")
+
+	positional, err := parseArgs(flags, args, 1)
+
+	if err != nil {
+		return err
+	}
+
+	master_password, err := obj.readSecret("Master password of " + positional[0] + ": ")
+
+	if err != nil {
+		return err
+	}
+
+	summary, err := obj.system_service.ImportWithStrategy(positional[0], "", master_password, strings.ToUpper(*strategy))
+
+	if err != nil {
+		return err
+	}
+
+	if obj.output == "json" {
+		return obj.printJSON(summary)
+	}
+
+	rows := [][]string{}
+	for _, item := range []struct {
+		name    string
+		changes models.ImportChanges
+	}{{"LOGINS", summary.Logins}, {"NOTES", summary.Notes}} {
+		rows = append(rows, []string{item.name, fmt.Sprint(len(item.changes.Added)), fmt.Sprint(len(item.changes.Overwritten)),
+			fmt.Sprint(len(item.changes.Renamed)), fmt.Sprint(len(item.changes.Skipped)), fmt.Sprint(len(item.changes.Removed))})
+	}
+
+	return obj.printTable([]string{"", "ADDED", "OVERWRITTEN", "RENAMED", "SKIPPED", "REMOVED"}, rows)
+}
+
+// Ask for the master password before revealing an entry that requires it, the session alone is not enough. Shares lockout with sign in
+func (obj *cli) confirmMasterPassword() error {
+	master_password, err := obj.readSecret("Master password: ")
+
+	if err != nil {
+		return err
+	}
+
+	result, err := obj.system_service.ValidateMasterPassword(master_password)
+
+	if err != nil {
+		return err
+	}
+
+	if !result {
+		return errors.New("invalid password")
+	}
+
+	return nil
+}
+
+// Get password given as flag value. "-" reads it from stdin, an empty value generates one if allowed
+func (obj *cli) accountPassword(value string, generate bool) (string, error) {
+	switch {
+	case value == "-":
+		return obj.readSecret("Password: ")
+	case value == "" && generate:
+		generated_password, err := obj.system_service.GeneratePassword()
+		return generated_password.Password, err
+	}
+
+	return value, nil
+}
+
+// Read a single line from stdin. Prompt is written to stderr so it does not end up in captured output
+func (obj *cli) readSecret(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := obj.stdin.ReadString('\n')
+
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", errors.New("no input on stdin")
+	}
+
+	return line, nil
+}
+
+func (obj *cli) printResult(data interface{}, message string) error {
+	if obj.output == "json" {
+		return obj.printJSON(data)
+	}
+
+	fmt.Fprintln(obj.stdout, message)
+	return nil
+}
+
+/*
+Parse flags that may appear before or after positional arguments, e.g. `get github --user abc`.
+The flag package stops at the first positional argument, so parsing is resumed after each one.
+*/
+func parseArgs(flags *flag.FlagSet, args []string, positional_count int) ([]string, error) {
+	flags.SetOutput(io.Discard)
+	var positional []string
+
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	if len(positional) != positional_count {
+		return nil, fmt.Errorf("%s expects %d argument(s), got %d", flags.Name(), positional_count, len(positional))
+	}
+
+	return positional, nil
+}
+
+func notFound(err error, name string) error {
+	if err == badger.ErrKeyNotFound {
+		return errors.New(name + " not found")
+	}
+	return err
+}
+
+func (obj *cli) printJSON(data interface{}) error {
+	encoder := json.NewEncoder(obj.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+func (obj *cli) printTable(headers []string, rows [][]string) error {
+	writer := tabwriter.NewWriter(obj.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, strings.Join(headers, "\t"))
+
+	for _, row := range rows {
+		fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+
+	return writer.Flush()
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"ncrypt/services"
+	"ncrypt/utils/database"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Create a CLI reading the given stdin and writing to the returned buffer
+func newTestCLI(session_token string, stdin string) (*cli, *bytes.Buffer) {
+	stdout := new(bytes.Buffer)
+	return &cli{session_token: session_token, output: "table", stdin: bufio.NewReader(strings.NewReader(stdin)), stdout: stdout}, stdout
+}
+
+func runCLI(session_token string, stdin string, args ...string) (string, error) {
+	obj, stdout := newTestCLI(session_token, stdin)
+	err := obj.run(args[0], args[1:])
+	return stdout.String(), err
+}
+
+// Set master password and unlock the vault using the CLI, returns the session token
+func cli_test_init(t *testing.T) string {
+	master_password_service := new(services.MasterPasswordService)
+	master_password_service.Init()
+	master_password_service.SetMasterPassword("12345")
+
+	output, err := runCLI("", "12345\n", "unlock")
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return strings.TrimSpace(output)
+}
+
+func TestParseArgs(t *testing.T) {
+	test_cases := []struct {
+		args                []string
+		positional_count    int
+		expected_positional []string
+		expected_user       string
+		is_error            bool
+	}{
+		{[]string{"github"}, 1, []string{"github"}, "", false},
+		{[]string{"github", "--user", "abc"}, 1, []string{"github"}, "abc", false},
+		{[]string{"--user", "abc", "github"}, 1, []string{"github"}, "abc", false},
+		{[]string{"--user=abc", "github"}, 1, []string{"github"}, "abc", false},
+		{[]string{}, 1, nil, "", true},
+		{[]string{"github", "gitlab"}, 1, nil, "", true},
+		{[]string{"github", "--unknown"}, 1, nil, "", true},
+		{[]string{"github", "--user"}, 1, nil, "", true},
+	}
+
+	for _, test_case := range test_cases {
+		flags := flag.NewFlagSet("get", flag.ContinueOnError)
+		username := flags.String("user", "", "")
+
+		positional, err := parseArgs(flags, test_case.args, test_case.positional_count)
+
+		if test_case.is_error {
+			if err == nil {
+				t.Errorf("%v should result in an error", test_case.args)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v\n%s", test_case.args, err.Error())
+			continue
+		}
+
+		if !slices.Equal(positional, test_case.expected_positional) || *username != test_case.expected_user {
+			t.Errorf("%v\nExpected: %v %s\nActual: %v %s", test_case.args, test_case.expected_positional, test_case.expected_user, positional, *username)
+		}
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	master_password_service := new(services.MasterPasswordService)
+	master_password_service.Init()
+	master_password_service.SetMasterPassword("12345")
+
+	if _, err := runCLI("", "123\n", "unlock"); err == nil {
+		t.Error("Should fail for invalid password")
+	}
+
+	if _, err := runCLI("", "", "unlock"); err == nil {
+		t.Error("Should fail without password on stdin")
+	}
+
+	session_token, err := runCLI("", "12345\n", "unlock")
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	session_token = strings.TrimSpace(session_token)
+
+	if _, err := runCLI(session_token, "", "list"); err != nil {
+		t.Error(err.Error())
+	}
+
+	if _, err := runCLI("", "", "list"); err == nil {
+		t.Error("Should fail without session")
+	}
+
+	if _, err := runCLI("abc", "", "list"); err == nil {
+		t.Error("Should fail for invalid session token")
+	}
+
+	if _, err := runCLI(session_token, "", "lock"); err != nil {
+		t.Error(err.Error())
+	}
+
+	if _, err := runCLI(session_token, "", "list"); err == nil {
+		t.Error("Should fail after lock")
+	}
+
+	t.Cleanup(cli_test_cleanup)
+}
+
+func TestLoginCommands(t *testing.T) {
+	session_token := cli_test_init(t)
+
+	if _, err := runCLI(session_token, "", "add", "github", "--user", "abc", "--url", "https://github.com", "--password", "123"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, err := runCLI(session_token, "456\n", "add", "github", "--user", "pqr", "--password", "-"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, err := runCLI(session_token, "", "add", "github"); err == nil {
+		t.Error("Should fail without --user")
+	}
+
+	output, err := runCLI(session_token, "", "list")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if !strings.Contains(output, "github") || !strings.Contains(output, "abc, pqr") {
+		t.Errorf("Unexpected list\n%s", output)
+	}
+
+	if output, _ := runCLI(session_token, "", "get", "github", "--user", "pqr"); output != "456\n" {
+		t.Errorf("Expected: 456\nActual: %s", output)
+	}
+
+	if _, err := runCLI(session_token, "", "get", "github"); err == nil {
+		t.Error("Should fail without --user as github has 2 accounts")
+	}
+
+	if _, err := runCLI(session_token, "", "get", "gitlab"); err == nil || err.Error() != "gitlab not found" {
+		t.Errorf("Expected not found, got %v", err)
+	}
+
+	if _, err := runCLI(session_token, "", "edit", "github", "--user", "abc", "--password", "789"); err != nil {
+		t.Error(err.Error())
+	}
+
+	obj, stdout := newTestCLI(session_token, "")
+	obj.output = "json"
+
+	if err := obj.run("get", []string{"github", "--user", "abc"}); err != nil {
+		t.Error(err.Error())
+	}
+
+	var account map[string]string
+	json.Unmarshal(stdout.Bytes(), &account)
+
+	if account["username"] != "abc" || account["password"] != "789" || account["url"] != "https://github.com" {
+		t.Errorf("Unexpected account %v", account)
+	}
+
+	if _, err := runCLI(session_token, "", "rm", "github", "--user", "pqr"); err != nil {
+		t.Error(err.Error())
+	}
+
+	if output, _ := runCLI(session_token, "", "get", "github"); output != "789\n" {
+		t.Errorf("Expected: 789\nActual: %s", output)
+	}
+
+	if _, err := runCLI(session_token, "", "rm", "github"); err != nil {
+		t.Error(err.Error())
+	}
+
+	if output, _ := runCLI(session_token, "", "list"); strings.Contains(output, "github") {
+		t.Errorf("github should be removed\n%s", output)
+	}
+
+	if _, err := runCLI(session_token, "", "unknown"); err == nil {
+		t.Error("Should fail for unknown command")
+	}
+
+	t.Cleanup(cli_test_cleanup)
+}
+
+func TestNoteCommands(t *testing.T) {
+	session_token := cli_test_init(t)
+
+	if _, err := runCLI(session_token, "router password\n", "note", "add", "wifi"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if output, _ := runCLI(session_token, "", "note", "list"); !strings.Contains(output, "wifi") {
+		t.Errorf("Unexpected list\n%s", output)
+	}
+
+	if output, _ := runCLI(session_token, "", "note", "get", "wifi"); output != "router password\n\n" {
+		t.Errorf("Expected: router password\nActual: %s", output)
+	}
+
+	if _, err := runCLI(session_token, "", "note", "rm", "wifi"); err != nil {
+		t.Error(err.Error())
+	}
+
+	if _, err := runCLI(session_token, "", "note", "get", "wifi"); err == nil {
+		t.Error("Should fail as note is removed")
+	}
+
+	if _, err := runCLI(session_token, "", "note"); err == nil {
+		t.Error("Should fail without note command")
+	}
+
+	t.Cleanup(cli_test_cleanup)
+}
+
+// Session alone is not enough to reveal entries that require master password
+func TestRequireMasterPassword(t *testing.T) {
+	session_token := cli_test_init(t)
+
+	login_service := services.InitBadgerLoginService()
+	login_service.Init()
+
+	note_service := services.InitBadgerNoteService()
+	note_service.Init()
+
+	attributes := map[string]interface{}{"is_favourite": false, "require_master_password": true}
+
+	err := login_service.AddLoginData(map[string]interface{}{"name": "github", "url": "https://github.com", "attributes": attributes,
+		"accounts": []interface{}{map[string]interface{}{"username": "abc", "password": "123"}}})
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = note_service.AddNote(map[string]interface{}{"created_date_time": "1", "title": "wifi", "content": "router password", "attributes": attributes})
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, args := range [][]string{{"get", "github"}, {"note", "get", "wifi"}} {
+		for _, stdin := range []string{"", "456\n"} {
+			if output, err := runCLI(session_token, stdin, args...); err == nil || output != "" {
+				t.Errorf("%v with stdin %q should fail, got %q", args, stdin, output)
+			}
+		}
+	}
+
+	if output, _ := runCLI(session_token, "12345\n", "get", "github"); output != "123\n" {
+		t.Errorf("Expected: 123\nActual: %s", output)
+	}
+
+	if output, _ := runCLI(session_token, "12345\n", "note", "get", "wifi"); output != "router password\n" {
+		t.Errorf("Expected: router password\nActual: %s", output)
+	}
+
+	t.Cleanup(cli_test_cleanup)
+}
+
+func cli_test_cleanup() {
+	database.Close()
+	os.RemoveAll(os.Getenv("STORAGE_FOLDER"))
+}
@@ -5,16 +5,21 @@ import (
 	"encoding/json"
 	"ncrypt/models"
 	"ncrypt/services"
+	"ncrypt/utils/database"
+	"ncrypt/utils/jwt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func login_controller_test_cleanup() {
+	database.Close()
 	os.RemoveAll(os.Getenv("STORAGE_FOLDER"))
 }
 
@@ -611,6 +616,230 @@ func TestUpdateLoginData_With_Conflicting_Names(t *testing.T) {
 	t.Cleanup(login_controller_test_cleanup)
 }
 
+func TestPatchLoginData_Concurrent_Edits(t *testing.T) {
+	master_password_service := new(services.MasterPasswordService)
+	master_password_service.Init()
+	master_password_service.SetMasterPassword("12345")
+
+	login_service := new(services.LoginDataService)
+	login_service.Init()
+
+	login_controller := new(LoginDataController)
+	login_controller.Init()
+
+	login_data := make(map[string]interface{})
+	login_data["name"] = "github"
+	login_data["url"] = "https://github.com"
+	login_data["accounts"] = []interface{}{map[string]interface{}{"username": "abc", "password": "123"}}
+	login_data["attributes"] = map[string]interface{}{"is_favourite": false, "require_master_password": false}
+
+	err := login_service.AddLoginData(login_data)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	server := gin.Default()
+	server.GET("/login", login_controller.GetLoginData)
+	server.PATCH("/login/:name", login_controller.PatchLoginData)
+
+	//Both windows load the same login
+	test := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/login?name=github", nil)
+	server.ServeHTTP(test, req)
+
+	etag := test.Header().Get("ETag")
+
+	if etag == "" {
+		t.Error("ETag should be returned")
+	}
+
+	patches := []map[string]interface{}{
+		{"attributes": map[string]interface{}{"is_favourite": true}},
+		{"add_accounts": []interface{}{map[string]interface{}{"username": "pqr", "password": "456"}}},
+	}
+	expected_codes := []int{http.StatusOK, http.StatusConflict}
+
+	for index, patch := range patches {
+		patch_bytes, err := json.Marshal(patch)
+
+		if err != nil {
+			t.Error(err.Error())
+		}
+
+		test = httptest.NewRecorder()
+		req, _ = http.NewRequest("PATCH", "/login/github", bytes.NewReader(patch_bytes))
+		req.Header.Set("If-Match", etag)
+		server.ServeHTTP(test, req)
+
+		if test.Code != expected_codes[index] {
+			t.Errorf("Expected: %d\nActual: %d", expected_codes[index], test.Code)
+		}
+	}
+
+	fetched_login_data, err := login_service.GetLoginData("github")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if !fetched_login_data.Attributes.IsFavourite || len(fetched_login_data.Accounts) != 1 {
+		t.Errorf("Unexpected data: %v", fetched_login_data)
+	}
+
+	t.Cleanup(login_controller_test_cleanup)
+}
+
+func TestGetTOTPCode(t *testing.T) {
+	master_password_service := new(services.MasterPasswordService)
+	master_password_service.Init()
+	master_password_service.SetMasterPassword("12345")
+
+	login_service := new(services.LoginDataService)
+	login_service.Init()
+
+	login_controller := new(LoginDataController)
+	login_controller.Init()
+
+	login_data := make(map[string]interface{})
+	login_data["name"] = "github"
+	login_data["url"] = "https://github.com"
+	login_data["accounts"] = []interface{}{map[string]interface{}{"username": "abc", "password": "123", "totp_secret": map[string]interface{}{"secret": "otpauth://totp/GitHub:abc?secret=JBSWY3DPEHPK3PXP"}}}
+	login_data["attributes"] = map[string]interface{}{"is_favourite": true, "require_master_password": true}
+
+	err := login_service.AddLoginData(login_data)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	system_controller := new(SystemController)
+	system_controller.Init()
+
+	server := gin.Default()
+	login_controller.RegisterRoutes(server.Group(""))
+	system_controller.RegisterRoutes(server.Group(""))
+
+	token, _ := jwt.GenerateToken(5)
+
+	//Login requires master password, which is no longer accepted in a header
+	for description, header := range map[string]string{"no elevated token": "", "master password header": "12345"} {
+		test := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/login/github/totp?username=abc", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if header != "" {
+			req.Header.Set("Master-Password", header)
+		}
+		server.ServeHTTP(test, req)
+
+		if test.Code != http.StatusForbidden {
+			t.Errorf("%s\nExpected: %d\nActual: %d", description, http.StatusForbidden, test.Code)
+		}
+	}
+
+	test := authorizedRequest(server, "GET", "/login/github/totp?username=abc", token, reauthenticate(t, server, token, jwt.LOGIN_SCOPE, "github"), nil)
+
+	if test.Code != http.StatusOK {
+		t.Errorf("Expected: %d\nActual: %d", http.StatusOK, test.Code)
+	}
+
+	var totp_code models.TOTPCode
+	err = json.Unmarshal(test.Body.Bytes(), &totp_code)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if len(totp_code.Code) != 6 || totp_code.RemainingSeconds <= 0 {
+		t.Errorf("Invalid code: %v", totp_code)
+	}
+
+	t.Cleanup(login_controller_test_cleanup)
+}
+
+func TestPasswordHistory(t *testing.T) {
+	master_password_service := new(services.MasterPasswordService)
+	master_password_service.Init()
+	master_password_service.SetMasterPassword("12345")
+
+	login_service := new(services.LoginDataService)
+	login_service.Init()
+
+	login_controller := new(LoginDataController)
+	login_controller.Init()
+
+	login_data := make(map[string]interface{})
+	login_data["name"] = "github"
+	login_data["url"] = "https://github.com"
+	login_data["accounts"] = []interface{}{map[string]interface{}{"username": "abc", "password": "123"}}
+	login_data["attributes"] = map[string]interface{}{"is_favourite": true, "require_master_password": false}
+
+	err := login_service.AddLoginData(login_data)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	_, err = login_service.PatchLoginData("github", map[string]interface{}{"update_accounts": []interface{}{map[string]interface{}{"username": "abc", "password": "456"}}}, "")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	server := gin.Default()
+	server.GET("/login/:name/history", login_controller.GetPasswordHistory)
+	server.POST("/login/:name/history/restore", login_controller.RestorePassword)
+
+	test := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/login/github/history?username=abc", nil)
+	server.ServeHTTP(test, req)
+
+	if test.Code != http.StatusOK {
+		t.Errorf("Expected: %d\nActual: %d", http.StatusOK, test.Code)
+	}
+
+	var history []models.PasswordHistoryDate
+	err = json.Unmarshal(test.Body.Bytes(), &history)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if len(history) != 1 || history[0].ReplacedDateTime == "" || strings.Contains(test.Body.String(), "password") {
+		t.Errorf("Incorrect history %s", test.Body.String())
+	}
+
+	request_body, _ := json.Marshal(map[string]interface{}{"username": "abc", "index": 0})
+
+	test = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/login/github/history/restore", bytes.NewBuffer(request_body))
+	server.ServeHTTP(test, req)
+
+	if test.Code != http.StatusOK {
+		t.Errorf("Expected: %d\nActual: %d", http.StatusOK, test.Code)
+	}
+
+	password, err := login_service.GetDecryptedAccountPassword("github", "abc")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if password != "123" {
+		t.Errorf("Expected: %s\nActual: %s", "123", password)
+	}
+
+	test = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/login/github/history/restore", bytes.NewBuffer([]byte(`{"username": "abc"}`)))
+	server.ServeHTTP(test, req)
+
+	if test.Code != http.StatusBadRequest {
+		t.Errorf("Expected: %d\nActual: %d", http.StatusBadRequest, test.Code)
+	}
+
+	t.Cleanup(login_controller_test_cleanup)
+}
+
 func TestDeleteLoginData(t *testing.T) {
 	master_password_service := new(services.MasterPasswordService)
 	master_password_service.Init()
@@ -664,6 +893,65 @@ func TestDeleteLoginData(t *testing.T) {
 	t.Cleanup(login_controller_test_cleanup)
 }
 
+func TestGetAccountPassword_RequireMasterPassword(t *testing.T) {
+	master_password_service := new(services.MasterPasswordService)
+	master_password_service.Init()
+	master_password_service.SetMasterPassword("12345")
+
+	login_service := new(services.LoginDataService)
+	login_service.Init()
+
+	login_controller := new(LoginDataController)
+	login_controller.Init()
+
+	system_controller := new(SystemController)
+	system_controller.Init()
+
+	for _, name := range []string{"github", "gitlab"} {
+		login_data := make(map[string]interface{})
+		login_data["name"] = name
+		login_data["url"] = "https://" + name + ".com"
+		login_data["accounts"] = []interface{}{map[string]interface{}{"username": "abc", "password": "123"}}
+		login_data["attributes"] = map[string]interface{}{"is_favourite": false, "require_master_password": true}
+
+		if err := login_service.AddLoginData(login_data); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	server := gin.Default()
+	login_controller.RegisterRoutes(server.Group(""))
+	system_controller.RegisterRoutes(server.Group(""))
+
+	token, _ := jwt.GenerateToken(5)
+	other_token, _ := jwt.GenerateToken(5)
+
+	elevated_token := reauthenticate(t, server, token, jwt.LOGIN_SCOPE, "github")
+	other_entry_token := reauthenticate(t, server, token, jwt.LOGIN_SCOPE, "gitlab")
+	other_session_token := reauthenticate(t, server, other_token, jwt.LOGIN_SCOPE, "github")
+	note_token := reauthenticate(t, server, token, jwt.NOTE_SCOPE, "github")
+	// Issued for the right session and entry, only expired
+	expired_token, _ := jwt.GenerateElevatedToken(currentSessionID(server, token), jwt.Scope(jwt.LOGIN_SCOPE, "github"), -time.Minute)
+
+	for description, forbidden_token := range map[string]string{"no elevated token": "", "other entry": other_entry_token, "other session": other_session_token,
+		"other entry type": note_token, "expired": expired_token, "session token": token} {
+		if test := authorizedRequest(server, "GET", "/login/github?username=abc", token, forbidden_token, nil); test.Code != http.StatusForbidden {
+			t.Errorf("%s\nExpected: %d\nActual: %d %s", description, http.StatusForbidden, test.Code, test.Body.String())
+		}
+	}
+
+	test := authorizedRequest(server, "GET", "/login/github?username=abc", token, elevated_token, nil)
+
+	var decrypted_password string
+	json.Unmarshal(test.Body.Bytes(), &decrypted_password)
+
+	if test.Code != 200 || decrypted_password != "123" {
+		t.Errorf("Expected password\n%d %s", test.Code, test.Body.String())
+	}
+
+	t.Cleanup(login_controller_test_cleanup)
+}
+
 func TestGetAccountPassword(t *testing.T) {
 	master_password_service := new(services.MasterPasswordService)
 	master_password_service.Init()
@@ -728,3 +1016,49 @@ func TestGetAccountPassword(t *testing.T) {
 
 	t.Cleanup(login_controller_test_cleanup)
 }
+
+func BenchmarkGetLoginData_All(b *testing.B) {
+	login_controller_test_cleanup()
+
+	master_password_service := new(services.MasterPasswordService)
+	master_password_service.Init()
+	master_password_service.SetMasterPassword("12345")
+
+	login_service := new(services.LoginDataService)
+	login_service.Init()
+
+	login_controller := new(LoginDataController)
+	login_controller.Init()
+
+	entry_count := 3000
+	for index := range entry_count {
+		login_data := make(map[string]interface{})
+		login_data["name"] = "login_" + strconv.Itoa(index)
+		login_data["url"] = "https://github.com"
+		login_data["accounts"] = []interface{}{map[string]interface{}{"username": "abc", "password": "123"}, map[string]interface{}{"username": "pqr", "password": "456"}}
+		login_data["attributes"] = map[string]interface{}{"is_favourite": false, "require_master_password": false}
+
+		if err := login_service.AddLoginData(login_data); err != nil {
+			b.Fatal(err.Error())
+		}
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	server := gin.New()
+	server.GET("/login", login_controller.GetLoginData)
+
+	b.ResetTimer()
+	for range b.N {
+		test := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/login", bytes.NewReader([]byte{}))
+
+		server.ServeHTTP(test, req)
+
+		if test.Code != 200 {
+			b.Fatal(test.Body.String())
+		}
+	}
+	b.StopTimer()
+
+	login_controller_test_cleanup()
+}
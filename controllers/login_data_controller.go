@@ -1,11 +1,13 @@
 package controllers
 
 import (
-	"fmt"
+	"errors"
 	"ncrypt/services"
+	"ncrypt/utils/database"
 	"ncrypt/utils/jwt"
 	"ncrypt/utils/logger"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -50,11 +52,25 @@ func (obj *LoginDataController) GetLoginData(ctx *gin.Context) {
 		} else {
 			ctx.JSON(http.StatusOK, data)
 		}
-	} else if data, err := obj.service.GetLoginData(name); err != nil {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
-		logger.Log.Printf("ERROR: %s", err.Error())
-		return
 	} else {
+		// Version is read before data, so a write in between results in a conflict rather than a lost update
+		version, err := obj.service.GetLoginDataVersion(name)
+
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return
+		}
+
+		data, err := obj.service.GetLoginData(name)
+
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return
+		}
+
+		ctx.Header("ETag", formatETag(version))
 		ctx.JSON(http.StatusOK, data)
 	}
 }
@@ -63,7 +79,17 @@ func (obj *LoginDataController) GetAccountPassword(ctx *gin.Context) {
 	login_data_name := ctx.Param("name")
 	account_username := ctx.Query("username")
 
-	fmt.Println(login_data_name, account_username)
+	login_data, err := obj.service.GetLoginData(login_data_name)
+
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	if login_data.Attributes.RequireMasterPassword && !checkReauth(ctx, jwt.LOGIN_SCOPE, login_data.Name) {
+		return
+	}
 
 	password, err := obj.service.GetDecryptedAccountPassword(login_data_name, account_username)
 
@@ -76,6 +102,87 @@ func (obj *LoginDataController) GetAccountPassword(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, password)
 }
 
+// Get current TOTP code of an account. If login requires master password, an elevated token from POST /system/reauth is needed
+func (obj *LoginDataController) GetTOTPCode(ctx *gin.Context) {
+	login_data_name := ctx.Param("name")
+	account_username := ctx.Query("username")
+
+	login_data, err := obj.service.GetLoginData(login_data_name)
+
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	if login_data.Attributes.RequireMasterPassword && !checkReauth(ctx, jwt.LOGIN_SCOPE, login_data.Name) {
+		return
+	}
+
+	totp_code, err := obj.service.GetTOTPCode(login_data_name, account_username)
+
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, totp_code)
+}
+
+// List dates of previous passwords of an account
+func (obj *LoginDataController) GetPasswordHistory(ctx *gin.Context) {
+	login_data_name := ctx.Param("name")
+	account_username := ctx.Query("username")
+
+	history, err := obj.service.GetPasswordHistory(login_data_name, account_username)
+
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, history)
+}
+
+// Swap password at the given index of history back in
+func (obj *LoginDataController) RestorePassword(ctx *gin.Context) {
+	login_data_name := ctx.Param("name")
+
+	request_data := make(map[string]interface{})
+
+	if err := ctx.ShouldBindJSON(&request_data); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	account_username, is_username_valid := request_data["username"].(string)
+	history_index, is_index_valid := request_data["index"].(float64)
+
+	if !is_username_valid || !is_index_valid {
+		err := errors.New("username and index are required")
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	err := obj.service.RestorePassword(login_data_name, account_username, int(history_index))
+
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, database.ErrConflict) {
+			status = http.StatusConflict
+		}
+		ctx.AbortWithStatusJSON(status, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
 func (obj *LoginDataController) DeleteLoginData(ctx *gin.Context) {
 	name := ctx.Param("name")
 
@@ -108,6 +215,48 @@ func (obj *LoginDataController) UpdateLoginData(ctx *gin.Context) {
 	}
 }
 
+/*
+Partially update login data. Body can contain any of
+
+	url, attributes (is_favourite/require_master_password), add_accounts, update_accounts (username, new_username, password), remove_accounts
+
+ETag returned by GET /login?name= or a previous PATCH can be passed in If-Match header, a 409 is returned if the login was modified since.
+*/
+func (obj *LoginDataController) PatchLoginData(ctx *gin.Context) {
+	name := ctx.Param("name")
+
+	var patch_data map[string]interface{}
+
+	if err := ctx.ShouldBindJSON(&patch_data); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	version, err := obj.service.PatchLoginData(name, patch_data, parseETag(ctx.GetHeader("If-Match")))
+
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, database.ErrConflict) {
+			status = http.StatusConflict
+		}
+		ctx.AbortWithStatusJSON(status, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	ctx.Header("ETag", formatETag(version))
+	ctx.Status(http.StatusOK)
+}
+
+func formatETag(version string) string {
+	return "\"" + version + "\""
+}
+
+func parseETag(etag string) string {
+	return strings.Trim(strings.TrimPrefix(etag, "W/"), "\"")
+}
+
 func (obj *LoginDataController) RegisterRoutes(rg *gin.RouterGroup) {
 	group := rg.Group("/login")
 
@@ -116,7 +265,11 @@ func (obj *LoginDataController) RegisterRoutes(rg *gin.RouterGroup) {
 
 	group.GET("", obj.GetLoginData)
 	group.GET("/:name", obj.GetAccountPassword)
+	group.GET("/:name/totp", obj.GetTOTPCode)
+	group.GET("/:name/history", obj.GetPasswordHistory)
+	group.POST("/:name/history/restore", obj.RestorePassword)
 
 	group.DELETE("/:name", obj.DeleteLoginData)
 	group.PUT("/:name", obj.UpdateLoginData)
+	group.PATCH("/:name", obj.PatchLoginData)
 }
@@ -10,13 +10,15 @@ import (
 )
 
 type MasterPasswordController struct {
-	service services.IMasterPasswordService
+	service        services.IMasterPasswordService
+	system_service services.SystemService
 }
 
 func (obj *MasterPasswordController) Init() {
 	logger.Log.Printf("Initializing master password controller")
 	obj.service = services.InitBadgerMasterPasswordService()
 	obj.service.Init()
+	obj.system_service.InitHeadless()
 	logger.Log.Printf("Initialization complete!")
 }
 
@@ -27,7 +29,23 @@ func (obj *MasterPasswordController) UpdatePassword(ctx *gin.Context) {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
 		logger.Log.Printf("ERROR: %s", err.Error())
 		return
-	} else if err := obj.service.UpdateMasterPassword(data["old_master_password"], data["new_master_password"]); err != nil {
+	}
+
+	// Old password is checked with the same lockout as sign in, so this cannot be used to guess the master password
+	result, err := obj.system_service.ValidateMasterPassword(data["old_master_password"])
+
+	if err != nil {
+		ctx.AbortWithStatusJSON(masterPasswordErrorStatus(err), err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+	if !result {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, "password does not match")
+		logger.Log.Printf("ERROR: %s", "password does not match")
+		return
+	}
+
+	if err := obj.service.UpdateMasterPassword(data["old_master_password"], data["new_master_password"]); err != nil {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
 		logger.Log.Printf("ERROR: %s", err.Error())
 		return
@@ -45,10 +63,11 @@ func (obj *MasterPasswordController) ValidatePassword(ctx *gin.Context) {
 		return
 	}
 
-	result, err := obj.service.Validate(data["master_password"])
+	// Shares lockout with sign in, so this cannot be used to guess the master password
+	result, err := obj.system_service.ValidateMasterPassword(data["master_password"])
 
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		ctx.AbortWithStatusJSON(masterPasswordErrorStatus(err), err.Error())
 		logger.Log.Printf("ERROR: %s", err.Error())
 		return
 	}
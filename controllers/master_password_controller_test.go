@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"ncrypt/services"
-	"ncrypt/utils/encryptor"
+	"ncrypt/utils/database"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -30,8 +30,6 @@ func TestUpdatePassword(t *testing.T) {
 	password_data["old_master_password"] = "12345"
 	password_data["new_master_password"] = "123"
 
-	hashed_password := encryptor.CreateHash(password_data["new_master_password"])
-
 	password_data_bytes, err := json.Marshal(password_data)
 
 	if err != nil {
@@ -54,14 +52,14 @@ func TestUpdatePassword(t *testing.T) {
 		t.Error(data)
 	} else {
 		//Validate set password
-		data, err := master_password_service.GetMasterPassword()
+		result, err := master_password_service.Validate(password_data["new_master_password"])
 
 		if err != nil {
 			t.Error(err.Error())
 		}
 
-		if data != hashed_password {
-			t.Errorf("Mistmatch in password\nExpected: %s\nActual:%s", hashed_password, data)
+		if !result {
+			t.Errorf("Mistmatch in password\nExpected: %t\nActual:%t", true, result)
 			return
 		}
 	}
@@ -110,6 +108,44 @@ func TestUpdatePassword_IncorrectOldPassword(t *testing.T) {
 
 	t.Cleanup(master_password_controller_test_cleanup)
 }
+func TestUpdatePassword_LockedOut(t *testing.T) {
+	system_service := new(services.SystemService)
+	system_service.Init()
+
+	master_password_controller := new(MasterPasswordController)
+	master_password_controller.Init()
+
+	auto_backup_setting := map[string]interface{}{"is_enabled": false, "backup_location": "", "backup_file_name": ""}
+
+	if err := system_service.Setup("12345", auto_backup_setting); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	server := gin.Default()
+	server.PUT("/master_password", master_password_controller.UpdatePassword)
+
+	updatePassword := func(old_master_password string) int {
+		password_data_bytes, _ := json.Marshal(map[string]string{"old_master_password": old_master_password, "new_master_password": "123"})
+		test := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/master_password", bytes.NewReader(password_data_bytes))
+		server.ServeHTTP(test, req)
+		return test.Code
+	}
+
+	//Failed attempts count towards the sign in lockout
+	for i := 0; i <= services.FREE_SIGN_IN_ATTEMPTS; i++ {
+		if code := updatePassword("456"); code != http.StatusBadRequest {
+			t.Errorf("Attempt %d: expected %d, got %d", i+1, http.StatusBadRequest, code)
+		}
+	}
+
+	if code := updatePassword("12345"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected %d while locked out, got %d", http.StatusTooManyRequests, code)
+	}
+
+	t.Cleanup(master_password_controller_test_cleanup)
+}
+
 func TestUpdatePassword_NewPasswordSameAsOld(t *testing.T) {
 	password_data := make(map[string]string)
 
@@ -231,6 +267,7 @@ func TestValidatePassword_FAIL(t *testing.T) {
 }
 
 func master_password_controller_test_cleanup() {
+	database.Close()
 	os.RemoveAll(os.Getenv("STORAGE_FOLDER"))
 	os.RemoveAll("logs")
 }
@@ -62,6 +62,18 @@ func (obj *NoteController) GetNote(ctx *gin.Context) {
 func (obj *NoteController) GetContent(ctx *gin.Context) {
 	created_date_time := ctx.Param("created_date_time")
 
+	note, err := obj.service.GetNote(created_date_time)
+
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	if note.Attributes.RequireMasterPassword && !checkReauth(ctx, jwt.NOTE_SCOPE, note.CreatedDateTime) {
+		return
+	}
+
 	if data, err := obj.service.GetDecryptedContent(created_date_time); err != nil {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
 		logger.Log.Printf("ERROR: %s", err.Error())
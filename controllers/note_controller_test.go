@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"ncrypt/models"
 	"ncrypt/services"
+	"ncrypt/utils/database"
+	"ncrypt/utils/jwt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -601,6 +604,59 @@ func TestGetDecryptedContent_InvalidCreatedDateTime(t *testing.T) {
 	t.Cleanup(note_controller_test_cleanup)
 }
 
+func TestGetDecryptedContent_RequireMasterPassword(t *testing.T) {
+	master_password_service := new(services.MasterPasswordService)
+	master_password_service.Init()
+	master_password_service.SetMasterPassword("12345")
+
+	note_service := new(services.NoteService)
+	note_service.Init()
+
+	note_controller := new(NoteController)
+	note_controller.Init()
+
+	system_controller := new(SystemController)
+	system_controller.Init()
+
+	note_data := make(map[string]interface{})
+	note_data["created_date_time"] = "123"
+	note_data["title"] = "abc"
+	note_data["content"] = "my content"
+	note_data["attributes"] = map[string]interface{}{"is_favourite": false, "require_master_password": true}
+
+	if err := note_service.AddNote(note_data); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	server := gin.Default()
+	note_controller.RegisterRoutes(server.Group(""))
+	system_controller.RegisterRoutes(server.Group(""))
+
+	token, _ := jwt.GenerateToken(5)
+
+	other_entry_token := reauthenticate(t, server, token, jwt.NOTE_SCOPE, "124")
+	// Issued for the right session and entry, only expired
+	expired_token, _ := jwt.GenerateElevatedToken(currentSessionID(server, token), jwt.Scope(jwt.NOTE_SCOPE, "123"), -time.Minute)
+
+	for description, forbidden_token := range map[string]string{"no elevated token": "", "other entry": other_entry_token, "expired": expired_token} {
+		if test := authorizedRequest(server, "GET", "/note/123", token, forbidden_token, nil); test.Code != http.StatusForbidden {
+			t.Errorf("%s\nExpected: %d\nActual: %d %s", description, http.StatusForbidden, test.Code, test.Body.String())
+		}
+	}
+
+	test := authorizedRequest(server, "GET", "/note/123", token, reauthenticate(t, server, token, jwt.NOTE_SCOPE, "123"), nil)
+
+	var content string
+	json.Unmarshal(test.Body.Bytes(), &content)
+
+	if test.Code != 200 || content != "my content" {
+		t.Errorf("Expected content\n%d %s", test.Code, test.Body.String())
+	}
+
+	t.Cleanup(note_controller_test_cleanup)
+}
+
 func note_controller_test_cleanup() {
+	database.Close()
 	os.RemoveAll(os.Getenv("STORAGE_FOLDER"))
 }
@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"ncrypt/utils/jwt"
+	"ncrypt/utils/logger"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+Abort with 403 unless the request carries an elevated token for the entry, see POST /system/reauth.
+Used before revealing entries that require master password. Returns false if the request was aborted.
+*/
+func checkReauth(ctx *gin.Context, entry_type string, entry_id string) bool {
+	err := jwt.ValidateElevatedToken(ctx.GetHeader(jwt.ELEVATED_TOKEN_HEADER), ctx.GetString(jwt.SESSION_ID), jwt.Scope(entry_type, entry_id))
+
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return false
+	}
+
+	return true
+}
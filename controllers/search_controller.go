@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"ncrypt/services"
+	"ncrypt/utils/jwt"
+	"ncrypt/utils/logger"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchController struct {
+	service services.ISearchService
+}
+
+func (obj *SearchController) Init() {
+	obj.service = services.InitSearchService()
+	obj.service.Init()
+}
+
+func (obj *SearchController) Search(ctx *gin.Context) {
+	is_content_included := false
+	page := 1
+	page_size := 0
+
+	var err error
+	if value := ctx.Query("include_content"); value != "" {
+		is_content_included, err = strconv.ParseBool(value)
+	}
+	if value := ctx.Query("page"); value != "" && err == nil {
+		page, err = strconv.Atoi(value)
+	}
+	if value := ctx.Query("page_size"); value != "" && err == nil {
+		page_size, err = strconv.Atoi(value)
+	}
+
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	result, err := obj.service.Search(ctx.Query("q"), is_content_included, page, page_size)
+
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+func (obj *SearchController) RegisterRoutes(rg *gin.RouterGroup) {
+	group := rg.Group("/search")
+
+	group.Use(jwt.ValidateAuthorization())
+	group.GET("", obj.Search)
+}
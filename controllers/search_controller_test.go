@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"encoding/json"
+	"ncrypt/models"
+	"ncrypt/services"
+	"ncrypt/utils/database"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSearch(t *testing.T) {
+	master_password_service := new(services.MasterPasswordService)
+	master_password_service.Init()
+	master_password_service.SetMasterPassword("12345")
+
+	note_service := services.InitBadgerNoteService()
+	note_service.Init()
+	note_service.AddNote(map[string]interface{}{"created_date_time": "123", "title": "abc", "content": "my content", "attributes": map[string]interface{}{"is_favourite": false, "require_master_password": false}})
+
+	search_controller := new(SearchController)
+	search_controller.Init()
+
+	server := gin.Default()
+	server.GET("/search", search_controller.Search)
+
+	test_cases := []struct {
+		url           string
+		expected_code int
+		expected_ids  []string
+	}{
+		{"/search?q=abc", http.StatusOK, []string{"123"}},
+		{"/search?q=content&include_content=true", http.StatusOK, []string{"123"}},
+		{"/search?q=content", http.StatusOK, nil},
+		{"/search", http.StatusBadRequest, nil},
+		{"/search?q=abc&page=x", http.StatusBadRequest, nil},
+	}
+
+	for _, test_case := range test_cases {
+		test := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", test_case.url, nil)
+
+		server.ServeHTTP(test, req)
+
+		if test.Code != test_case.expected_code {
+			t.Errorf("%s\nExpected: %d\nActual: %d", test_case.url, test_case.expected_code, test.Code)
+			continue
+		}
+
+		if test.Code != http.StatusOK {
+			continue
+		}
+
+		var search_page models.SearchPage
+		if err := json.Unmarshal(test.Body.Bytes(), &search_page); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		var ids []string
+		for _, result := range search_page.Results {
+			ids = append(ids, result.ID)
+		}
+
+		if len(ids) != len(test_case.expected_ids) || (len(ids) > 0 && ids[0] != test_case.expected_ids[0]) {
+			t.Errorf("%s\nExpected: %v\nActual: %v", test_case.url, test_case.expected_ids, ids)
+		}
+	}
+
+	t.Cleanup(search_controller_test_cleanup)
+}
+
+func search_controller_test_cleanup() {
+	database.Close()
+	os.RemoveAll(os.Getenv("STORAGE_FOLDER"))
+}
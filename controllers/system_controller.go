@@ -1,10 +1,13 @@
 package controllers
 
 import (
+	"errors"
 	"ncrypt/services"
 	"ncrypt/utils/jwt"
 	"ncrypt/utils/logger"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -64,11 +67,41 @@ func (obj *SystemController) SignIn(ctx *gin.Context) {
 
 	token, err := obj.service.SignIn(request_data["master_password"])
 	if err != nil {
+		ctx.AbortWithStatusJSON(masterPasswordErrorStatus(err), err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, token)
+}
+
+// Locked out attempts are reported as 429 so clients can tell them apart from an incorrect password
+func masterPasswordErrorStatus(err error) int {
+	if errors.Is(err, services.ErrSignInLocked) {
+		return http.StatusTooManyRequests
+	}
+
+	return http.StatusBadRequest
+}
+
+// Re-enter master password to get an elevated token for a single entry that requires master password
+func (obj *SystemController) Reauthenticate(ctx *gin.Context) {
+	request_data := make(map[string]string)
+
+	//Check if given JSON is valid
+	if err := ctx.ShouldBindJSON(&request_data); err != nil {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
 		logger.Log.Printf("ERROR: %s", err.Error())
 		return
 	}
 
+	token, err := obj.service.Reauthenticate(request_data["master_password"], ctx.GetString(jwt.SESSION_ID), request_data["type"], request_data["id"])
+	if err != nil {
+		ctx.AbortWithStatusJSON(masterPasswordErrorStatus(err), err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
 	ctx.JSON(http.StatusOK, token)
 }
 
@@ -120,15 +153,96 @@ func (obj *SystemController) Import(ctx *gin.Context) {
 	ctx.Status(http.StatusOK)
 }
 
+// Merge a .ncrypt export into the vault. Returns a summary of added, overwritten, renamed and skipped logins and notes
+func (obj *SystemController) ImportMerge(ctx *gin.Context) {
+	request_data := make(map[string]string)
+
+	if err := ctx.ShouldBindJSON(&request_data); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	summary, err := obj.service.ImportWithStrategy(request_data["file_name"], request_data["path"], request_data["master_password"], request_data["strategy"])
+
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, summary)
+}
+
+// Import export of another password manager. With dry_run nothing is written and the report previews the import
+func (obj *SystemController) ImportExternal(ctx *gin.Context) {
+	request_data := make(map[string]interface{})
+
+	if err := ctx.ShouldBindJSON(&request_data); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	format, _ := request_data["format"].(string)
+	path, _ := request_data["path"].(string)
+	is_dry_run, _ := request_data["dry_run"].(bool)
+
+	report, err := obj.service.ImportExternal(format, path, is_dry_run)
+
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+// Read cleartext header of a backup file, master password is not needed
+func (obj *SystemController) InspectBackup(ctx *gin.Context) {
+	header, err := obj.service.InspectBackup(ctx.Query("file_name"), ctx.Query("path"))
+
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, header)
+}
+
+// Restore a backup into a temporary store and compare it with the vault
+func (obj *SystemController) VerifyBackup(ctx *gin.Context) {
+	request_data := make(map[string]string)
+
+	if err := ctx.ShouldBindJSON(&request_data); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	verification, err := obj.service.VerifyBackup(request_data["file_name"], request_data["path"], request_data["master_password"])
+
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, verification)
+}
+
 func (obj *SystemController) GeneratePassword(ctx *gin.Context) {
-	password := obj.service.GeneratePassword()
+	generated_password, err := obj.service.GeneratePassword()
 
-	if password == "" {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, "Error occured while generating password")
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
 		return
 	}
 
-	ctx.JSON(http.StatusOK, password)
+	ctx.JSON(http.StatusOK, generated_password)
 }
 
 func (obj *SystemController) Backup(ctx *gin.Context) {
@@ -215,6 +329,9 @@ func (obj *SystemController) UpdateSessionDuration(ctx *gin.Context) {
 		return
 	}
 
+	// Token is replaced by the updated one
+	jwt.RevokeSession(ctx.GetString(jwt.SESSION_ID))
+
 	ctx.JSON(http.StatusOK, updated_token)
 }
 
@@ -227,9 +344,45 @@ func (obj *SystemController) ExtendSession(ctx *gin.Context) {
 		return
 	}
 
+	// Token is replaced by the extended one
+	jwt.RevokeSession(ctx.GetString(jwt.SESSION_ID))
+
 	ctx.JSON(http.StatusOK, new_token)
 }
 
+func (obj *SystemController) UpdateWipeAfterFailedAttempts(ctx *gin.Context) {
+	request_data := make(map[string]interface{})
+
+	//Check if given JSON is valid
+	if err := ctx.ShouldBindJSON(&request_data); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	wipe_after_failed_attempts, ok := request_data["wipe_after_failed_attempts"].(float64)
+
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, "wipe_after_failed_attempts is required")
+		logger.Log.Printf("ERROR: %s", "wipe_after_failed_attempts is required")
+		return
+	}
+
+	err := obj.service.UpdateWipeAfterFailedAttempts(int(wipe_after_failed_attempts))
+
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (obj *SystemController) GetSessions(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, obj.service.GetSessions(ctx.GetString(jwt.SESSION_ID)))
+}
+
 func (obj *SystemController) UpdateTheme(ctx *gin.Context) {
 	var theme map[string]string
 
@@ -256,6 +409,86 @@ func (obj *SystemController) GetTheme(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, theme)
 }
 
+func (obj *SystemController) Audit(ctx *gin.Context) {
+	max_age_in_days := services.DEFAULT_PASSWORD_MAX_AGE_IN_DAYS
+
+	if value := ctx.Query("max_age_days"); value != "" {
+		parsed_value, err := strconv.Atoi(value)
+
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return
+		}
+
+		max_age_in_days = parsed_value
+	}
+
+	report, err := obj.service.Audit(max_age_in_days)
+
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+// Event types can be repeated or comma separated
+func (obj *SystemController) GetAuditLog(ctx *gin.Context) {
+	var event_types []string
+	for _, value := range ctx.QueryArray("event_type") {
+		for _, event_type := range strings.Split(value, ",") {
+			if event_type = strings.TrimSpace(event_type); event_type != "" {
+				event_types = append(event_types, event_type)
+			}
+		}
+	}
+
+	entries, err := obj.service.GetAuditLog(ctx.Query("from"), ctx.Query("to"), event_types)
+
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entries)
+}
+
+func (obj *SystemController) VerifyAuditLog(ctx *gin.Context) {
+	verification, err := obj.service.VerifyAuditLog()
+
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, verification)
+}
+
+func (obj *SystemController) BreachCheck(ctx *gin.Context) {
+	var request_data map[string]string
+
+	if err := ctx.ShouldBindJSON(&request_data); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	report, err := obj.service.BreachCheck(request_data["path"])
+
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
+
 func (obj *SystemController) RegisterRoutes(rg *gin.RouterGroup) {
 	group := rg.Group("system")
 
@@ -263,6 +496,7 @@ func (obj *SystemController) RegisterRoutes(rg *gin.RouterGroup) {
 	group.POST("/signin", obj.SignIn)
 	group.GET("/generate_password", obj.GeneratePassword)
 	group.POST("/import", obj.Import)
+	group.GET("/backup/inspect", obj.InspectBackup)
 	group.GET("/theme", obj.GetTheme)
 
 	group.Use(jwt.ValidateAuthorization())
@@ -272,6 +506,9 @@ func (obj *SystemController) RegisterRoutes(rg *gin.RouterGroup) {
 	group.PUT("/password_generator_preference", obj.UpdatePasswordGeneratorPreference)
 	group.PUT("/session_duration", obj.UpdateSessionDuration)
 	group.GET("/session_duration", obj.ExtendSession)
+	group.GET("/sessions", obj.GetSessions)
+	group.PUT("/wipe_after_failed_attempts", obj.UpdateWipeAfterFailedAttempts)
+	group.POST("/reauth", obj.Reauthenticate)
 
 	group.PUT("/theme", obj.UpdateTheme)
 
@@ -279,4 +516,11 @@ func (obj *SystemController) RegisterRoutes(rg *gin.RouterGroup) {
 	group.POST("/logout", obj.Logout)
 	group.POST("/export", obj.Export)
 	group.POST("/backup", obj.Backup)
+	group.POST("/backup/verify", obj.VerifyBackup)
+	group.GET("/audit", obj.Audit)
+	group.GET("/audit_log", obj.GetAuditLog)
+	group.GET("/audit_log/verify", obj.VerifyAuditLog)
+	group.POST("/breach_check", obj.BreachCheck)
+	group.POST("/import/external", obj.ImportExternal)
+	group.POST("/import/merge", obj.ImportMerge)
 }
@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"ncrypt/models"
 	"ncrypt/services"
+	"ncrypt/utils/database"
+	"ncrypt/utils/jwt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -258,6 +261,43 @@ func TestSignIn_WithIncorrectPassword(t *testing.T) {
 	t.Cleanup(system_controller_test_cleanup)
 }
 
+func TestSignIn_LockedOut(t *testing.T) {
+	system_service := new(services.SystemService)
+	system_service.Init()
+
+	system_controller := new(SystemController)
+	system_controller.Init()
+
+	server := gin.Default()
+	server.POST("/system/signin", system_controller.SignIn)
+
+	auto_backup_setting := map[string]interface{}{"is_enabled": false, "backup_location": "", "backup_file_name": ""}
+
+	if err := system_service.Setup("12345", auto_backup_setting); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	signIn := func(password string) int {
+		request_data_bytes, _ := json.Marshal(map[string]string{"master_password": password})
+		test := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/system/signin", bytes.NewBuffer(request_data_bytes))
+		server.ServeHTTP(test, req)
+		return test.Code
+	}
+
+	for i := 0; i <= services.FREE_SIGN_IN_ATTEMPTS; i++ {
+		if code := signIn("123"); code != http.StatusBadRequest {
+			t.Errorf("Attempt %d: expected %d, got %d", i+1, http.StatusBadRequest, code)
+		}
+	}
+
+	if code := signIn("12345"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected %d while locked out, got %d", http.StatusTooManyRequests, code)
+	}
+
+	t.Cleanup(system_controller_test_cleanup)
+}
+
 func TestLogout(t *testing.T) {
 	system_service := new(services.SystemService)
 	system_service.Init()
@@ -409,7 +449,7 @@ func TestExport_WithCustomPath(t *testing.T) {
 
 	request_data := make(map[string]interface{})
 	request_data["file_name"] = "test_export.ncrypt"
-	request_data["path"] = "..\\models"
+	request_data["path"] = filepath.Join("..", "models")
 
 	request_data_bytes, err := json.Marshal(request_data)
 
@@ -430,10 +470,10 @@ func TestExport_WithCustomPath(t *testing.T) {
 		t.Error(data)
 	}
 
-	if _, err := os.Stat("..\\models\\test_export.ncrypt"); os.IsNotExist(err) {
+	if _, err := os.Stat(filepath.Join("..", "models", "test_export.ncrypt")); os.IsNotExist(err) {
 		t.Error("Exported file not found")
 	} else {
-		err = os.Remove("..\\models\\test_export.ncrypt")
+		err = os.Remove(filepath.Join("..", "models", "test_export.ncrypt"))
 		if err != nil {
 			t.Error(err.Error())
 		}
@@ -464,7 +504,7 @@ func TestExport_WithInvalidPath(t *testing.T) {
 
 	request_data := make(map[string]interface{})
 	request_data["file_name"] = "test_export.ncrypt"
-	request_data["path"] = "..\\test"
+	request_data["path"] = filepath.Join("..", "test")
 
 	request_data_bytes, err := json.Marshal(request_data)
 
@@ -1010,12 +1050,16 @@ func TestSetPasswordPreference_GeneratePassword(t *testing.T) {
 
 		t.Error(data)
 	} else {
-		var data string
+		var data models.GeneratedPassword
 
 		json.Unmarshal(test.Body.Bytes(), &data)
 
-		if len(data) == 0 {
-			t.Errorf("Generated password cannot be empty")
+		if len(data.Password) != 16 {
+			t.Errorf("Generated password should be of length 16")
+		}
+
+		if data.EntropyBits <= 0 {
+			t.Errorf("Entropy should be returned")
 		}
 	}
 
@@ -1136,6 +1180,180 @@ func TestImport_WithIncorrectMasterPassword(t *testing.T) {
 	t.Cleanup(system_controller_test_cleanup)
 }
 
+func TestImportMerge(t *testing.T) {
+	system_service := new(services.SystemService)
+	system_service.Init()
+
+	system_controller := new(SystemController)
+	system_controller.Init()
+
+	server := gin.Default()
+
+	auto_backup_setting := map[string]interface{}{"is_enabled": false, "backup_location": "", "backup_file_name": ""}
+
+	err := system_service.Setup("12345", auto_backup_setting)
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	login_service := services.InitBadgerLoginService()
+	login_service.Init()
+	login_service.AddLoginData(map[string]interface{}{"name": "github", "url": "https://github.com", "attributes": map[string]interface{}{"is_favourite": false, "require_master_password": false}, "accounts": []interface{}{
+		map[string]interface{}{"username": "abc", "password": "123"},
+	}})
+
+	err = system_service.Export("test_export.ncrypt", "")
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	server.POST("/system/import/merge", system_controller.ImportMerge)
+
+	for _, strategy := range []string{"UNKNOWN", models.IMPORT_KEEP_BOTH} {
+		test := httptest.NewRecorder()
+		request_data_bytes, _ := json.Marshal(map[string]string{"file_name": "test_export.ncrypt", "path": "", "master_password": "12345", "strategy": strategy})
+
+		req, _ := http.NewRequest("POST", "/system/import/merge", bytes.NewBuffer(request_data_bytes))
+		server.ServeHTTP(test, req)
+
+		if strategy == "UNKNOWN" {
+			if test.Code != http.StatusBadRequest {
+				t.Errorf("Expected: %d\nActual: %d", http.StatusBadRequest, test.Code)
+			}
+			continue
+		}
+
+		if test.Code != 200 {
+			t.Fatal(test.Body.String())
+		}
+
+		var summary models.ImportSummary
+		json.Unmarshal(test.Body.Bytes(), &summary)
+
+		if len(summary.Logins.Renamed) != 1 || summary.Logins.Renamed[0].To != "github (1)" {
+			t.Errorf("Incorrect summary %+v", summary)
+		}
+	}
+
+	err = os.Remove("test_export.ncrypt")
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	t.Cleanup(system_controller_test_cleanup)
+}
+
+func TestInspectBackup(t *testing.T) {
+	system_service := new(services.SystemService)
+	system_service.Init()
+
+	system_controller := new(SystemController)
+	system_controller.Init()
+
+	server := gin.Default()
+
+	auto_backup_setting := map[string]interface{}{"is_enabled": false, "backup_location": "", "backup_file_name": ""}
+
+	err := system_service.Setup("12345", auto_backup_setting)
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	err = system_service.Export("test_export.ncrypt", "")
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	server.GET("/system/backup/inspect", system_controller.InspectBackup)
+
+	test := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/system/backup/inspect?file_name=test_export.ncrypt", nil)
+	server.ServeHTTP(test, req)
+
+	if test.Code != 200 {
+		t.Fatal(test.Body.String())
+	}
+
+	var header models.BackupHeader
+	json.Unmarshal(test.Body.Bytes(), &header)
+
+	if header.FormatVersion != 2 || header.LoginCount != 0 || header.CreatedDateTime == "" {
+		t.Errorf("Incorrect header %+v", header)
+	}
+
+	test = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/system/backup/inspect?file_name=missing.ncrypt", nil)
+	server.ServeHTTP(test, req)
+
+	if test.Code != http.StatusBadRequest {
+		t.Errorf("Expected: %d\nActual: %d", http.StatusBadRequest, test.Code)
+	}
+
+	err = os.Remove("test_export.ncrypt")
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	t.Cleanup(system_controller_test_cleanup)
+}
+
+func TestVerifyBackup(t *testing.T) {
+	system_service := new(services.SystemService)
+	system_service.Init()
+
+	system_controller := new(SystemController)
+	system_controller.Init()
+
+	server := gin.Default()
+
+	auto_backup_setting := map[string]interface{}{"is_enabled": false, "backup_location": "", "backup_file_name": ""}
+
+	err := system_service.Setup("12345", auto_backup_setting)
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	err = system_service.Export("test_export.ncrypt", "")
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	server.POST("/system/backup/verify", system_controller.VerifyBackup)
+
+	for _, master_password := range []string{"123", "12345"} {
+		test := httptest.NewRecorder()
+		request_data_bytes, _ := json.Marshal(map[string]string{"file_name": "test_export.ncrypt", "path": "", "master_password": master_password})
+
+		req, _ := http.NewRequest("POST", "/system/backup/verify", bytes.NewBuffer(request_data_bytes))
+		server.ServeHTTP(test, req)
+
+		if master_password == "123" {
+			if test.Code != http.StatusBadRequest {
+				t.Errorf("Expected: %d\nActual: %d", http.StatusBadRequest, test.Code)
+			}
+			continue
+		}
+
+		if test.Code != 200 {
+			t.Fatal(test.Body.String())
+		}
+
+		var verification models.BackupVerification
+		json.Unmarshal(test.Body.Bytes(), &verification)
+
+		if !verification.IsValid || !verification.IsComparedWithVault {
+			t.Errorf("Incorrect verification %+v", verification)
+		}
+	}
+
+	err = os.Remove("test_export.ncrypt")
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	t.Cleanup(system_controller_test_cleanup)
+}
+
 func TestBackup(t *testing.T) {
 	system_service := new(services.SystemService)
 	system_service.Init()
@@ -1463,6 +1681,110 @@ func TestExtendSessionDuration(t *testing.T) {
 	t.Cleanup(system_controller_test_cleanup)
 }
 
+func TestGetSessions(t *testing.T) {
+	system_service := new(services.SystemService)
+	system_service.Init()
+
+	system_controller := new(SystemController)
+	system_controller.Init()
+
+	server := gin.Default()
+	system_controller.RegisterRoutes(server.Group(""))
+
+	auto_backup_setting := map[string]interface{}{"is_enabled": false, "backup_location": "", "backup_file_name": ""}
+
+	if err := system_service.Setup("12345", auto_backup_setting); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	token, err := system_service.SignIn("12345")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	request := func(method string, path string) *httptest.ResponseRecorder {
+		test := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer([]byte{}))
+		req.Header.Set("Authorization", "Bearer "+token)
+		server.ServeHTTP(test, req)
+		return test
+	}
+
+	test := request("GET", "/system/sessions")
+
+	var sessions []models.Session
+	json.Unmarshal(test.Body.Bytes(), &sessions)
+
+	current_count := 0
+	for _, session := range sessions {
+		if session.IsCurrent {
+			current_count += 1
+		}
+	}
+
+	if test.Code != 200 || current_count != 1 {
+		t.Errorf("Expected current session\n%d %s", test.Code, test.Body.String())
+	}
+
+	if test := request("POST", "/system/logout"); test.Code != 200 {
+		t.Error(test.Body.String())
+	}
+
+	// Token is revoked on logout
+	if test := request("GET", "/system/sessions"); test.Code != http.StatusUnauthorized {
+		t.Errorf("Expected: %d\nActual: %d", http.StatusUnauthorized, test.Code)
+	}
+
+	t.Cleanup(system_controller_test_cleanup)
+}
+
+func TestGetAuditLog(t *testing.T) {
+	system_service := new(services.SystemService)
+	system_service.Init()
+
+	system_controller := new(SystemController)
+	system_controller.Init()
+
+	server := gin.Default()
+	system_controller.RegisterRoutes(server.Group(""))
+
+	auto_backup_setting := map[string]interface{}{"is_enabled": false, "backup_location": "", "backup_file_name": ""}
+
+	if err := system_service.Setup("12345", auto_backup_setting); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	system_service.SignIn("123")
+	token, err := system_service.SignIn("12345")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	test := authorizedRequest(server, "GET", "/system/audit_log?event_type=SIGN_IN,SIGN_IN_FAILED", token, "", nil)
+
+	var entries []models.AuditLogEntry
+	json.Unmarshal(test.Body.Bytes(), &entries)
+
+	if test.Code != 200 || len(entries) != 2 || entries[0].EventType != services.AUDIT_SIGN_IN_FAILED || entries[1].EventType != services.AUDIT_SIGN_IN {
+		t.Errorf("Unexpected audit log\n%d %s", test.Code, test.Body.String())
+	}
+
+	if test := authorizedRequest(server, "GET", "/system/audit_log?from=yesterday", token, "", nil); test.Code != http.StatusBadRequest {
+		t.Errorf("Expected: %d\nActual: %d", http.StatusBadRequest, test.Code)
+	}
+
+	test = authorizedRequest(server, "GET", "/system/audit_log/verify", token, "", nil)
+
+	var verification models.AuditLogVerification
+	json.Unmarshal(test.Body.Bytes(), &verification)
+
+	if test.Code != 200 || !verification.IsValid {
+		t.Errorf("Audit log should be valid\n%d %s", test.Code, test.Body.String())
+	}
+
+	t.Cleanup(system_controller_test_cleanup)
+}
+
 func TestUpdateTheme(t *testing.T) {
 	system_service := new(services.SystemService)
 	system_service.Init()
@@ -1507,6 +1829,244 @@ func TestUpdateTheme(t *testing.T) {
 	t.Cleanup(system_controller_test_cleanup)
 }
 
+func TestAudit(t *testing.T) {
+	master_password_service := new(services.MasterPasswordService)
+	master_password_service.Init()
+	master_password_service.SetMasterPassword("12345")
+
+	login_service := new(services.LoginDataService)
+	login_service.Init()
+
+	system_controller := new(SystemController)
+	system_controller.Init()
+
+	login_data := make(map[string]interface{})
+	login_data["name"] = "github"
+	login_data["url"] = "https://github.com"
+	login_data["accounts"] = []interface{}{
+		map[string]interface{}{"username": "abc", "password": "qwerty123"},
+		map[string]interface{}{"username": "pqr", "password": "qwerty123"},
+	}
+	login_data["attributes"] = map[string]interface{}{"is_favourite": false, "require_master_password": false}
+
+	err := login_service.AddLoginData(login_data)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	server := gin.Default()
+	server.GET("/system/audit", system_controller.Audit)
+
+	test := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/system/audit?max_age_days=30", nil)
+	server.ServeHTTP(test, req)
+
+	if test.Code != http.StatusOK {
+		t.Errorf("Expected: %d\nActual: %d", http.StatusOK, test.Code)
+	}
+
+	if strings.Contains(test.Body.String(), "qwerty123") {
+		t.Error("Audit response should not contain passwords")
+	}
+
+	var report models.AuditReport
+	err = json.Unmarshal(test.Body.Bytes(), &report)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if len(report.Accounts) != 2 || report.WeakCount != 2 || report.ReusedCount != 2 || report.OldCount != 0 || report.MaxAgeInDays != 30 {
+		t.Errorf("Incorrect report %+v", report)
+	}
+
+	test = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/system/audit?max_age_days=abc", nil)
+	server.ServeHTTP(test, req)
+
+	if test.Code != http.StatusBadRequest {
+		t.Errorf("Expected: %d\nActual: %d", http.StatusBadRequest, test.Code)
+	}
+
+	t.Cleanup(system_controller_test_cleanup)
+}
+
+func TestBreachCheck(t *testing.T) {
+	master_password_service := new(services.MasterPasswordService)
+	master_password_service.Init()
+	master_password_service.SetMasterPassword("12345")
+
+	login_service := new(services.LoginDataService)
+	login_service.Init()
+
+	system_controller := new(SystemController)
+	system_controller.Init()
+
+	login_data := make(map[string]interface{})
+	login_data["name"] = "github"
+	login_data["url"] = "https://github.com"
+	login_data["accounts"] = []interface{}{map[string]interface{}{"username": "abc", "password": "123456"}}
+	login_data["attributes"] = map[string]interface{}{"is_favourite": false, "require_master_password": false}
+
+	err := login_service.AddLoginData(login_data)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	//Range file for SHA-1 of "123456"
+	corpus_path := t.TempDir()
+	os.WriteFile(filepath.Join(corpus_path, "7C4A8.txt"), []byte("D09CA3762AF61E59520943DC26494F8941B:37359195\r\n"), 0644)
+
+	server := gin.Default()
+	server.POST("/system/breach_check", system_controller.BreachCheck)
+
+	request_body, _ := json.Marshal(map[string]string{"path": corpus_path})
+
+	test := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/system/breach_check", bytes.NewBuffer(request_body))
+	server.ServeHTTP(test, req)
+
+	if test.Code != http.StatusOK {
+		t.Errorf("Expected: %d\nActual: %d", http.StatusOK, test.Code)
+	}
+
+	var report models.BreachReport
+	err = json.Unmarshal(test.Body.Bytes(), &report)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if report.CheckedCount != 1 || len(report.Accounts) != 1 || report.Accounts[0].BreachCount != 37359195 {
+		t.Errorf("Incorrect report %+v", report)
+	}
+
+	if strings.Contains(test.Body.String(), "123456\"") {
+		t.Error("Breach check response should not contain passwords")
+	}
+
+	t.Cleanup(system_controller_test_cleanup)
+}
+
+func TestImportExternal_DryRun(t *testing.T) {
+	master_password_service := new(services.MasterPasswordService)
+	master_password_service.Init()
+	master_password_service.SetMasterPassword("12345")
+
+	system_controller := new(SystemController)
+	system_controller.Init()
+
+	file_path := filepath.Join(t.TempDir(), "chrome_passwords.csv")
+	os.WriteFile(file_path, []byte("name,url,username,password\ngithub.com,https://github.com,abc,123\ngithub.com,https://github.com,abc,456\n"), 0644)
+
+	server := gin.Default()
+	server.POST("/system/import/external", system_controller.ImportExternal)
+
+	request_body, _ := json.Marshal(map[string]interface{}{"format": "CHROME_CSV", "path": file_path, "dry_run": true})
+
+	test := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/system/import/external", bytes.NewBuffer(request_body))
+	server.ServeHTTP(test, req)
+
+	if test.Code != http.StatusOK {
+		t.Errorf("Expected: %d\nActual: %d", http.StatusOK, test.Code)
+	}
+
+	var report models.ImportReport
+	err := json.Unmarshal(test.Body.Bytes(), &report)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if !report.IsDryRun || len(report.Logins) != 1 || len(report.Conflicts) != 1 || strings.Contains(test.Body.String(), "456") {
+		t.Errorf("Incorrect report %s", test.Body.String())
+	}
+
+	request_body, _ = json.Marshal(map[string]interface{}{"format": "LASTPASS", "path": file_path, "dry_run": true})
+
+	test = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/system/import/external", bytes.NewBuffer(request_body))
+	server.ServeHTTP(test, req)
+
+	if test.Code != http.StatusBadRequest {
+		t.Errorf("Expected: %d\nActual: %d", http.StatusBadRequest, test.Code)
+	}
+
+	t.Cleanup(system_controller_test_cleanup)
+}
+
+// Send request with session token and optional elevated token
+func authorizedRequest(server *gin.Engine, method string, path string, token string, elevated_token string, body []byte) *httptest.ResponseRecorder {
+	test := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	if elevated_token != "" {
+		req.Header.Set(jwt.ELEVATED_TOKEN_HEADER, elevated_token)
+	}
+
+	server.ServeHTTP(test, req)
+	return test
+}
+
+// Get elevated token for the entry using POST /system/reauth
+func reauthenticate(t *testing.T, server *gin.Engine, token string, entry_type string, entry_id string) string {
+	body, _ := json.Marshal(map[string]string{"master_password": "12345", "type": entry_type, "id": entry_id})
+	test := authorizedRequest(server, "POST", "/system/reauth", token, "", body)
+
+	var elevated_token string
+	json.Unmarshal(test.Body.Bytes(), &elevated_token)
+
+	if test.Code != 200 {
+		t.Fatalf("Reauth failed %d %s", test.Code, elevated_token)
+	}
+
+	return elevated_token
+}
+
+// Get jti of the token using GET /system/sessions
+func currentSessionID(server *gin.Engine, token string) string {
+	var sessions []models.Session
+	json.Unmarshal(authorizedRequest(server, "GET", "/system/sessions", token, "", nil).Body.Bytes(), &sessions)
+
+	for _, session := range sessions {
+		if session.IsCurrent {
+			return session.ID
+		}
+	}
+
+	return ""
+}
+
+func TestReauthenticate_IncorrectPassword(t *testing.T) {
+	master_password_service := new(services.MasterPasswordService)
+	master_password_service.Init()
+	master_password_service.SetMasterPassword("12345")
+
+	system_controller := new(SystemController)
+	system_controller.Init()
+
+	server := gin.Default()
+	system_controller.RegisterRoutes(server.Group(""))
+
+	token, _ := jwt.GenerateToken(5)
+
+	body, _ := json.Marshal(map[string]string{"master_password": "123", "type": jwt.LOGIN_SCOPE, "id": "github"})
+	if test := authorizedRequest(server, "POST", "/system/reauth", token, "", body); test.Code != http.StatusBadRequest {
+		t.Errorf("Expected: %d\nActual: %d", http.StatusBadRequest, test.Code)
+	}
+
+	body, _ = json.Marshal(map[string]string{"master_password": "12345", "type": "SYSTEM", "id": "github"})
+	if test := authorizedRequest(server, "POST", "/system/reauth", token, "", body); test.Code != http.StatusBadRequest {
+		t.Errorf("Invalid type\nExpected: %d\nActual: %d", http.StatusBadRequest, test.Code)
+	}
+
+	t.Cleanup(system_controller_test_cleanup)
+}
+
 func system_controller_test_cleanup() {
+	database.Close()
 	os.RemoveAll(os.Getenv("STORAGE_FOLDER"))
 }
@@ -1,54 +1,86 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"ncrypt/controllers"
+	"ncrypt/services"
 	"ncrypt/utils"
+	"ncrypt/utils/database"
 	"ncrypt/utils/logger"
 	"net/http"
 	"os"
-	"sort"
-	"strings"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
-func deleteOldLogs() {
-	path := "logs"
+// Time allowed to drain requests, take the final backup and close databases before exiting anyway
+const SHUTDOWN_TIMEOUT = 30 * time.Second
 
-	//Always keep 5 most recent logs
-	LOGS_TO_KEEP := 5
+/*
+Stop the server within SHUTDOWN_TIMEOUT.
 
-	// Get list of all files
-	files, _ := os.ReadDir(path)
+In-flight requests are drained before the user is logged out, a final backup is taken and database handles and the log file are
+closed. Returns false if shutdown timed out or failed.
+*/
+func shutdown(http_server *http.Server, backup_scheduler *services.BackupScheduler) bool {
+	logger.Log.Printf("Shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
 
-	// Sort files based on modified time in descending order
-	sort.Slice(files, func(i, j int) bool {
-		info1, _ := files[i].Info()
-		info2, _ := files[j].Info()
+	result := make(chan bool, 1)
 
-		return info1.ModTime().After(info2.ModTime())
-	})
+	go func() {
+		is_successful := true
+
+		if err := http_server.Shutdown(ctx); err != nil {
+			logger.Log.Printf("ERROR: %s", err.Error())
+			is_successful = false
+		}
+
+		backup_scheduler.Stop()
+
+		system_service := new(services.SystemService)
+		system_service.InitHeadless()
+
+		if err := system_service.Shutdown(); err != nil {
+			logger.Log.Printf("ERROR: %s", err.Error())
+			is_successful = false
+		}
 
-	count := 0
-	for _, file := range files {
-		if count >= LOGS_TO_KEEP {
-			// Delete files with .log type
-			if !file.IsDir() && strings.HasSuffix(file.Name(), ".log") {
-				err := os.Remove(path + "/" + file.Name())
-				if err != nil {
-					fmt.Println(err.Error())
-				}
-			}
+		if err := database.Close(); err != nil {
+			logger.Log.Printf("ERROR: %s", err.Error())
+			is_successful = false
 		}
 
-		count += 1
+		result <- is_successful
+	}()
+
+	is_successful := false
+	select {
+	case is_successful = <-result:
+		logger.Log.Printf("Shut down")
+	case <-ctx.Done():
+		logger.Log.Printf("ERROR: shutdown timed out")
 	}
+
+	logger.Close()
+	return is_successful
 }
 
 func main() {
+	headless := flag.Bool("headless", false, "run as a server without launching the UI")
+	port := flag.Int("port", 0, "port to listen on, assigned dynamically if 0")
+	flag.Parse()
+
 	fmt.Println("Welcome to Ncrpyt")
 
 	log.SetFlags(log.Lshortfile | log.LstdFlags)
@@ -56,14 +88,40 @@ func main() {
 	//Loading env
 	godotenv.Load(".env")
 
-	deleteOldLogs()
+	//Level, format and rotation of logs can be set in .env
+	if err := logger.Configure(); err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+	}
+
+	if *port != 0 {
+		utils.PORT = strconv.Itoa(*port)
+	} else {
+		utils.AssignDynamicPort()
+	}
 
-	utils.AssignDynamicPort()
+	//UI is launched by system service on init unless running headless
+	ui_launcher := services.IUILauncher(services.InitHeadlessUILauncher())
+	if !*headless {
+		if os.Getenv("UI_EXECUTABLE_PATH") == "" {
+			logger.Log.Printf("UI_EXECUTABLE_PATH not set, running headless")
+		} else {
+			ui_launcher = services.InitExecutableUILauncher(os.Getenv("UI_EXECUTABLE_PATH"))
+		}
+	}
+	services.SetUILauncher(ui_launcher)
 
-	gin.DefaultWriter = logger.Log.Writer()
+	//Complete any transaction interrupted by a crash
+	err := database.Recover()
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+	}
+
+	gin.DefaultWriter = logger.Writer(slog.LevelDebug)
+	gin.DefaultErrorWriter = logger.Writer(slog.LevelError)
 
 	//web server
-	server := gin.Default()
+	server := gin.New()
+	server.Use(logger.RequestLogger(), gin.Recovery())
 
 	server.GET("/ping", func(ctx *gin.Context) {
 		ctx.JSON(http.StatusOK, "pong")
@@ -87,10 +145,40 @@ func main() {
 	master_password_controller.Init()
 	master_password_controller.RegisterRoutes(base_path)
 
+	search_controller := new(controllers.SearchController)
+	search_controller.Init()
+	search_controller.RegisterRoutes(base_path)
+
+	//Automatic backups on interval or after changes
+	backup_scheduler := services.InitBackupScheduler()
+	backup_scheduler.Init()
+	backup_scheduler.Start()
+
+	http_server := &http.Server{Addr: ":" + utils.PORT, Handler: server}
+	server_error := make(chan error, 1)
+
 	go func() {
-		defer logger.Close()
+		logger.Log.Printf("Starting server on %s", utils.PORT)
+		if err := http_server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			server_error <- err
+		}
 	}()
 
-	logger.Log.Printf("Starting server on %s", utils.PORT)
-	server.Run(":" + utils.PORT)
+	signal_context, stop_signals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case <-signal_context.Done():
+		logger.Log.Printf("Received shutdown signal")
+	case <-ui_launcher.Closed():
+		logger.Log.Printf("UI closed")
+	case err := <-server_error:
+		logger.Log.Printf("ERROR: %s", err.Error())
+	}
+
+	// A second signal kills the process right away
+	stop_signals()
+
+	if !shutdown(http_server, backup_scheduler) {
+		os.Exit(1)
+	}
 }
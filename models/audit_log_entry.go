@@ -0,0 +1,34 @@
+package models
+
+/*
+Entry of the audit log. Never holds secret values.
+
+Hash is computed over all other fields including PreviousHash, chaining every entry to the one before it.
+*/
+type AuditLogEntry struct {
+	Sequence     int    `json:"sequence"`
+	DateTime     string `json:"date_time"`
+	EventType    string `json:"event_type"`
+	Target       string `json:"target"` // Login name, note created date time or file path the event is about
+	Detail       string `json:"detail"`
+	PreviousHash string `json:"previous_hash"`
+	Hash         string `json:"hash"`
+}
+
+func (obj *AuditLogEntry) FromMap(data map[string]interface{}) {
+	obj.Sequence = int(data["sequence"].(float64))
+	obj.DateTime = data["date_time"].(string)
+	obj.EventType = data["event_type"].(string)
+	obj.Target = data["target"].(string)
+	obj.Detail = data["detail"].(string)
+	obj.PreviousHash = data["previous_hash"].(string)
+	obj.Hash = data["hash"].(string)
+}
+
+// Result of checking the audit log hash chain. FirstInvalidSequence is 0 if the log is valid
+type AuditLogVerification struct {
+	IsValid              bool     `json:"is_valid"`
+	EntryCount           int      `json:"entry_count"`
+	FirstInvalidSequence int      `json:"first_invalid_sequence"`
+	Errors               []string `json:"errors"`
+}
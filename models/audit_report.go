@@ -0,0 +1,27 @@
+package models
+
+// Audit result of a single account. Never contains the password itself
+type AccountAudit struct {
+	Name                    string  `json:"name"`
+	Username                string  `json:"username"`
+	Score                   int     `json:"score"`
+	EntropyBits             float64 `json:"entropy_bits"`
+	IsWeak                  bool    `json:"is_weak"`
+	IsReused                bool    `json:"is_reused"`
+	IsOld                   bool    `json:"is_old"`
+	PasswordUpdatedDateTime string  `json:"password_updated_date_time"`
+}
+
+type AccountReference struct {
+	Name     string `json:"name"`
+	Username string `json:"username"`
+}
+
+type AuditReport struct {
+	Accounts     []AccountAudit       `json:"accounts"`
+	ReusedGroups [][]AccountReference `json:"reused_groups"`
+	WeakCount    int                  `json:"weak_count"`
+	ReusedCount  int                  `json:"reused_count"`
+	OldCount     int                  `json:"old_count"`
+	MaxAgeInDays int                  `json:"max_age_in_days"`
+}
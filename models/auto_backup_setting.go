@@ -1,9 +1,33 @@
 package models
 
 type AutoBackupSetting struct {
-	IsEnabled      bool   `json:"is_enabled" bson:"is_enabled"`
-	BackupLocation string `json:"backup_location" bson:"backup_location"`
-	BackupFileName string `json:"backup_file_name" bson:"backup_file_name"`
+	IsEnabled         bool            `json:"is_enabled" bson:"is_enabled"`
+	BackupLocation    string          `json:"backup_location" bson:"backup_location"`
+	BackupFileName    string          `json:"backup_file_name" bson:"backup_file_name"`
+	IntervalInMinutes int             `json:"interval_in_minutes" bson:"interval_in_minutes"` // 0 - no scheduled backups
+	AfterChanges      int             `json:"after_changes" bson:"after_changes"`             // 0 - changes do not trigger a backup
+	Retention         BackupRetention `json:"retention" bson:"retention"`
+	VerifyAfterBackup bool            `json:"verify_after_backup" bson:"verify_after_backup"`
+}
+
+/*
+Which backups to keep, all rules are applied together. A backup kept by any rule is not deleted.
+
+If no rule is set, backups are never deleted.
+*/
+type BackupRetention struct {
+	KeepLast           int `json:"keep_last" bson:"keep_last"`                         // Most recent backups
+	KeepDailyForDays   int `json:"keep_daily_for_days" bson:"keep_daily_for_days"`     // Latest backup of each day
+	KeepWeeklyForWeeks int `json:"keep_weekly_for_weeks" bson:"keep_weekly_for_weeks"` // Latest backup of each week
+}
+
+// Outcome of the last backup, manual or scheduled
+type BackupStatus struct {
+	DateTime     string `json:"date_time" bson:"date_time"`
+	FileName     string `json:"file_name" bson:"file_name"`
+	IsSuccessful bool   `json:"is_successful" bson:"is_successful"`
+	IsVerified   bool   `json:"is_verified" bson:"is_verified"`
+	Error        string `json:"error,omitempty" bson:"error"`
 }
 
 func (obj *AutoBackupSetting) FromMap(data map[string]interface{}) *AutoBackupSetting {
@@ -11,5 +35,41 @@ func (obj *AutoBackupSetting) FromMap(data map[string]interface{}) *AutoBackupSe
 	obj.BackupLocation = data["backup_location"].(string)
 	obj.BackupFileName = data["backup_file_name"].(string)
 
+	// Settings saved before scheduled backups were introduced
+	if interval_in_minutes, ok := data["interval_in_minutes"].(float64); ok {
+		obj.IntervalInMinutes = int(interval_in_minutes)
+	}
+	if after_changes, ok := data["after_changes"].(float64); ok {
+		obj.AfterChanges = int(after_changes)
+	}
+	if retention, ok := data["retention"].(map[string]interface{}); ok {
+		obj.Retention.FromMap(retention)
+	}
+	obj.VerifyAfterBackup, _ = data["verify_after_backup"].(bool)
+
+	return obj
+}
+
+func (obj *BackupRetention) FromMap(data map[string]interface{}) *BackupRetention {
+	if keep_last, ok := data["keep_last"].(float64); ok {
+		obj.KeepLast = int(keep_last)
+	}
+	if keep_daily_for_days, ok := data["keep_daily_for_days"].(float64); ok {
+		obj.KeepDailyForDays = int(keep_daily_for_days)
+	}
+	if keep_weekly_for_weeks, ok := data["keep_weekly_for_weeks"].(float64); ok {
+		obj.KeepWeeklyForWeeks = int(keep_weekly_for_weeks)
+	}
+
+	return obj
+}
+
+func (obj *BackupStatus) FromMap(data map[string]interface{}) *BackupStatus {
+	obj.DateTime, _ = data["date_time"].(string)
+	obj.FileName, _ = data["file_name"].(string)
+	obj.IsSuccessful, _ = data["is_successful"].(bool)
+	obj.IsVerified, _ = data["is_verified"].(bool)
+	obj.Error, _ = data["error"].(string)
+
 	return obj
 }
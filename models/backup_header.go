@@ -0,0 +1,20 @@
+package models
+
+// Argon2id parameters used to derive the backup key from the master password
+type BackupKDF struct {
+	Algorithm   string `json:"algorithm"`
+	Memory      uint32 `json:"memory"`
+	Iterations  uint32 `json:"iterations"`
+	Parallelism uint8  `json:"parallelism"`
+	Salt        []byte `json:"salt"`
+}
+
+// Cleartext metadata of a .ncrypt backup. Files written before the versioned container only have FormatVersion set
+type BackupHeader struct {
+	FormatVersion   int       `json:"format_version"`
+	Cipher          string    `json:"cipher,omitempty"`
+	KDF             BackupKDF `json:"kdf"`
+	CreatedDateTime string    `json:"created_date_time,omitempty"`
+	LoginCount      int       `json:"login_count"`
+	NoteCount       int       `json:"note_count"`
+}
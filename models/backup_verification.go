@@ -0,0 +1,20 @@
+package models
+
+// Names of logins or created date times of notes that differ between a backup and the vault
+type BackupDiff struct {
+	OnlyInBackup []string `json:"only_in_backup"`
+	OnlyInVault  []string `json:"only_in_vault"`
+	Different    []string `json:"different"`
+}
+
+// Result of restoring a backup into an isolated store. IsValid is false if any login or note could not be restored
+type BackupVerification struct {
+	Header              BackupHeader `json:"header"`
+	IsValid             bool         `json:"is_valid"`
+	LoginCount          int          `json:"login_count"`
+	NoteCount           int          `json:"note_count"`
+	Errors              []string     `json:"errors"`
+	IsComparedWithVault bool         `json:"is_compared_with_vault"` // Vault has to be unlocked to compare
+	Logins              BackupDiff   `json:"logins"`
+	Notes               BackupDiff   `json:"notes"`
+}
@@ -0,0 +1,12 @@
+package models
+
+type BreachedAccount struct {
+	Name        string `json:"name"`
+	Username    string `json:"username"`
+	BreachCount int    `json:"breach_count"`
+}
+
+type BreachReport struct {
+	Accounts     []BreachedAccount `json:"accounts"`
+	CheckedCount int               `json:"checked_count"`
+}
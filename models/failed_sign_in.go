@@ -0,0 +1,52 @@
+package models
+
+// Failed master password attempts, most recent first. Only the latest MAX_FAILED_SIGN_IN_RECORDS times are kept
+type FailedSignInRecord struct {
+	Count     int      `json:"count" bson:"count"`
+	DateTimes []string `json:"date_times" bson:"date_times"`
+}
+
+const MAX_FAILED_SIGN_IN_RECORDS = 20
+
+/*
+Failed master password attempts. Persisted so restarting the app does not reset the lockout.
+
+ConsecutiveCount is reset by any correct master password and decides the lockout, SinceLastLogin is only reset by a successful
+sign in, when it is moved to SystemData.FailedSignInsBeforeLogin to be shown to the user.
+*/
+type FailedSignIn struct {
+	ConsecutiveCount    int                `json:"consecutive_count" bson:"consecutive_count"`
+	LockedUntilDateTime string             `json:"locked_until_date_time" bson:"locked_until_date_time"`
+	SinceLastLogin      FailedSignInRecord `json:"since_last_login" bson:"since_last_login"`
+}
+
+func (obj *FailedSignInRecord) FromMap(data map[string]interface{}) *FailedSignInRecord {
+	obj.Count = int(data["count"].(float64))
+
+	obj.DateTimes = nil
+	if date_times, ok := data["date_times"].([]interface{}); ok {
+		for _, date_time := range date_times {
+			obj.DateTimes = append(obj.DateTimes, date_time.(string))
+		}
+	}
+
+	return obj
+}
+
+func (obj *FailedSignIn) FromMap(data map[string]interface{}) *FailedSignIn {
+	obj.ConsecutiveCount = int(data["consecutive_count"].(float64))
+	obj.LockedUntilDateTime = data["locked_until_date_time"].(string)
+	obj.SinceLastLogin.FromMap(data["since_last_login"].(map[string]interface{}))
+
+	return obj
+}
+
+// Add a failed attempt at the given time
+func (obj *FailedSignInRecord) Add(date_time string) {
+	obj.Count += 1
+	obj.DateTimes = append([]string{date_time}, obj.DateTimes...)
+
+	if len(obj.DateTimes) > MAX_FAILED_SIGN_IN_RECORDS {
+		obj.DateTimes = obj.DateTimes[:MAX_FAILED_SIGN_IN_RECORDS]
+	}
+}
@@ -0,0 +1,26 @@
+package models
+
+// Entry of an external export that was not imported as is
+type ImportIssue struct {
+	Row      int    `json:"row"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+	Reason   string `json:"reason"`
+}
+
+type ImportedLogin struct {
+	Name      string   `json:"name"`
+	URL       string   `json:"url"`
+	Usernames []string `json:"usernames"`
+	IsMerged  bool     `json:"is_merged"` // Accounts are added to a login already in the vault
+}
+
+// Result of importing from another password manager. Never contains passwords
+type ImportReport struct {
+	Format    string          `json:"format"`
+	IsDryRun  bool            `json:"is_dry_run"`
+	Logins    []ImportedLogin `json:"logins"`
+	Notes     []string        `json:"notes"`
+	Conflicts []ImportIssue   `json:"conflicts"`
+	Skipped   []ImportIssue   `json:"skipped"`
+}
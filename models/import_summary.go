@@ -0,0 +1,30 @@
+package models
+
+// Strategies for importing a .ncrypt export. Except REPLACE, data is merged into the existing vault and the strategy decides
+// what happens to logins and notes that exist in both
+const (
+	IMPORT_REPLACE       = "REPLACE"
+	IMPORT_KEEP_EXISTING = "KEEP_EXISTING"
+	IMPORT_OVERWRITE     = "OVERWRITE"
+	IMPORT_KEEP_BOTH     = "KEEP_BOTH"
+)
+
+type ImportRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Names of logins or created date times of notes affected by an import
+type ImportChanges struct {
+	Added       []string       `json:"added"`
+	Overwritten []string       `json:"overwritten"`
+	Renamed     []ImportRename `json:"renamed"`
+	Skipped     []string       `json:"skipped"`
+	Removed     []string       `json:"removed"`
+}
+
+type ImportSummary struct {
+	Strategy string        `json:"strategy"`
+	Logins   ImportChanges `json:"logins"`
+	Notes    ImportChanges `json:"notes"`
+}
@@ -1,13 +1,32 @@
 package models
 
 type Account struct {
-	Username string `json:"username" bson:"username"`
-	Password string `json:"password" bson:"password"`
+	Username                string      `json:"username" bson:"username"`
+	Password                string      `json:"password" bson:"password"`
+	TOTPSecret              *TOTPSecret `json:"totp_secret,omitempty" bson:"totp_secret,omitempty"`
+	PasswordUpdatedDateTime string      `json:"password_updated_date_time" bson:"password_updated_date_time"`
+	// Most recent first
+	PasswordHistory []PasswordHistoryEntry `json:"password_history,omitempty" bson:"password_history,omitempty"`
 }
 
 func (obj *Account) fromMap(data map[string]interface{}) *Account {
 	obj.Username = data["username"].(string)
 	obj.Password = data["password"].(string)
 
+	// Not set for accounts created before password age was tracked
+	if password_updated_date_time, exists := data["password_updated_date_time"]; exists {
+		obj.PasswordUpdatedDateTime = password_updated_date_time.(string)
+	}
+
+	if password_history, exists := data["password_history"]; exists && password_history != nil {
+		for _, entry := range password_history.([]interface{}) {
+			obj.PasswordHistory = append(obj.PasswordHistory, *new(PasswordHistoryEntry).fromMap(entry.(map[string]interface{})))
+		}
+	}
+
+	if totp_secret, exists := data["totp_secret"]; exists && totp_secret != nil {
+		obj.TOTPSecret = new(TOTPSecret).fromMap(totp_secret.(map[string]interface{}))
+	}
+
 	return obj
 }
\ No newline at end of file
@@ -0,0 +1,78 @@
+package models
+
+type AccountPatch struct {
+	Username         string
+	NewUsername      string
+	Password         string
+	TOTPSecret       *TOTPSecret
+	RemoveTOTPSecret bool
+}
+
+func (obj *AccountPatch) fromMap(data map[string]interface{}) *AccountPatch {
+	obj.Username = data["username"].(string)
+
+	if new_username, exists := data["new_username"]; exists {
+		obj.NewUsername = new_username.(string)
+	}
+	if password, exists := data["password"]; exists {
+		obj.Password = password.(string)
+	}
+	if totp_secret, exists := data["totp_secret"]; exists && totp_secret != nil {
+		obj.TOTPSecret = new(TOTPSecret).fromMap(totp_secret.(map[string]interface{}))
+	}
+	if remove_totp_secret, exists := data["remove_totp_secret"]; exists {
+		obj.RemoveTOTPSecret = remove_totp_secret.(bool)
+	}
+
+	return obj
+}
+
+// Partial update of a login. Fields that are not set are left unchanged
+type LoginPatch struct {
+	URL                   *string
+	IsFavourite           *bool
+	RequireMasterPassword *bool
+	AddAccounts           []Account
+	UpdateAccounts        []AccountPatch
+	RemoveAccounts        []string
+}
+
+func (obj *LoginPatch) FromMap(data map[string]interface{}) *LoginPatch {
+	if url, exists := data["url"]; exists {
+		url := url.(string)
+		obj.URL = &url
+	}
+
+	if attributes, exists := data["attributes"]; exists {
+		attributes := attributes.(map[string]interface{})
+
+		if is_favourite, exists := attributes["is_favourite"]; exists {
+			is_favourite := is_favourite.(bool)
+			obj.IsFavourite = &is_favourite
+		}
+		if require_master_password, exists := attributes["require_master_password"]; exists {
+			require_master_password := require_master_password.(bool)
+			obj.RequireMasterPassword = &require_master_password
+		}
+	}
+
+	if account_data_list, exists := data["add_accounts"]; exists {
+		for _, account_data := range account_data_list.([]interface{}) {
+			obj.AddAccounts = append(obj.AddAccounts, *new(Account).fromMap(account_data.(map[string]interface{})))
+		}
+	}
+
+	if account_data_list, exists := data["update_accounts"]; exists {
+		for _, account_data := range account_data_list.([]interface{}) {
+			obj.UpdateAccounts = append(obj.UpdateAccounts, *new(AccountPatch).fromMap(account_data.(map[string]interface{})))
+		}
+	}
+
+	if username_list, exists := data["remove_accounts"]; exists {
+		for _, username := range username_list.([]interface{}) {
+			obj.RemoveAccounts = append(obj.RemoveAccounts, username.(string))
+		}
+	}
+
+	return obj
+}
@@ -1,17 +1,89 @@
 package models
 
+const (
+	DEFAULT_SPECIAL_CHAR_SET = "!@#$%^&*"
+	DEFAULT_WORD_COUNT       = 6
+	DEFAULT_WORD_SEPARATOR   = "-"
+
+	PASSWORD_MODE   = "PASSWORD"   // Random characters
+	PASSPHRASE_MODE = "PASSPHRASE" // Random words from a wordlist
+)
+
 type PasswordGeneratorPreference struct {
-	HasDigits      bool `json:"has_digits" bson:"has_digits"`
-	HasUpperCase   bool `json:"has_uppercase" bson:"has_uppercase"`
-	HasSpecialChar bool `json:"has_special_char" bson:"has_special_char"`
-	Length         int  `json:"length" bson:"length"`
+	Mode             string `json:"mode" bson:"mode"`
+	HasDigits        bool   `json:"has_digits" bson:"has_digits"`
+	HasUpperCase     bool   `json:"has_uppercase" bson:"has_uppercase"`
+	HasSpecialChar   bool   `json:"has_special_char" bson:"has_special_char"`
+	Length           int    `json:"length" bson:"length"`
+	MinLowerCase     int    `json:"min_lowercase" bson:"min_lowercase"`
+	MinDigits        int    `json:"min_digits" bson:"min_digits"`
+	MinUpperCase     int    `json:"min_uppercase" bson:"min_uppercase"`
+	MinSpecialChar   int    `json:"min_special_char" bson:"min_special_char"`
+	SpecialCharSet   string `json:"special_char_set" bson:"special_char_set"`
+	ExcludeAmbiguous bool   `json:"exclude_ambiguous" bson:"exclude_ambiguous"`
+	WordCount        int    `json:"word_count" bson:"word_count"`
+	WordSeparator    string `json:"word_separator" bson:"word_separator"`
+	CapitalizeWords  bool   `json:"capitalize_words" bson:"capitalize_words"`
+	IncludeDigit     bool   `json:"include_digit" bson:"include_digit"`
 }
 
+// Fields added after the initial version are optional so that older preferences can still be read
 func (obj *PasswordGeneratorPreference) FromMap(data map[string]interface{}) *PasswordGeneratorPreference {
+	obj.Mode = PASSWORD_MODE
+	if mode, exists := data["mode"]; exists && mode.(string) != "" {
+		obj.Mode = mode.(string)
+	}
+
 	obj.HasDigits = data["has_digits"].(bool)
 	obj.HasUpperCase = data["has_uppercase"].(bool)
 	obj.HasSpecialChar = data["has_special_char"].(bool)
 	obj.Length = (int)(data["length"].(float64))
 
+	if min_lowercase, exists := data["min_lowercase"]; exists {
+		obj.MinLowerCase = (int)(min_lowercase.(float64))
+	}
+	if min_digits, exists := data["min_digits"]; exists {
+		obj.MinDigits = (int)(min_digits.(float64))
+	}
+	if min_uppercase, exists := data["min_uppercase"]; exists {
+		obj.MinUpperCase = (int)(min_uppercase.(float64))
+	}
+	if min_special_char, exists := data["min_special_char"]; exists {
+		obj.MinSpecialChar = (int)(min_special_char.(float64))
+	}
+
+	obj.SpecialCharSet = DEFAULT_SPECIAL_CHAR_SET
+	if special_char_set, exists := data["special_char_set"]; exists && special_char_set.(string) != "" {
+		obj.SpecialCharSet = special_char_set.(string)
+	}
+
+	// Ambiguous characters were always excluded before this was configurable
+	obj.ExcludeAmbiguous = true
+	if exclude_ambiguous, exists := data["exclude_ambiguous"]; exists {
+		obj.ExcludeAmbiguous = exclude_ambiguous.(bool)
+	}
+
+	obj.WordCount = DEFAULT_WORD_COUNT
+	if word_count, exists := data["word_count"]; exists {
+		obj.WordCount = (int)(word_count.(float64))
+	}
+
+	obj.WordSeparator = DEFAULT_WORD_SEPARATOR
+	if word_separator, exists := data["word_separator"]; exists {
+		obj.WordSeparator = word_separator.(string)
+	}
+
+	if capitalize_words, exists := data["capitalize_words"]; exists {
+		obj.CapitalizeWords = capitalize_words.(bool)
+	}
+	if include_digit, exists := data["include_digit"]; exists {
+		obj.IncludeDigit = include_digit.(bool)
+	}
+
 	return obj
 }
+
+type GeneratedPassword struct {
+	Password    string  `json:"password"`
+	EntropyBits float64 `json:"entropy_bits"`
+}
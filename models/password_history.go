@@ -0,0 +1,23 @@
+package models
+
+// Previous password of an account. Password is encrypted just like the account password
+type PasswordHistoryEntry struct {
+	Password         string `json:"password" bson:"password"`
+	CreatedDateTime  string `json:"created_date_time" bson:"created_date_time"`
+	ReplacedDateTime string `json:"replaced_date_time" bson:"replaced_date_time"`
+}
+
+func (obj *PasswordHistoryEntry) fromMap(data map[string]interface{}) *PasswordHistoryEntry {
+	obj.Password = data["password"].(string)
+	obj.CreatedDateTime = data["created_date_time"].(string)
+	obj.ReplacedDateTime = data["replaced_date_time"].(string)
+
+	return obj
+}
+
+// History entry as listed to clients, without the password
+type PasswordHistoryDate struct {
+	Index            int    `json:"index"`
+	CreatedDateTime  string `json:"created_date_time"`
+	ReplacedDateTime string `json:"replaced_date_time"`
+}
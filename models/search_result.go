@@ -0,0 +1,32 @@
+package models
+
+// Range of matched characters (runes) in a value, End is exclusive
+type TextRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Field of a login or note that matched the query. Value of note content is a snippet around the first match
+type SearchMatch struct {
+	Field  string      `json:"field"`
+	Value  string      `json:"value"`
+	Ranges []TextRange `json:"ranges"`
+}
+
+// Login (ID is name) or note (ID is created date time) matching the query
+type SearchResult struct {
+	Type        string        `json:"type"`
+	ID          string        `json:"id"`
+	Title       string        `json:"title"`
+	IsFavourite bool          `json:"is_favourite"`
+	Score       float64       `json:"score"`
+	Matches     []SearchMatch `json:"matches"`
+}
+
+type SearchPage struct {
+	Query    string         `json:"query"`
+	Total    int            `json:"total"`
+	Page     int            `json:"page"`
+	PageSize int            `json:"page_size"`
+	Results  []SearchResult `json:"results"`
+}
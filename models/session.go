@@ -0,0 +1,12 @@
+package models
+
+// Signed in session of the API. Times are RFC3339
+type Session struct {
+	ID               string `json:"id"`
+	IssuedDateTime   string `json:"issued_date_time"`
+	ExpiryDateTime   string `json:"expiry_date_time"`
+	LastUsedDateTime string `json:"last_used_date_time"`
+	ClientIP         string `json:"client_ip"`
+	UserAgent        string `json:"user_agent"`
+	IsCurrent        bool   `json:"is_current"`
+}
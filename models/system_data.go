@@ -7,8 +7,12 @@ type SystemData struct {
 	CurrentLoginDateTime        string                      `json:"current_login_date_time" bson:"current_login_date_time"`
 	SessionDurationInMinutes    int                         `json:"session_duration_in_minutes" bson:"session_duration_in_minutes"`
 	AutoBackupSetting           AutoBackupSetting           `json:"auto_backup_setting" bson:"auto_backup_setting"`
+	LastBackup                  BackupStatus                `json:"last_backup" bson:"last_backup"`
 	PasswordGeneratorPreference PasswordGeneratorPreference `json:"password_generator_preference" bson:"password_generator_preference"`
 	Theme                       string                      `json:"theme" bson:"theme"`
+	FailedSignIn                FailedSignIn                `json:"failed_sign_in" bson:"failed_sign_in"`
+	FailedSignInsBeforeLogin    FailedSignInRecord          `json:"failed_sign_ins_before_login" bson:"failed_sign_ins_before_login"` // Failed attempts before the current sign in
+	WipeAfterFailedAttempts     int                         `json:"wipe_after_failed_attempts" bson:"wipe_after_failed_attempts"`     // 0 - never wipe
 }
 
 func (obj *SystemData) FromMap(data map[string]interface{}) *SystemData {
@@ -17,9 +21,23 @@ func (obj *SystemData) FromMap(data map[string]interface{}) *SystemData {
 	obj.IsLoggedIn = data["is_logged_in"].(bool)
 	obj.CurrentLoginDateTime = data["current_login_date_time"].(string)
 	obj.AutoBackupSetting = *new(AutoBackupSetting).FromMap(data["auto_backup_setting"].(map[string]interface{}))
+	if last_backup, ok := data["last_backup"].(map[string]interface{}); ok {
+		obj.LastBackup.FromMap(last_backup)
+	}
 	obj.SessionDurationInMinutes = int(data["session_duration_in_minutes"].(float64))
 	obj.PasswordGeneratorPreference = *new(PasswordGeneratorPreference).FromMap(data["password_generator_preference"].(map[string]interface{}))
 	obj.Theme = data["theme"].(string)
 
+	// Not set for data saved before sign in attempts were limited
+	if failed_sign_in, ok := data["failed_sign_in"].(map[string]interface{}); ok {
+		obj.FailedSignIn.FromMap(failed_sign_in)
+	}
+	if failed_sign_ins_before_login, ok := data["failed_sign_ins_before_login"].(map[string]interface{}); ok {
+		obj.FailedSignInsBeforeLogin.FromMap(failed_sign_ins_before_login)
+	}
+	if wipe_after_failed_attempts, ok := data["wipe_after_failed_attempts"].(float64); ok {
+		obj.WipeAfterFailedAttempts = int(wipe_after_failed_attempts)
+	}
+
 	return obj
 }
@@ -0,0 +1,34 @@
+package models
+
+/*
+Encrypted TOTP (2FA) seed of an account.
+
+When adding or updating, Secret can either be the base32 encoded seed or an otpauth:// URI from which the remaining fields are read.
+*/
+type TOTPSecret struct {
+	Secret    string `json:"secret" bson:"secret"`
+	Digits    int    `json:"digits" bson:"digits"`
+	Period    int    `json:"period" bson:"period"`
+	Algorithm string `json:"algorithm" bson:"algorithm"`
+}
+
+func (obj *TOTPSecret) fromMap(data map[string]interface{}) *TOTPSecret {
+	obj.Secret = data["secret"].(string)
+
+	if digits, exists := data["digits"]; exists {
+		obj.Digits = int(digits.(float64))
+	}
+	if period, exists := data["period"]; exists {
+		obj.Period = int(period.(float64))
+	}
+	if algorithm, exists := data["algorithm"]; exists {
+		obj.Algorithm = algorithm.(string)
+	}
+
+	return obj
+}
+
+type TOTPCode struct {
+	Code             string `json:"code"`
+	RemainingSeconds int    `json:"remaining_seconds"`
+}
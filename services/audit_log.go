@@ -0,0 +1,294 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ncrypt/models"
+	"ncrypt/utils/database"
+	"ncrypt/utils/logger"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Kept apart from the vault databases, so wiping or replacing the vault leaves the audit log intact
+const AUDIT_LOG_DB_NAME = "AUDIT_LOG"
+
+// Key of the latest entry's sequence and hash, used to detect entries removed from the end of the log
+const AUDIT_LOG_HEAD_KEY = "HEAD"
+
+// Audit log event types
+const (
+	AUDIT_SIGN_IN                 = "SIGN_IN"
+	AUDIT_SIGN_IN_FAILED          = "SIGN_IN_FAILED"
+	AUDIT_SIGN_OUT                = "SIGN_OUT"
+	AUDIT_VAULT_WIPED             = "VAULT_WIPED"
+	AUDIT_REVEAL_PASSWORD         = "REVEAL_PASSWORD"
+	AUDIT_REVEAL_NOTE             = "REVEAL_NOTE"
+	AUDIT_REVEAL_TOTP             = "REVEAL_TOTP"
+	AUDIT_LOGIN_CREATED           = "LOGIN_CREATED"
+	AUDIT_LOGIN_UPDATED           = "LOGIN_UPDATED"
+	AUDIT_LOGIN_DELETED           = "LOGIN_DELETED"
+	AUDIT_NOTE_CREATED            = "NOTE_CREATED"
+	AUDIT_NOTE_UPDATED            = "NOTE_UPDATED"
+	AUDIT_NOTE_DELETED            = "NOTE_DELETED"
+	AUDIT_EXPORT                  = "EXPORT"
+	AUDIT_IMPORT                  = "IMPORT"
+	AUDIT_MASTER_PASSWORD_UPDATED = "MASTER_PASSWORD_UPDATED"
+)
+
+// Held while appending, so every entry is chained to the one written right before it
+var audit_log_lock sync.Mutex
+
+type auditLogHead struct {
+	Sequence int    `json:"sequence"`
+	Hash     string `json:"hash"`
+}
+
+func auditLogDatabase() database.IDatabase {
+	audit_log_database := database.InitBadgerDb()
+	audit_log_database.SetDatabase(AUDIT_LOG_DB_NAME)
+	return audit_log_database
+}
+
+func auditLogKey(sequence int) string {
+	return fmt.Sprintf("ENTRY_%020d", sequence)
+}
+
+/*
+Hash of all fields of the entry except Hash itself.
+
+The hash is not keyed, as entries are also written while the vault is locked and the log outlives wiped and replaced vaults.
+Someone with write access to the database can rewrite entries, the chain and the head consistently and verification still
+passes. The chain only detects partial edits, such as a changed, inserted or removed entry.
+*/
+func auditLogHash(entry models.AuditLogEntry) string {
+	data, _ := json.Marshal([]interface{}{entry.Sequence, entry.DateTime, entry.EventType, entry.Target, entry.Detail, entry.PreviousHash})
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func getAuditLogHead(audit_log_database database.IDatabase) (auditLogHead, error) {
+	fetched_data, err := audit_log_database.GetData(AUDIT_LOG_HEAD_KEY)
+
+	if err != nil {
+		return auditLogHead{}, err
+	}
+
+	data := fetched_data.(map[string]interface{})
+	return auditLogHead{Sequence: int(data["sequence"].(float64)), Hash: data["hash"].(string)}, nil
+}
+
+/*
+Append an event to the audit log. Target and detail must not contain secret values.
+
+Failures are only logged, so a broken audit log never blocks the action being audited.
+*/
+func recordAuditEvent(event_type string, target string, detail string) {
+	audit_log_lock.Lock()
+	defer audit_log_lock.Unlock()
+
+	audit_log_database := auditLogDatabase()
+
+	head, err := getAuditLogHead(audit_log_database)
+
+	if err != nil && err != badger.ErrKeyNotFound {
+		logger.Log.Printf("ERROR: audit log: %s", err.Error())
+		return
+	}
+
+	entry := models.AuditLogEntry{
+		Sequence:     head.Sequence + 1,
+		DateTime:     time.Now().Format(time.RFC3339),
+		EventType:    event_type,
+		Target:       target,
+		Detail:       detail,
+		PreviousHash: head.Hash,
+	}
+	entry.Hash = auditLogHash(entry)
+
+	// Entry and head are written together, so a crash cannot leave the head pointing past the last entry
+	transaction := database.BeginBadgerTransaction()
+
+	if err := transaction.AddData(audit_log_database, auditLogKey(entry.Sequence), entry); err != nil {
+		transaction.Rollback()
+		logger.Log.Printf("ERROR: audit log: %s", err.Error())
+		return
+	}
+
+	if err := transaction.AddData(audit_log_database, AUDIT_LOG_HEAD_KEY, auditLogHead{Sequence: entry.Sequence, Hash: entry.Hash}); err != nil {
+		transaction.Rollback()
+		logger.Log.Printf("ERROR: audit log: %s", err.Error())
+		return
+	}
+
+	if err := transaction.Commit(); err != nil {
+		logger.Log.Printf("ERROR: audit log: %s", err.Error())
+	}
+}
+
+// Get all audit log entries ordered by sequence
+func getAuditLogEntries(audit_log_database database.IDatabase) ([]models.AuditLogEntry, error) {
+	result_list, err := audit_log_database.GetAllData()
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]models.AuditLogEntry, 0, len(result_list))
+	for _, result := range result_list {
+		data := result.(map[string]interface{})
+
+		// Skip head
+		if _, ok := data["event_type"]; !ok {
+			continue
+		}
+
+		var entry models.AuditLogEntry
+		entry.FromMap(data)
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Sequence < entries[j].Sequence })
+	return entries, nil
+}
+
+/*
+Get audit log entries, oldest first.
+
+from and to are optional RFC3339 date times, both inclusive. If event types are given only entries of those types are returned.
+*/
+func (obj *SystemService) GetAuditLog(from string, to string, event_types []string) ([]models.AuditLogEntry, error) {
+	logger.Log.Printf("Getting audit log")
+
+	var from_time, to_time time.Time
+	var err error
+
+	if from != "" {
+		if from_time, err = time.Parse(time.RFC3339, from); err != nil {
+			err = errors.New("invalid from date time, expected RFC3339")
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return nil, err
+		}
+	}
+
+	if to != "" {
+		if to_time, err = time.Parse(time.RFC3339, to); err != nil {
+			err = errors.New("invalid to date time, expected RFC3339")
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return nil, err
+		}
+	}
+
+	// Copied, so the caller's slice is left as is
+	upper_event_types := make([]string, len(event_types))
+	for index, event_type := range event_types {
+		upper_event_types[index] = strings.ToUpper(event_type)
+	}
+
+	audit_log_lock.Lock()
+	entries, err := getAuditLogEntries(auditLogDatabase())
+	audit_log_lock.Unlock()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return nil, err
+	}
+
+	filtered_entries := make([]models.AuditLogEntry, 0, len(entries))
+	for _, entry := range entries {
+		if len(upper_event_types) > 0 && !slices.Contains(upper_event_types, entry.EventType) {
+			continue
+		}
+
+		if !from_time.IsZero() || !to_time.IsZero() {
+			date_time, err := time.Parse(time.RFC3339, entry.DateTime)
+
+			if err != nil || (!from_time.IsZero() && date_time.Before(from_time)) || (!to_time.IsZero() && date_time.After(to_time)) {
+				continue
+			}
+		}
+
+		filtered_entries = append(filtered_entries, entry)
+	}
+
+	return filtered_entries, nil
+}
+
+/*
+Check the audit log hash chain.
+
+Detects modified entries, entries removed from anywhere in the log and entries removed from the end, as long as the head was not
+rewritten along with them. Rewriting the whole chain from the first changed entry is not detected, see auditLogHash.
+*/
+func (obj *SystemService) VerifyAuditLog() (models.AuditLogVerification, error) {
+	logger.Log.Printf("Verifying audit log")
+
+	audit_log_lock.Lock()
+	defer audit_log_lock.Unlock()
+
+	audit_log_database := auditLogDatabase()
+
+	entries, err := getAuditLogEntries(audit_log_database)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.AuditLogVerification{}, err
+	}
+
+	head, err := getAuditLogHead(audit_log_database)
+
+	if err != nil && err != badger.ErrKeyNotFound {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.AuditLogVerification{}, err
+	}
+
+	verification := models.AuditLogVerification{EntryCount: len(entries), Errors: []string{}}
+	invalid := func(sequence int, message string) {
+		if verification.FirstInvalidSequence == 0 {
+			verification.FirstInvalidSequence = sequence
+		}
+		verification.Errors = append(verification.Errors, message)
+	}
+
+	previous_hash := ""
+	for index, entry := range entries {
+		expected_sequence := index + 1
+
+		if entry.Sequence != expected_sequence {
+			invalid(expected_sequence, fmt.Sprintf("entry %d is missing", expected_sequence))
+			break
+		}
+
+		if entry.PreviousHash != previous_hash {
+			invalid(entry.Sequence, fmt.Sprintf("entry %d is not chained to the previous entry", entry.Sequence))
+		}
+
+		if auditLogHash(entry) != entry.Hash {
+			invalid(entry.Sequence, fmt.Sprintf("entry %d has been modified", entry.Sequence))
+		}
+
+		previous_hash = entry.Hash
+	}
+
+	if head.Sequence != len(entries) {
+		invalid(len(entries)+1, fmt.Sprintf("log ends at entry %d but %d entries were written", len(entries), head.Sequence))
+	} else if head.Hash != previous_hash {
+		invalid(len(entries), fmt.Sprintf("entry %d does not match the last written entry", len(entries)))
+	}
+
+	verification.IsValid = len(verification.Errors) == 0
+
+	if !verification.IsValid {
+		logger.Log.Printf("ERROR: audit log verification failed: %s", strings.Join(verification.Errors, ", "))
+	}
+
+	return verification, nil
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"ncrypt/utils/logger"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// How often the scheduler checks if a backup is due
+const BACKUP_SCHEDULER_TICK = time.Minute
+
+// Number of changes to login data and notes since the last successful backup
+var data_change_count atomic.Int64
+
+// Held while a backup is running
+var backup_lock sync.Mutex
+
+// Count a change to login data or notes towards the automatic backup change threshold
+func dataChanged() {
+	data_change_count.Add(1)
+}
+
+/*
+Runs automatic backups in the background.
+
+A backup is due once IntervalInMinutes have passed since the last backup or AfterChanges changes were made to login data and
+notes, whichever comes first. Nothing is backed up while the vault is locked, as export data is encrypted with the master key.
+*/
+type BackupScheduler struct {
+	system_service *SystemService
+	tick           time.Duration
+	stop_channel   chan struct{}
+	wait_group     sync.WaitGroup
+}
+
+func InitBackupScheduler() *BackupScheduler {
+	return &BackupScheduler{}
+}
+
+func (obj *BackupScheduler) Init() {
+	logger.Log.Printf("Initializing backup scheduler")
+	obj.system_service = new(SystemService)
+	obj.system_service.InitHeadless()
+	obj.tick = BACKUP_SCHEDULER_TICK
+}
+
+func (obj *BackupScheduler) Start() {
+	obj.stop_channel = make(chan struct{})
+	obj.wait_group.Add(1)
+
+	go func() {
+		defer obj.wait_group.Done()
+
+		ticker := time.NewTicker(obj.tick)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-obj.stop_channel:
+				return
+			case now := <-ticker.C:
+				obj.runIfDue(now)
+			}
+		}
+	}()
+}
+
+// Stop scheduler and wait for a running backup to complete
+func (obj *BackupScheduler) Stop() {
+	if obj.stop_channel == nil {
+		return
+	}
+
+	close(obj.stop_channel)
+	obj.wait_group.Wait()
+	obj.stop_channel = nil
+}
+
+func (obj *BackupScheduler) isDue(now time.Time) bool {
+	system_data, err := obj.system_service.GetSystemData()
+
+	if err != nil {
+		return false
+	}
+
+	auto_backup_setting := system_data.AutoBackupSetting
+
+	if !auto_backup_setting.IsEnabled {
+		return false
+	}
+
+	if _, err := obj.system_service.master_password_service.getMasterKeys(); err != nil {
+		return false
+	}
+
+	if auto_backup_setting.AfterChanges > 0 && data_change_count.Load() >= int64(auto_backup_setting.AfterChanges) {
+		return true
+	}
+
+	if auto_backup_setting.IntervalInMinutes > 0 {
+		last_backup_date_time, err := time.Parse(time.RFC3339, system_data.LastBackup.DateTime)
+
+		return err != nil || now.Sub(last_backup_date_time) >= time.Duration(auto_backup_setting.IntervalInMinutes)*time.Minute
+	}
+
+	return false
+}
+
+func (obj *BackupScheduler) runIfDue(now time.Time) {
+	if !obj.isDue(now) {
+		return
+	}
+
+	logger.Log.Printf("Running scheduled backup")
+
+	if err := obj.system_service.Backup(); err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"ncrypt/utils/encryptor"
+	"ncrypt/utils/logger"
+	"os"
+	"time"
+)
+
+const CLI_SESSION_FILE_NAME = "CLI_SESSION"
+
+var ErrSessionExpired = errors.New("session expired, unlock the vault again")
+
+// Unlocked keys cached between CLI invocations. Stored encrypted with the session token, which is never written to disk
+type cliSession struct {
+	Record    string `json:"record"`
+	Current   string `json:"current"`
+	Legacy    string `json:"legacy"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+func cliSessionPath() string {
+	return os.Getenv("STORAGE_FOLDER") + "/" + CLI_SESSION_FILE_NAME
+}
+
+/*
+Cache the unlocked keys so later CLI invocations can resume without the master password.
+
+Returns a random token that is the only way to decrypt the cached keys. The vault has to be unlocked and
+the session is valid for the given duration or until the master password changes.
+*/
+func (obj *MasterPasswordService) ExportSession(duration time.Duration) (string, error) {
+	logger.Log.Printf("Exporting CLI session")
+	keys, err := obj.getMasterKeys()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return "", err
+	}
+
+	record, err := obj.getMasterPasswordRecord()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return "", err
+	}
+
+	token_bytes := make([]byte, 32)
+	if _, err := rand.Read(token_bytes); err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return "", err
+	}
+	token := hex.EncodeToString(token_bytes)
+
+	session_data, err := json.Marshal(cliSession{
+		Record:    record,
+		Current:   keys.current,
+		Legacy:    keys.legacy,
+		ExpiresAt: time.Now().Add(duration).Format(time.RFC3339),
+	})
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return "", err
+	}
+
+	encrypted_session, err := encryptor.Encrypt(string(session_data), token, "SESSION")
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return "", err
+	}
+
+	err = os.WriteFile(cliSessionPath(), []byte(encrypted_session), 0600)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Unlock the vault using a session created by ExportSession
+func (obj *MasterPasswordService) ResumeSession(token string) error {
+	logger.Log.Printf("Resuming CLI session")
+	encrypted_session, err := os.ReadFile(cliSessionPath())
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = ErrVaultLocked
+		}
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	session_data, err := encryptor.Decrypt(string(encrypted_session), token, "SESSION")
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return errors.New("invalid session token")
+	}
+
+	var session cliSession
+	err = json.Unmarshal([]byte(session_data), &session)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	expires_at, err := time.Parse(time.RFC3339, session.ExpiresAt)
+
+	if err != nil || time.Now().After(expires_at) {
+		obj.EndSession()
+		logger.Log.Printf("ERROR: %s", ErrSessionExpired.Error())
+		return ErrSessionExpired
+	}
+
+	// Keys cached before a master password change can no longer decrypt the vault
+	stored_record, err := obj.getMasterPasswordRecord()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	if stored_record != session.Record {
+		obj.EndSession()
+		logger.Log.Printf("ERROR: %s", ErrSessionExpired.Error())
+		return ErrSessionExpired
+	}
+
+	unlock(session.Record, masterKeys{current: session.Current, legacy: session.Legacy})
+
+	return nil
+}
+
+// Remove cached CLI session
+func (obj *MasterPasswordService) EndSession() error {
+	logger.Log.Printf("Ending CLI session")
+	err := os.Remove(cliSessionPath())
+
+	if err != nil && !os.IsNotExist(err) {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	return nil
+}
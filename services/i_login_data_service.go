@@ -1,17 +1,27 @@
 package services
 
-import "ncrypt/models"
+import (
+	"ncrypt/models"
+	"ncrypt/utils/database"
+)
 
 type ILoginDataService interface {
 	Init()
 	GetLoginData(login_data_name string) (models.Login, error)
 	GetAllLoginData() ([]models.Login, error)
 	GetDecryptedAccountPassword(login_data_name string, account_username string) (string, error)
+	GetTOTPCode(login_data_name string, account_username string) (models.TOTPCode, error)
+	GetPasswordHistory(login_data_name string, account_username string) ([]models.PasswordHistoryDate, error)
+	RestorePassword(login_data_name string, account_username string, history_index int) error
 	AddLoginData(login_data map[string]interface{}) error
 	UpdateLoginData(old_login_data_name string, login_data map[string]interface{}) error
+	GetLoginDataVersion(login_data_name string) (string, error)
+	PatchLoginData(login_data_name string, patch_data map[string]interface{}, version string) (string, error)
 	DeleteLoginData(login_data_name string) error
-	recryptData(password_data map[string]string) error
-	importData(login_datas []models.Login) error
+	recryptData(transaction database.ITransaction, password_data map[string]string) error
+	importData(transaction database.ITransaction, login_datas []models.Login, replace_existing bool) (models.ImportChanges, error)
+	mergeData(transaction database.ITransaction, login_datas []models.Login, strategy string) (models.ImportChanges, error)
+	getDecryptedLoginData() ([]models.Login, error)
 }
 
 func InitBadgerLoginService() *LoginDataService {
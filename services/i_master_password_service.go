@@ -1,12 +1,24 @@
 package services
 
+import (
+	"ncrypt/utils/database"
+	"time"
+)
+
 type IMasterPasswordService interface {
 	Init()
 	GetMasterPassword() (string, error)
 	SetMasterPassword(master_password string) error
 	UpdateMasterPassword(old_master_password string, new_master_password string) error
 	Validate(password string) (bool, error)
-	importData(password string) error
+	ExportSession(duration time.Duration) (string, error)
+	ResumeSession(token string) error
+	EndSession() error
+	getMasterKeys() (masterKeys, error)
+	getMasterPasswordRecord() (string, error)
+	migrateData() error
+	importData(transaction database.ITransaction, password string) error
+	deleteData(transaction database.ITransaction) error
 }
 
 func InitBadgerMasterPasswordService() *MasterPasswordService {
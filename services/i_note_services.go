@@ -1,6 +1,9 @@
 package services
 
-import "ncrypt/models"
+import (
+	"ncrypt/models"
+	"ncrypt/utils/database"
+)
 
 type INoteService interface {
 	Init()
@@ -10,8 +13,9 @@ type INoteService interface {
 	AddNote(new_note map[string]interface{}) error
 	UpdateNote(created_date_time string, updated_note map[string]interface{}) error
 	DeleteNote(created_date_time string) error
-	recryptData(password_data map[string]string) error
-	importData(notes []models.Note) error
+	recryptData(transaction database.ITransaction, password_data map[string]string) error
+	importData(transaction database.ITransaction, notes []models.Note, replace_existing bool) (models.ImportChanges, error)
+	mergeData(transaction database.ITransaction, notes []models.Note, strategy string) (models.ImportChanges, error)
 }
 
 func InitBadgerNoteService() *NoteService {
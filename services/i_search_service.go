@@ -0,0 +1,12 @@
+package services
+
+import "ncrypt/models"
+
+type ISearchService interface {
+	Init()
+	Search(query string, is_content_included bool, page int, page_size int) (models.SearchPage, error)
+}
+
+func InitSearchService() *SearchService {
+	return &SearchService{}
+}
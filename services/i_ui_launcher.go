@@ -0,0 +1,16 @@
+package services
+
+type IUILauncher interface {
+	// Start UI without waiting for it to quit. on_exit is called once the UI quits
+	Launch(args []string, on_exit func()) error
+	// Closed once the UI has quit and on_exit returned. Never closed for headless launchers
+	Closed() <-chan struct{}
+}
+
+func InitExecutableUILauncher(executable_path string) *ExecutableUILauncher {
+	return &ExecutableUILauncher{executable_path: executable_path, closed: make(chan struct{})}
+}
+
+func InitHeadlessUILauncher() *HeadlessUILauncher {
+	return &HeadlessUILauncher{}
+}
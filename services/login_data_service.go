@@ -6,13 +6,26 @@ import (
 	"ncrypt/utils/database"
 	"ncrypt/utils/encryptor"
 	"ncrypt/utils/logger"
+	"ncrypt/utils/totp"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dgraph-io/badger/v4"
 	"github.com/joho/godotenv"
 )
 
+// Associated data used to tell TOTP secret ciphertexts apart from password ciphertexts of the same account
+const TOTP_ASSOCIATED_DATA = "TOTP"
+
+// Associated data used to tell previous password ciphertexts apart from the current password
+const HISTORY_ASSOCIATED_DATA = "HISTORY"
+
+// Number of previous passwords kept per account
+const MAX_PASSWORD_HISTORY = 10
+
 type LoginDataService struct {
 	database                database.IDatabase
 	master_password_service IMasterPasswordService
@@ -63,14 +76,14 @@ func (obj *LoginDataService) GetDecryptedAccountPassword(login_data_name string,
 	var decrypted_password string
 	for _, account := range fetched_login_data.Accounts {
 		if account.Username == account_username {
-			master_password_hash, err := obj.master_password_service.GetMasterPassword()
+			master_keys, err := obj.master_password_service.getMasterKeys()
 
 			if err != nil {
 				logger.Log.Printf("ERROR: %s", err.Error())
 				return "", err
 			}
 
-			decrypted_password, err = encryptor.Decrypt(account.Password, master_password_hash+login_data_name+account_username)
+			decrypted_password, err = encryptor.Decrypt(account.Password, master_keys.forCiphertext(account.Password)+fetched_login_data.Name+account_username, fetched_login_data.Name, account_username)
 
 			if err != nil {
 				logger.Log.Printf("ERROR: %s", err.Error())
@@ -86,6 +99,8 @@ func (obj *LoginDataService) GetDecryptedAccountPassword(login_data_name string,
 		return "", errors.New("account username not found")
 	}
 
+	recordAuditEvent(AUDIT_REVEAL_PASSWORD, fetched_login_data.Name, "account "+account_username)
+
 	logger.Log.Printf("DONE")
 	return decrypted_password, nil
 }
@@ -140,18 +155,29 @@ func (obj *LoginDataService) setLoginData(login_data models.Login) error {
 		return err
 	}
 
+	login_data, err = encryptLoginData(login_data, master_password_hash)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
 	for index := range len(login_data.Accounts) {
-		login_data.Accounts[index].Password, _ = encryptor.Encrypt(login_data.Accounts[index].Password, master_password_hash+login_data.Name+login_data.Accounts[index].Username)
+		if login_data.Accounts[index].PasswordUpdatedDateTime == "" {
+			login_data.Accounts[index].PasswordUpdatedDateTime = time.Now().Format(time.RFC3339)
+		}
 	}
 
 	err = obj.database.AddData(strings.ToUpper(login_data.Name), login_data)
 
 	if err != nil {
 		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
 	}
 
+	dataChanged()
 	logger.Log.Printf("Saved to database!")
-	return err
+	return nil
 }
 
 func (obj *LoginDataService) AddLoginData(login_data map[string]interface{}) error {
@@ -172,10 +198,17 @@ func (obj *LoginDataService) AddLoginData(login_data map[string]interface{}) err
 		return errors.New(new_login_data.Name + " already exists")
 	}
 
+	// Password history is only maintained by the server
+	for index := range len(new_login_data.Accounts) {
+		new_login_data.Accounts[index].PasswordHistory = nil
+	}
+
 	err = obj.setLoginData(new_login_data)
 
 	if err != nil {
 		logger.Log.Printf("ERROR: %s", err.Error())
+	} else {
+		recordAuditEvent(AUDIT_LOGIN_CREATED, new_login_data.Name, "")
 	}
 
 	logger.Log.Printf("DONE")
@@ -190,6 +223,14 @@ func (obj *LoginDataService) UpdateLoginData(old_login_data_name string, login_d
 	var updated_login_data models.Login
 	updated_login_data.FromMap(login_data)
 
+	// Used to keep password updated time of accounts whose password is unchanged
+	existing_login_data, err := obj.GetLoginData(old_login_data_name)
+
+	if err != nil && err != badger.ErrKeyNotFound {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
 	if old_login_data_name != updated_login_data.Name {
 		existing_data, err := obj.GetLoginData(updated_login_data.Name)
 
@@ -202,29 +243,77 @@ func (obj *LoginDataService) UpdateLoginData(old_login_data_name string, login_d
 			logger.Log.Printf("ERROR: %s", err.Error())
 			return err
 		}
-
-		err = obj.DeleteLoginData(old_login_data_name)
-
-		if err != nil {
-			logger.Log.Printf("ERROR: %s", err.Error())
-			return err
-		}
 	}
 
 	logger.Log.Printf("Decrypting data")
 
-	key, err := obj.master_password_service.GetMasterPassword()
+	master_keys, err := obj.master_password_service.getMasterKeys()
 
 	if err != nil {
 		logger.Log.Printf("ERROR: %s", err.Error())
 	}
 
 	for index := range len(updated_login_data.Accounts) {
-		decrypted_data, err := encryptor.Decrypt(updated_login_data.Accounts[index].Password, key+old_login_data_name+updated_login_data.Accounts[index].Username)
+		password := updated_login_data.Accounts[index].Password
+		decrypted_data, err := encryptor.Decrypt(password, master_keys.forCiphertext(password)+old_login_data_name+updated_login_data.Accounts[index].Username, old_login_data_name, updated_login_data.Accounts[index].Username)
+		is_password_changed := err != nil
+
+		existing_index := findAccount(existing_login_data.Accounts, updated_login_data.Accounts[index].Username)
 
 		// login_data.Accounts[index].Password = decrypted_data
-		if err == nil {
+		if !is_password_changed {
 			updated_login_data.Accounts[index].Password = decrypted_data
+
+			if existing_index != -1 {
+				updated_login_data.Accounts[index].PasswordUpdatedDateTime = existing_login_data.Accounts[existing_index].PasswordUpdatedDateTime
+			}
+		} else {
+			updated_login_data.Accounts[index].PasswordUpdatedDateTime = ""
+		}
+
+		// Password history is only maintained by the server, replaced password is added to it
+		updated_login_data.Accounts[index].PasswordHistory = nil
+
+		if existing_index != -1 {
+			existing_account := existing_login_data.Accounts[existing_index]
+			history, err := decryptPasswordHistory(existing_account.PasswordHistory, master_keys, old_login_data_name, existing_account.Username)
+
+			if err != nil {
+				logger.Log.Printf("ERROR: %s", err.Error())
+				return err
+			}
+
+			if is_password_changed {
+				old_password, err := encryptor.Decrypt(existing_account.Password, master_keys.forCiphertext(existing_account.Password)+old_login_data_name+existing_account.Username, old_login_data_name, existing_account.Username)
+
+				if err != nil {
+					logger.Log.Printf("ERROR: %s", err.Error())
+					return err
+				}
+
+				if old_password != password {
+					history = addPasswordHistory(history, old_password, existing_account.PasswordUpdatedDateTime)
+				}
+			}
+
+			updated_login_data.Accounts[index].PasswordHistory = history
+		}
+
+		if updated_login_data.Accounts[index].TOTPSecret != nil {
+			decrypted_secret, err := decryptTOTPSecret(updated_login_data.Accounts[index].TOTPSecret, master_keys, old_login_data_name, updated_login_data.Accounts[index].Username)
+
+			if err == nil {
+				updated_login_data.Accounts[index].TOTPSecret.Secret = decrypted_secret
+			}
+		}
+	}
+
+	if old_login_data_name != updated_login_data.Name {
+		err = obj.deleteLoginData(old_login_data_name)
+
+		if err != nil {
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return err
 		}
 	}
 
@@ -232,6 +321,10 @@ func (obj *LoginDataService) UpdateLoginData(old_login_data_name string, login_d
 
 	if err != nil {
 		logger.Log.Printf("ERROR: %s", err.Error())
+	} else if old_login_data_name != updated_login_data.Name {
+		recordAuditEvent(AUDIT_LOGIN_UPDATED, updated_login_data.Name, "renamed from "+old_login_data_name)
+	} else {
+		recordAuditEvent(AUDIT_LOGIN_UPDATED, updated_login_data.Name, "")
 	}
 
 	logger.Log.Printf("DONE")
@@ -239,17 +332,621 @@ func (obj *LoginDataService) UpdateLoginData(old_login_data_name string, login_d
 	return err
 }
 
+// Get version of stored login data, changes on every write
+func (obj *LoginDataService) GetLoginDataVersion(login_data_name string) (string, error) {
+	version, err := obj.database.GetVersion(strings.ToUpper(login_data_name))
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+	}
+
+	return version, err
+}
+
+/*
+Partially update login data without re-submitting the whole login.
+
+Only accounts that are added or edited are encrypted again. If version is not empty and the stored login has a different version
+database.ErrConflict is returned. Returns the version of the updated login.
+*/
+func (obj *LoginDataService) PatchLoginData(login_data_name string, patch_data map[string]interface{}, version string) (string, error) {
+	logger.Log.Printf("Patching login data")
+
+	var patch models.LoginPatch
+	patch.FromMap(patch_data)
+
+	current_version, err := obj.GetLoginDataVersion(login_data_name)
+
+	if err != nil {
+		return "", err
+	}
+
+	if version != "" && version != current_version {
+		logger.Log.Printf("ERROR: %s", database.ErrConflict.Error())
+		return "", database.ErrConflict
+	}
+
+	login_data, err := obj.GetLoginData(login_data_name)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return "", err
+	}
+
+	master_keys, err := obj.master_password_service.getMasterKeys()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return "", err
+	}
+
+	if patch.URL != nil {
+		login_data.URL = *patch.URL
+	}
+	if patch.IsFavourite != nil {
+		login_data.Attributes.IsFavourite = *patch.IsFavourite
+	}
+	if patch.RequireMasterPassword != nil {
+		login_data.Attributes.RequireMasterPassword = *patch.RequireMasterPassword
+	}
+
+	logger.Log.Printf("Removing accounts")
+	for _, username := range patch.RemoveAccounts {
+		index := findAccount(login_data.Accounts, username)
+
+		if index == -1 {
+			err = errors.New("account username " + username + " not found")
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return "", err
+		}
+
+		login_data.Accounts = append(login_data.Accounts[:index], login_data.Accounts[index+1:]...)
+	}
+
+	logger.Log.Printf("Updating accounts")
+	for _, account_patch := range patch.UpdateAccounts {
+		index := findAccount(login_data.Accounts, account_patch.Username)
+
+		if index == -1 {
+			err = errors.New("account username " + account_patch.Username + " not found")
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return "", err
+		}
+
+		account := &login_data.Accounts[index]
+		password := account_patch.Password
+
+		// History is bound to the username as well, so it is decrypted and encrypted again along with the password
+		history, err := decryptPasswordHistory(account.PasswordHistory, master_keys, login_data.Name, account.Username)
+
+		if err != nil {
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return "", err
+		}
+
+		if account_patch.Password != "" {
+			old_password, err := encryptor.Decrypt(account.Password, master_keys.forCiphertext(account.Password)+login_data.Name+account.Username, login_data.Name, account.Username)
+
+			if err != nil {
+				logger.Log.Printf("ERROR: %s", err.Error())
+				return "", err
+			}
+
+			if old_password != account_patch.Password {
+				history = addPasswordHistory(history, old_password, account.PasswordUpdatedDateTime)
+			}
+		}
+
+		// Password is bound to the username, so renaming requires it to be encrypted again
+		if account_patch.NewUsername != "" && account_patch.NewUsername != account.Username {
+			if findAccount(login_data.Accounts, account_patch.NewUsername) != -1 {
+				err = errors.New("duplicate username " + account_patch.NewUsername)
+				logger.Log.Printf("ERROR: %s", err.Error())
+				return "", err
+			}
+
+			if password == "" {
+				password, err = encryptor.Decrypt(account.Password, master_keys.forCiphertext(account.Password)+login_data.Name+account.Username, login_data.Name, account.Username)
+
+				if err != nil {
+					logger.Log.Printf("ERROR: %s", err.Error())
+					return "", err
+				}
+			}
+
+			if account.TOTPSecret != nil && account_patch.TOTPSecret == nil && !account_patch.RemoveTOTPSecret {
+				decrypted_secret, err := decryptTOTPSecret(account.TOTPSecret, master_keys, login_data.Name, account.Username)
+
+				if err != nil {
+					logger.Log.Printf("ERROR: %s", err.Error())
+					return "", err
+				}
+
+				account_patch.TOTPSecret = &models.TOTPSecret{Secret: decrypted_secret, Digits: account.TOTPSecret.Digits, Period: account.TOTPSecret.Period, Algorithm: account.TOTPSecret.Algorithm}
+			}
+
+			account.Username = account_patch.NewUsername
+		}
+
+		if account_patch.RemoveTOTPSecret {
+			account.TOTPSecret = nil
+		}
+
+		if account_patch.TOTPSecret != nil {
+			account.TOTPSecret = account_patch.TOTPSecret
+
+			err = encryptTOTPSecret(account, master_keys.current, login_data.Name)
+
+			if err != nil {
+				logger.Log.Printf("ERROR: %s", err.Error())
+				return "", err
+			}
+		}
+
+		if account_patch.Password != "" {
+			account.PasswordUpdatedDateTime = time.Now().Format(time.RFC3339)
+		}
+
+		if password != "" {
+			account.Password, err = encryptor.Encrypt(password, master_keys.current+login_data.Name+account.Username, login_data.Name, account.Username)
+
+			if err != nil {
+				logger.Log.Printf("ERROR: %s", err.Error())
+				return "", err
+			}
+		}
+
+		account.PasswordHistory, err = encryptPasswordHistory(history, master_keys.current, login_data.Name, account.Username)
+
+		if err != nil {
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return "", err
+		}
+	}
+
+	logger.Log.Printf("Adding accounts")
+	for _, account := range patch.AddAccounts {
+		if findAccount(login_data.Accounts, account.Username) != -1 {
+			err = errors.New("duplicate username " + account.Username)
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return "", err
+		}
+
+		account.Password, err = encryptor.Encrypt(account.Password, master_keys.current+login_data.Name+account.Username, login_data.Name, account.Username)
+
+		if err != nil {
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return "", err
+		}
+
+		err = encryptTOTPSecret(&account, master_keys.current, login_data.Name)
+
+		if err != nil {
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return "", err
+		}
+
+		account.PasswordUpdatedDateTime = time.Now().Format(time.RFC3339)
+		account.PasswordHistory = nil
+		login_data.Accounts = append(login_data.Accounts, account)
+	}
+
+	// Write only if login is unchanged since it was read
+	err = obj.database.UpdateData(strings.ToUpper(login_data.Name), login_data, current_version)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return "", err
+	}
+
+	dataChanged()
+	recordAuditEvent(AUDIT_LOGIN_UPDATED, login_data.Name, "")
+
+	logger.Log.Printf("DONE")
+	return obj.GetLoginDataVersion(login_data.Name)
+}
+
+// Get current one-time code of an account's TOTP secret along with seconds remaining until it expires
+func (obj *LoginDataService) GetTOTPCode(login_data_name string, account_username string) (models.TOTPCode, error) {
+	logger.Log.Printf("Generating TOTP code")
+
+	login_data, err := obj.GetLoginData(login_data_name)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.TOTPCode{}, err
+	}
+
+	index := findAccount(login_data.Accounts, account_username)
+
+	if index == -1 {
+		err = errors.New("account username not found")
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.TOTPCode{}, err
+	}
+
+	account := login_data.Accounts[index]
+
+	if account.TOTPSecret == nil {
+		err = errors.New("totp secret not set for " + account_username)
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.TOTPCode{}, err
+	}
+
+	master_keys, err := obj.master_password_service.getMasterKeys()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.TOTPCode{}, err
+	}
+
+	secret, err := decryptTOTPSecret(account.TOTPSecret, master_keys, login_data.Name, account.Username)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.TOTPCode{}, err
+	}
+
+	key := totp.Key{Secret: secret, Digits: account.TOTPSecret.Digits, Period: account.TOTPSecret.Period, Algorithm: account.TOTPSecret.Algorithm}
+	code, remaining_seconds, err := key.GenerateCode(time.Now())
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.TOTPCode{}, err
+	}
+
+	recordAuditEvent(AUDIT_REVEAL_TOTP, login_data.Name, "account "+account.Username)
+
+	logger.Log.Printf("DONE")
+	return models.TOTPCode{Code: code, RemainingSeconds: remaining_seconds}, nil
+}
+
+// List dates of previous passwords of an account, most recent first. Passwords themselves are not returned
+func (obj *LoginDataService) GetPasswordHistory(login_data_name string, account_username string) ([]models.PasswordHistoryDate, error) {
+	logger.Log.Printf("Getting password history")
+
+	login_data, err := obj.GetLoginData(login_data_name)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return nil, err
+	}
+
+	index := findAccount(login_data.Accounts, account_username)
+
+	if index == -1 {
+		err = errors.New("account username " + account_username + " not found")
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return nil, err
+	}
+
+	history_dates := []models.PasswordHistoryDate{}
+	for history_index, entry := range login_data.Accounts[index].PasswordHistory {
+		history_dates = append(history_dates, models.PasswordHistoryDate{Index: history_index, CreatedDateTime: entry.CreatedDateTime, ReplacedDateTime: entry.ReplacedDateTime})
+	}
+
+	return history_dates, nil
+}
+
+/*
+Swap a previous password of an account back in, the current password is added to history.
+
+Returns database.ErrConflict if login data is modified while restoring.
+*/
+func (obj *LoginDataService) RestorePassword(login_data_name string, account_username string, history_index int) error {
+	logger.Log.Printf("Restoring password")
+
+	version, err := obj.GetLoginDataVersion(login_data_name)
+
+	if err != nil {
+		return err
+	}
+
+	login_data, err := obj.GetLoginData(login_data_name)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	index := findAccount(login_data.Accounts, account_username)
+
+	if index == -1 {
+		err = errors.New("account username " + account_username + " not found")
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	account := &login_data.Accounts[index]
+
+	if history_index < 0 || history_index >= len(account.PasswordHistory) {
+		err = errors.New("password history entry not found")
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	master_keys, err := obj.master_password_service.getMasterKeys()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	history, err := decryptPasswordHistory(account.PasswordHistory, master_keys, login_data.Name, account.Username)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	current_password, err := encryptor.Decrypt(account.Password, master_keys.forCiphertext(account.Password)+login_data.Name+account.Username, login_data.Name, account.Username)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	restored_entry := history[history_index]
+	history = addPasswordHistory(slices.Delete(history, history_index, history_index+1), current_password, account.PasswordUpdatedDateTime)
+
+	account.Password, err = encryptor.Encrypt(restored_entry.Password, master_keys.current+login_data.Name+account.Username, login_data.Name, account.Username)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	account.PasswordHistory, err = encryptPasswordHistory(history, master_keys.current, login_data.Name, account.Username)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	// Restored password is the one that was set on the website back then, so its age is kept
+	account.PasswordUpdatedDateTime = restored_entry.CreatedDateTime
+	if account.PasswordUpdatedDateTime == "" {
+		account.PasswordUpdatedDateTime = time.Now().Format(time.RFC3339)
+	}
+
+	err = obj.database.UpdateData(strings.ToUpper(login_data.Name), login_data, version)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	dataChanged()
+	recordAuditEvent(AUDIT_LOGIN_UPDATED, login_data.Name, "restored password of account "+account_username)
+
+	logger.Log.Printf("DONE")
+	return nil
+}
+
+// Encrypt TOTP secret of the account. otpauth:// URIs are parsed to get the secret and its parameters
+func encryptTOTPSecret(account *models.Account, master_key string, login_data_name string) error {
+	if account.TOTPSecret == nil {
+		return nil
+	}
+
+	key := totp.Key{Secret: account.TOTPSecret.Secret, Digits: account.TOTPSecret.Digits, Period: account.TOTPSecret.Period, Algorithm: account.TOTPSecret.Algorithm}
+
+	var err error
+	if totp.IsURI(key.Secret) {
+		key, err = totp.ParseURI(key.Secret)
+	} else {
+		err = key.Normalize()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	encrypted_secret, err := encryptor.Encrypt(key.Secret, master_key+login_data_name+account.Username, login_data_name, account.Username, TOTP_ASSOCIATED_DATA)
+
+	if err != nil {
+		return err
+	}
+
+	account.TOTPSecret = &models.TOTPSecret{Secret: encrypted_secret, Digits: key.Digits, Period: key.Period, Algorithm: key.Algorithm}
+	return nil
+}
+
+func decryptTOTPSecret(totp_secret *models.TOTPSecret, master_keys masterKeys, login_data_name string, account_username string) (string, error) {
+	return encryptor.Decrypt(totp_secret.Secret, master_keys.forCiphertext(totp_secret.Secret)+login_data_name+account_username, login_data_name, account_username, TOTP_ASSOCIATED_DATA)
+}
+
+// Encrypt previous passwords of an account. Replaced time is bound to the ciphertext so entries cannot be reordered
+func encryptPasswordHistory(history []models.PasswordHistoryEntry, master_key string, login_data_name string, account_username string) ([]models.PasswordHistoryEntry, error) {
+	var encrypted_history []models.PasswordHistoryEntry
+
+	for _, entry := range history {
+		encrypted_password, err := encryptor.Encrypt(entry.Password, master_key+login_data_name+account_username, login_data_name, account_username, HISTORY_ASSOCIATED_DATA, entry.ReplacedDateTime)
+
+		if err != nil {
+			return nil, err
+		}
+
+		encrypted_history = append(encrypted_history, models.PasswordHistoryEntry{Password: encrypted_password, CreatedDateTime: entry.CreatedDateTime, ReplacedDateTime: entry.ReplacedDateTime})
+	}
+
+	return encrypted_history, nil
+}
+
+func decryptPasswordHistory(history []models.PasswordHistoryEntry, master_keys masterKeys, login_data_name string, account_username string) ([]models.PasswordHistoryEntry, error) {
+	var decrypted_history []models.PasswordHistoryEntry
+
+	for _, entry := range history {
+		decrypted_password, err := encryptor.Decrypt(entry.Password, master_keys.forCiphertext(entry.Password)+login_data_name+account_username, login_data_name, account_username, HISTORY_ASSOCIATED_DATA, entry.ReplacedDateTime)
+
+		if err != nil {
+			return nil, err
+		}
+
+		decrypted_history = append(decrypted_history, models.PasswordHistoryEntry{Password: decrypted_password, CreatedDateTime: entry.CreatedDateTime, ReplacedDateTime: entry.ReplacedDateTime})
+	}
+
+	return decrypted_history, nil
+}
+
+// Add replaced (decrypted) password to the front of history, oldest entries beyond MAX_PASSWORD_HISTORY are dropped
+func addPasswordHistory(history []models.PasswordHistoryEntry, password string, created_date_time string) []models.PasswordHistoryEntry {
+	entry := models.PasswordHistoryEntry{Password: password, CreatedDateTime: created_date_time, ReplacedDateTime: time.Now().Format(time.RFC3339)}
+	history = append([]models.PasswordHistoryEntry{entry}, history...)
+
+	if len(history) > MAX_PASSWORD_HISTORY {
+		history = history[:MAX_PASSWORD_HISTORY]
+	}
+
+	return history
+}
+
+// Encrypt passwords, TOTP secrets and password history of all accounts. Returns a copy, given login data is left as is
+func encryptLoginData(login_data models.Login, master_key string) (models.Login, error) {
+	var err error
+	login_data.Accounts = slices.Clone(login_data.Accounts)
+
+	for index := range len(login_data.Accounts) {
+		account := &login_data.Accounts[index]
+		account.Password, err = encryptor.Encrypt(account.Password, master_key+login_data.Name+account.Username, login_data.Name, account.Username)
+
+		if err != nil {
+			return models.Login{}, err
+		}
+
+		if account.TOTPSecret != nil {
+			totp_secret := *account.TOTPSecret
+			account.TOTPSecret = &totp_secret
+		}
+
+		err = encryptTOTPSecret(account, master_key, login_data.Name)
+
+		if err != nil {
+			return models.Login{}, err
+		}
+
+		account.PasswordHistory, err = encryptPasswordHistory(account.PasswordHistory, master_key, login_data.Name, account.Username)
+
+		if err != nil {
+			return models.Login{}, err
+		}
+	}
+
+	return login_data, nil
+}
+
+// Decrypt passwords, TOTP secrets and password history of all accounts. Returns a copy, given login data is left as is
+func decryptLoginData(login_data models.Login, master_keys masterKeys) (models.Login, error) {
+	var err error
+	login_data.Accounts = slices.Clone(login_data.Accounts)
+
+	for index := range len(login_data.Accounts) {
+		account := &login_data.Accounts[index]
+		account.Password, err = encryptor.Decrypt(account.Password, master_keys.forCiphertext(account.Password)+login_data.Name+account.Username, login_data.Name, account.Username)
+
+		if err != nil {
+			return models.Login{}, err
+		}
+
+		if account.TOTPSecret != nil {
+			totp_secret := *account.TOTPSecret
+			totp_secret.Secret, err = decryptTOTPSecret(account.TOTPSecret, master_keys, login_data.Name, account.Username)
+
+			if err != nil {
+				return models.Login{}, err
+			}
+
+			account.TOTPSecret = &totp_secret
+		}
+
+		account.PasswordHistory, err = decryptPasswordHistory(account.PasswordHistory, master_keys, login_data.Name, account.Username)
+
+		if err != nil {
+			return models.Login{}, err
+		}
+	}
+
+	return login_data, nil
+}
+
+// Add " (1)", " (2)", ... to name until it is not taken
+func uniqueName(name string, is_taken func(string) bool) string {
+	for count := 1; ; count++ {
+		candidate := name + " (" + strconv.Itoa(count) + ")"
+
+		if !is_taken(candidate) {
+			return candidate
+		}
+	}
+}
+
+// Get index of account with the given username, -1 if not found
+func findAccount(accounts []models.Account, username string) int {
+	for index, account := range accounts {
+		if account.Username == username {
+			return index
+		}
+	}
+
+	return -1
+}
+
+// Get all login data with account passwords decrypted. Only meant for server side processing, never return this to clients
+func (obj *LoginDataService) getDecryptedLoginData() ([]models.Login, error) {
+	login_list, err := obj.GetAllLoginData()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return nil, err
+	}
+
+	master_keys, err := obj.master_password_service.getMasterKeys()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return nil, err
+	}
+
+	logger.Log.Printf("Decrypting login data")
+	for i := range len(login_list) {
+		for j := range len(login_list[i].Accounts) {
+			account := &login_list[i].Accounts[j]
+			account.Password, err = encryptor.Decrypt(account.Password, master_keys.forCiphertext(account.Password)+login_list[i].Name+account.Username, login_list[i].Name, account.Username)
+
+			if err != nil {
+				logger.Log.Printf("ERROR: %s", err.Error())
+				return nil, err
+			}
+		}
+	}
+
+	return login_list, nil
+}
+
 func (obj *LoginDataService) DeleteLoginData(login_data_name string) error {
+	err := obj.deleteLoginData(login_data_name)
+
+	if err == nil {
+		recordAuditEvent(AUDIT_LOGIN_DELETED, login_data_name, "")
+	}
+
+	return err
+}
+
+// Delete login data without recording it in the audit log, used when the login is renamed
+func (obj *LoginDataService) deleteLoginData(login_data_name string) error {
 	logger.Log.Printf("Deleting login data")
 	err := obj.database.DeleteData(strings.ToUpper(login_data_name))
 	if err != nil {
 		logger.Log.Printf("ERROR: %s", err.Error())
+	} else {
+		dataChanged()
 	}
 	logger.Log.Printf("DONE")
 	return err
 }
 
-func (obj *LoginDataService) recryptData(password_data map[string]string) error {
+func (obj *LoginDataService) recryptData(transaction database.ITransaction, password_data map[string]string) error {
 	logger.Log.Printf("Re-crpyting login data")
 
 	//Get all login data
@@ -261,30 +958,83 @@ func (obj *LoginDataService) recryptData(password_data map[string]string) error
 	}
 
 	//Decrypt all login data
-	old_password := password_data["OLD_PASSWORD"]
+	old_keys := masterKeys{current: password_data["OLD_PASSWORD"], legacy: password_data["OLD_LEGACY_PASSWORD"]}
 	new_password := password_data["NEW_PASSWORD"]
 
+	var updated_login_list []models.Login
 	for i := range len(login_list) {
+		needs_recrypt := false
 		for j := range len(login_list[i].Accounts) {
-			login_list[i].Accounts[j].Password, err = encryptor.Decrypt(login_list[i].Accounts[j].Password, old_password+login_list[i].Name+login_list[i].Accounts[j].Username)
+			needs_recrypt = needs_recrypt || old_keys.needsRecrypt(login_list[i].Accounts[j].Password, new_password)
+
+			if login_list[i].Accounts[j].TOTPSecret != nil {
+				needs_recrypt = needs_recrypt || old_keys.needsRecrypt(login_list[i].Accounts[j].TOTPSecret.Secret, new_password)
+			}
+
+			for _, entry := range login_list[i].Accounts[j].PasswordHistory {
+				needs_recrypt = needs_recrypt || old_keys.needsRecrypt(entry.Password, new_password)
+			}
+		}
+		if !needs_recrypt {
+			continue
+		}
+
+		for j := range len(login_list[i].Accounts) {
+			old_password := old_keys.forCiphertext(login_list[i].Accounts[j].Password)
+			login_list[i].Accounts[j].Password, err = encryptor.Decrypt(login_list[i].Accounts[j].Password, old_password+login_list[i].Name+login_list[i].Accounts[j].Username, login_list[i].Name, login_list[i].Accounts[j].Username)
+			if err != nil {
+				logger.Log.Printf("ERROR: %s", err.Error())
+				return err
+			}
+
+			if login_list[i].Accounts[j].TOTPSecret != nil {
+				login_list[i].Accounts[j].TOTPSecret.Secret, err = decryptTOTPSecret(login_list[i].Accounts[j].TOTPSecret, old_keys, login_list[i].Name, login_list[i].Accounts[j].Username)
+				if err != nil {
+					logger.Log.Printf("ERROR: %s", err.Error())
+					return err
+				}
+			}
+
+			login_list[i].Accounts[j].PasswordHistory, err = decryptPasswordHistory(login_list[i].Accounts[j].PasswordHistory, old_keys, login_list[i].Name, login_list[i].Accounts[j].Username)
 			if err != nil {
 				logger.Log.Printf("ERROR: %s", err.Error())
 				return err
 			}
 		}
+
+		updated_login_list = append(updated_login_list, login_list[i])
 	}
 
 	//Save updated data
-	for i := range len(login_list) {
-		for j := range len(login_list[i].Accounts) {
-			login_list[i].Accounts[j].Password, err = encryptor.Encrypt(login_list[i].Accounts[j].Password, new_password+login_list[i].Name+login_list[i].Accounts[j].Username)
+	for i := range len(updated_login_list) {
+		for j := range len(updated_login_list[i].Accounts) {
+			updated_login_list[i].Accounts[j].Password, err = encryptor.Encrypt(updated_login_list[i].Accounts[j].Password, new_password+updated_login_list[i].Name+updated_login_list[i].Accounts[j].Username, updated_login_list[i].Name, updated_login_list[i].Accounts[j].Username)
+
+			if err != nil {
+				logger.Log.Printf("ERROR: %s", err.Error())
+				return err
+			}
+
+			err = encryptTOTPSecret(&updated_login_list[i].Accounts[j], new_password, updated_login_list[i].Name)
 
 			if err != nil {
 				logger.Log.Printf("ERROR: %s", err.Error())
 				return err
 			}
 
-			obj.database.AddData(strings.ToUpper(login_list[i].Name), login_list[i])
+			updated_login_list[i].Accounts[j].PasswordHistory, err = encryptPasswordHistory(updated_login_list[i].Accounts[j].PasswordHistory, new_password, updated_login_list[i].Name, updated_login_list[i].Accounts[j].Username)
+
+			if err != nil {
+				logger.Log.Printf("ERROR: %s", err.Error())
+				return err
+			}
+		}
+
+		err = transaction.AddData(obj.database, strings.ToUpper(updated_login_list[i].Name), updated_login_list[i])
+
+		if err != nil {
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return err
 		}
 	}
 
@@ -292,15 +1042,137 @@ func (obj *LoginDataService) recryptData(password_data map[string]string) error
 	return nil
 }
 
-func (obj *LoginDataService) importData(login_data_list []models.Login) error {
-	logger.Log.Printf("Importing notes")
+/*
+Stage already encrypted login data from an export as is. If replace_existing is set, login data not in the export is deleted so
+the vault ends up with exactly the exported data.
+*/
+func (obj *LoginDataService) importData(transaction database.ITransaction, login_data_list []models.Login, replace_existing bool) (models.ImportChanges, error) {
+	logger.Log.Printf("Importing login data")
+	var changes models.ImportChanges
+
+	existing_list, err := obj.GetAllLoginData()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return changes, err
+	}
+
+	existing_names := make(map[string]bool)
+	for _, login_data := range existing_list {
+		existing_names[strings.ToUpper(login_data.Name)] = true
+	}
+
+	imported_names := make(map[string]bool)
 	for _, login_data := range login_data_list {
-		err := obj.database.AddData(strings.ToUpper(login_data.Name), login_data)
+		key := strings.ToUpper(login_data.Name)
+		imported_names[key] = true
+
+		if existing_names[key] {
+			changes.Overwritten = append(changes.Overwritten, login_data.Name)
+		} else {
+			changes.Added = append(changes.Added, login_data.Name)
+		}
+
+		err = transaction.AddData(obj.database, key, login_data)
 
 		if err != nil {
-			return err
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return changes, err
 		}
 	}
 
-	return nil
+	if replace_existing {
+		for _, login_data := range existing_list {
+			key := strings.ToUpper(login_data.Name)
+
+			if imported_names[key] {
+				continue
+			}
+
+			changes.Removed = append(changes.Removed, login_data.Name)
+			err = transaction.DeleteData(obj.database, key)
+
+			if err != nil {
+				logger.Log.Printf("ERROR: %s", err.Error())
+				return changes, err
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+/*
+Stage decrypted login data into the vault, encrypted with the current master password.
+
+Login data whose name already exists is handled according to strategy:
+  - KEEP_EXISTING: imported login data is skipped
+  - OVERWRITE: existing login data is replaced
+  - KEEP_BOTH: imported login data is renamed to "name (1)", "name (2)", ...
+*/
+func (obj *LoginDataService) mergeData(transaction database.ITransaction, login_data_list []models.Login, strategy string) (models.ImportChanges, error) {
+	logger.Log.Printf("Merging login data")
+	var changes models.ImportChanges
+
+	master_keys, err := obj.master_password_service.getMasterKeys()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return changes, err
+	}
+
+	existing_list, err := obj.GetAllLoginData()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return changes, err
+	}
+
+	taken_names := make(map[string]bool)
+	for _, login_data := range existing_list {
+		taken_names[strings.ToUpper(login_data.Name)] = true
+	}
+
+	is_taken := func(name string) bool {
+		return taken_names[strings.ToUpper(name)]
+	}
+
+	for _, login_data := range login_data_list {
+		if is_taken(login_data.Name) {
+			switch strategy {
+			case models.IMPORT_KEEP_EXISTING:
+				changes.Skipped = append(changes.Skipped, login_data.Name)
+				continue
+			case models.IMPORT_OVERWRITE:
+				changes.Overwritten = append(changes.Overwritten, login_data.Name)
+			case models.IMPORT_KEEP_BOTH:
+				new_name := uniqueName(login_data.Name, is_taken)
+				changes.Renamed = append(changes.Renamed, models.ImportRename{From: login_data.Name, To: new_name})
+				login_data.Name = new_name
+			default:
+				return changes, errors.New("invalid import strategy " + strategy)
+			}
+		} else {
+			changes.Added = append(changes.Added, login_data.Name)
+		}
+
+		taken_names[strings.ToUpper(login_data.Name)] = true
+
+		// Name is part of the associated data, so renamed login data is encrypted after renaming
+		encrypted_login_data, err := encryptLoginData(login_data, master_keys.current)
+
+		if err != nil {
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return changes, err
+		}
+
+		err = transaction.AddData(obj.database, strings.ToUpper(encrypted_login_data.Name), encrypted_login_data)
+
+		if err != nil {
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return changes, err
+		}
+	}
+
+	return changes, nil
 }
@@ -2,9 +2,15 @@ package services
 
 import (
 	"ncrypt/models"
+	"ncrypt/utils/database"
+	"ncrypt/utils/encryptor"
+	"ncrypt/utils/totp"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/dgraph-io/badger/v4"
 )
@@ -273,6 +279,48 @@ func TestGetAllLoginData_With_Multiple_Record(t *testing.T) {
 	t.Cleanup(login_service_test_cleanup)
 }
 
+func TestGetAllLoginData_Concurrent(t *testing.T) {
+	login_service_test_init()
+
+	login_data := make(map[string]interface{})
+	login_data["name"] = "github"
+	login_data["url"] = "https://github.com"
+	login_data["accounts"] = []interface{}{map[string]interface{}{"username": "abc", "password": "123"}}
+	login_data["attributes"] = map[string]interface{}{"is_favourite": true, "require_master_password": false}
+
+	login_service := new(LoginDataService)
+	login_service.Init()
+
+	login_service.AddLoginData(login_data)
+
+	//Concurrent requests should share the same database connection instead of failing on the directory lock
+	var wg sync.WaitGroup
+	err_channel := make(chan error, 10)
+
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			service := new(LoginDataService)
+			service.Init()
+
+			if _, err := service.GetAllLoginData(); err != nil {
+				err_channel <- err
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(err_channel)
+
+	for err := range err_channel {
+		t.Error(err.Error())
+	}
+
+	t.Cleanup(login_service_test_cleanup)
+}
+
 func TestDeleteLoginData(t *testing.T) {
 	login_service_test_init()
 
@@ -443,6 +491,244 @@ func TestUpdateLoginData_ConflictingName(t *testing.T) {
 	t.Cleanup(login_service_test_cleanup)
 }
 
+func TestPatchLoginData(t *testing.T) {
+	login_service_test_init()
+
+	login_data := make(map[string]interface{})
+	login_data["name"] = "github"
+	login_data["url"] = "https://github.com"
+	login_data["accounts"] = []interface{}{map[string]interface{}{"username": "abc", "password": "123"}, map[string]interface{}{"username": "pqr", "password": "456"}, map[string]interface{}{"username": "xyz", "password": "789"}}
+	login_data["attributes"] = map[string]interface{}{"is_favourite": true, "require_master_password": false}
+
+	login_service := new(LoginDataService)
+	login_service.Init()
+
+	err := login_service.AddLoginData(login_data)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	patch_data := make(map[string]interface{})
+	patch_data["url"] = "https://github.com/login"
+	patch_data["attributes"] = map[string]interface{}{"is_favourite": false}
+	patch_data["add_accounts"] = []interface{}{map[string]interface{}{"username": "new", "password": "000"}}
+	patch_data["update_accounts"] = []interface{}{map[string]interface{}{"username": "abc", "password": "111"}, map[string]interface{}{"username": "pqr", "new_username": "renamed"}}
+	patch_data["remove_accounts"] = []interface{}{"xyz"}
+
+	version, err := login_service.PatchLoginData("github", patch_data, "")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if version == "" {
+		t.Error("Version should be returned")
+	}
+
+	expected_login_data := models.Login{Name: "github", URL: "https://github.com/login", Attributes: models.Attributes{IsFavourite: false, RequireMasterPassword: false}, Accounts: []models.Account{{Username: "abc"}, {Username: "renamed"}, {Username: "new"}}}
+
+	fetched_login_data, err := login_service.GetLoginData("github")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	compareLoginData(t, expected_login_data, fetched_login_data)
+
+	for username, expected_password := range map[string]string{"abc": "111", "renamed": "456", "new": "000"} {
+		decrypted_password, err := login_service.GetDecryptedAccountPassword("github", username)
+
+		if err != nil {
+			t.Error(err.Error())
+		}
+
+		if decrypted_password != expected_password {
+			t.Errorf("Expected: %s\nActual: %s", expected_password, decrypted_password)
+		}
+	}
+
+	t.Cleanup(login_service_test_cleanup)
+}
+
+func TestPatchLoginData_Conflict(t *testing.T) {
+	login_service_test_init()
+
+	login_data := make(map[string]interface{})
+	login_data["name"] = "github"
+	login_data["url"] = "https://github.com"
+	login_data["accounts"] = []interface{}{map[string]interface{}{"username": "abc", "password": "123"}}
+	login_data["attributes"] = map[string]interface{}{"is_favourite": true, "require_master_password": false}
+
+	login_service := new(LoginDataService)
+	login_service.Init()
+
+	login_service.AddLoginData(login_data)
+
+	//Both windows read the same version
+	version, err := login_service.GetLoginDataVersion("github")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	_, err = login_service.PatchLoginData("github", map[string]interface{}{"url": "https://github.com/first"}, version)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	_, err = login_service.PatchLoginData("github", map[string]interface{}{"url": "https://github.com/second"}, version)
+
+	if err != database.ErrConflict {
+		t.Errorf("Expected: %v\nActual: %v", database.ErrConflict, err)
+	}
+
+	fetched_login_data, err := login_service.GetLoginData("github")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if fetched_login_data.URL != "https://github.com/first" {
+		t.Errorf("Expected: %s\nActual: %s", "https://github.com/first", fetched_login_data.URL)
+	}
+
+	t.Cleanup(login_service_test_cleanup)
+}
+
+func TestPatchLoginData_InvalidUsername(t *testing.T) {
+	login_service_test_init()
+
+	login_data := make(map[string]interface{})
+	login_data["name"] = "github"
+	login_data["url"] = "https://github.com"
+	login_data["accounts"] = []interface{}{map[string]interface{}{"username": "abc", "password": "123"}, map[string]interface{}{"username": "pqr", "password": "456"}}
+	login_data["attributes"] = map[string]interface{}{"is_favourite": true, "require_master_password": false}
+
+	login_service := new(LoginDataService)
+	login_service.Init()
+
+	login_service.AddLoginData(login_data)
+
+	_, err := login_service.PatchLoginData("github", map[string]interface{}{"remove_accounts": []interface{}{"xyz"}}, "")
+
+	if err == nil {
+		t.Error("Should fail as account username is not found")
+	}
+
+	_, err = login_service.PatchLoginData("github", map[string]interface{}{"update_accounts": []interface{}{map[string]interface{}{"username": "abc", "new_username": "pqr"}}}, "")
+
+	if err == nil {
+		t.Error("Should fail as username is duplicated")
+	}
+
+	t.Cleanup(login_service_test_cleanup)
+}
+
+func checkTOTPCode(t *testing.T, secret string, totp_code models.TOTPCode) {
+	key := totp.Key{Secret: secret}
+
+	//Code might roll over between generating and checking
+	expected_code, _, _ := key.GenerateCode(time.Now())
+	previous_code, _, _ := key.GenerateCode(time.Now().Add(-time.Duration(totp.DEFAULT_PERIOD) * time.Second))
+
+	if totp_code.Code != expected_code && totp_code.Code != previous_code {
+		t.Errorf("Expected: %s\nActual: %s", expected_code, totp_code.Code)
+	}
+
+	if totp_code.RemainingSeconds <= 0 || totp_code.RemainingSeconds > totp.DEFAULT_PERIOD {
+		t.Errorf("Invalid remaining seconds: %d", totp_code.RemainingSeconds)
+	}
+}
+
+func TestGetTOTPCode(t *testing.T) {
+	login_service_test_init()
+
+	login_data := make(map[string]interface{})
+	login_data["name"] = "github"
+	login_data["url"] = "https://github.com"
+	login_data["accounts"] = []interface{}{
+		map[string]interface{}{"username": "abc", "password": "123", "totp_secret": map[string]interface{}{"secret": "otpauth://totp/GitHub:abc?secret=JBSWY3DPEHPK3PXP&issuer=GitHub"}},
+		map[string]interface{}{"username": "pqr", "password": "456"},
+	}
+	login_data["attributes"] = map[string]interface{}{"is_favourite": true, "require_master_password": false}
+
+	login_service := new(LoginDataService)
+	login_service.Init()
+
+	err := login_service.AddLoginData(login_data)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	fetched_login_data, err := login_service.GetLoginData("github")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if strings.Contains(fetched_login_data.Accounts[0].TOTPSecret.Secret, "JBSWY3DPEHPK3PXP") {
+		t.Error("TOTP secret should be encrypted")
+	}
+
+	totp_code, err := login_service.GetTOTPCode("github", "abc")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	checkTOTPCode(t, "JBSWY3DPEHPK3PXP", totp_code)
+
+	_, err = login_service.GetTOTPCode("github", "pqr")
+
+	if err == nil {
+		t.Error("Should fail as TOTP secret is not set")
+	}
+
+	//TOTP secret should be re-encrypted along with passwords
+	master_password_service := new(MasterPasswordService)
+	master_password_service.Init()
+
+	err = master_password_service.UpdateMasterPassword("12345", "123")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	totp_code, err = login_service.GetTOTPCode("github", "abc")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	checkTOTPCode(t, "JBSWY3DPEHPK3PXP", totp_code)
+
+	t.Cleanup(login_service_test_cleanup)
+}
+
+func TestAddLoginData_InvalidTOTPSecret(t *testing.T) {
+	login_service_test_init()
+
+	login_data := make(map[string]interface{})
+	login_data["name"] = "github"
+	login_data["url"] = "https://github.com"
+	login_data["accounts"] = []interface{}{map[string]interface{}{"username": "abc", "password": "123", "totp_secret": map[string]interface{}{"secret": "otpauth://hotp/abc?secret=JBSWY3DPEHPK3PXP"}}}
+	login_data["attributes"] = map[string]interface{}{"is_favourite": true, "require_master_password": false}
+
+	login_service := new(LoginDataService)
+	login_service.Init()
+
+	err := login_service.AddLoginData(login_data)
+
+	if err == nil {
+		t.Error("Should fail as only totp uris are supported")
+	}
+
+	t.Cleanup(login_service_test_cleanup)
+}
+
 func TestGetDecryptedAccountPassword_ValidUsername(t *testing.T) {
 	login_service_test_init()
 
@@ -524,11 +810,10 @@ func TestLoginDataImport(t *testing.T) {
 	login_service := new(LoginDataService)
 	login_service.Init()
 
-	err := login_service.importData(login_datas)
-
-	if err != nil {
-		t.Error(err.Error())
-	}
+	commitTestData(t, func(transaction database.ITransaction) error {
+		_, err := login_service.importData(transaction, login_datas, false)
+		return err
+	})
 
 	fetched_data_list, err := login_service.GetAllLoginData()
 
@@ -587,7 +872,14 @@ func TestLoginDataRecrpyt(t *testing.T) {
 	data["OLD_PASSWORD"] = old_password
 	data["NEW_PASSWORD"] = "123"
 
-	err = login_service.recryptData(data)
+	transaction := database.BeginBadgerTransaction()
+	err = login_service.recryptData(transaction, data)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	err = transaction.Commit()
 
 	if err != nil {
 		t.Error(err.Error())
@@ -607,7 +899,378 @@ func TestLoginDataRecrpyt(t *testing.T) {
 	t.Cleanup(login_service_test_cleanup)
 }
 
+func TestGetDecryptedAccountPassword_LegacyCiphertext(t *testing.T) {
+	master_password_service := new(MasterPasswordService)
+	master_password_service.Init()
+
+	//Vault created before Argon2id was introduced
+	commitTestData(t, func(transaction database.ITransaction) error {
+		return master_password_service.importData(transaction, encryptor.CreateHash("12345"))
+	})
+	master_password_service.Validate("12345")
+
+	//Encrypted using unversioned AES-CBC with key - CreateHash("12345")+"github"+"abc"
+	login := models.Login{Name: "github", URL: "https://github.com", Accounts: []models.Account{{Username: "abc", Password: "2dc977633c2caaff637bf7d46a07c2ad1528a861f957272bbc49208ac8de61a5"}}, Attributes: models.Attributes{IsFavourite: true, RequireMasterPassword: false}}
+
+	login_service := new(LoginDataService)
+	login_service.Init()
+
+	commitTestData(t, func(transaction database.ITransaction) error {
+		_, err := login_service.importData(transaction, []models.Login{login}, false)
+		return err
+	})
+
+	fetched_password, err := login_service.GetDecryptedAccountPassword(login.Name, "abc")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if fetched_password != "123" {
+		t.Errorf("Expected: %s\nActual: %s", "123", fetched_password)
+	}
+
+	//Updating should re-encrypt using the current version
+	login_data := map[string]interface{}{
+		"name":       login.Name,
+		"url":        login.URL,
+		"accounts":   []interface{}{map[string]interface{}{"username": "abc", "password": login.Accounts[0].Password}},
+		"attributes": map[string]interface{}{"is_favourite": true, "require_master_password": false},
+	}
+
+	err = login_service.UpdateLoginData(login.Name, login_data)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	fetched_data, err := login_service.GetLoginData(login.Name)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if encryptor.Version(fetched_data.Accounts[0].Password) != encryptor.CURRENT_VERSION {
+		t.Error("Password should be upgraded on write")
+	}
+
+	fetched_password, err = login_service.GetDecryptedAccountPassword(login.Name, "abc")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if fetched_password != "123" {
+		t.Errorf("Expected: %s\nActual: %s", "123", fetched_password)
+	}
+
+	t.Cleanup(login_service_test_cleanup)
+}
+
+func TestLoginDataMigrate(t *testing.T) {
+	master_password_service := new(MasterPasswordService)
+	master_password_service.Init()
+
+	//Vault created before Argon2id was introduced
+	commitTestData(t, func(transaction database.ITransaction) error {
+		return master_password_service.importData(transaction, encryptor.CreateHash("12345"))
+	})
+	master_password_service.Validate("12345")
+
+	//Encrypted using unversioned AES-CBC with key - CreateHash("12345")+"github"+"abc"
+	login := models.Login{Name: "github", URL: "https://github.com", Accounts: []models.Account{{Username: "abc", Password: "2dc977633c2caaff637bf7d46a07c2ad1528a861f957272bbc49208ac8de61a5"}}, Attributes: models.Attributes{IsFavourite: true, RequireMasterPassword: false}}
+
+	login_service := new(LoginDataService)
+	login_service.Init()
+
+	commitTestData(t, func(transaction database.ITransaction) error {
+		_, err := login_service.importData(transaction, []models.Login{login}, false)
+		return err
+	})
+
+	err := master_password_service.migrateData()
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	fetched_data, err := login_service.GetLoginData(login.Name)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if encryptor.Version(fetched_data.Accounts[0].Password) != encryptor.CURRENT_VERSION {
+		t.Error("Password should be migrated")
+	}
+
+	fetched_password, err := login_service.GetDecryptedAccountPassword(login.Name, "abc")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if fetched_password != "123" {
+		t.Errorf("Expected: %s\nActual: %s", "123", fetched_password)
+	}
+
+	t.Cleanup(login_service_test_cleanup)
+}
+
+func TestGetDecryptedAccountPassword_Tampered(t *testing.T) {
+	login_service_test_init()
+
+	login_service := new(LoginDataService)
+	login_service.Init()
+
+	for _, name := range []string{"github", "gitlab"} {
+		login_data := make(map[string]interface{})
+		login_data["name"] = name
+		login_data["url"] = "https://" + name + ".com"
+		login_data["accounts"] = []interface{}{map[string]interface{}{"username": "abc", "password": name + "_password"}}
+		login_data["attributes"] = map[string]interface{}{"is_favourite": true, "require_master_password": false}
+
+		login_service.AddLoginData(login_data)
+	}
+
+	github, _ := login_service.GetLoginData("github")
+	gitlab, _ := login_service.GetLoginData("gitlab")
+
+	//Swap ciphertexts between entries
+	github.Accounts[0].Password, gitlab.Accounts[0].Password = gitlab.Accounts[0].Password, github.Accounts[0].Password
+	commitTestData(t, func(transaction database.ITransaction) error {
+		_, err := login_service.importData(transaction, []models.Login{github, gitlab}, false)
+		return err
+	})
+
+	_, err := login_service.GetDecryptedAccountPassword("github", "abc")
+
+	if err != encryptor.ErrTampered {
+		t.Errorf("Expected: %v\nActual: %v", encryptor.ErrTampered, err)
+	}
+
+	t.Cleanup(login_service_test_cleanup)
+}
+
+func checkAccountPassword(t *testing.T, login_service *LoginDataService, login_data_name string, account_username string, expected_password string) {
+	password, err := login_service.GetDecryptedAccountPassword(login_data_name, account_username)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if password != expected_password {
+		t.Errorf("Expected: %s\nActual: %s", expected_password, password)
+	}
+}
+
+func checkPasswordHistoryLength(t *testing.T, login_service *LoginDataService, login_data_name string, account_username string, expected_length int) {
+	history, err := login_service.GetPasswordHistory(login_data_name, account_username)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if len(history) != expected_length {
+		t.Errorf("Expected: %d\nActual: %d", expected_length, len(history))
+	}
+}
+
+func TestPasswordHistory(t *testing.T) {
+	login_service_test_init()
+
+	login_data := make(map[string]interface{})
+	login_data["name"] = "github"
+	login_data["url"] = "https://github.com"
+	login_data["accounts"] = []interface{}{map[string]interface{}{"username": "abc", "password": "123"}, map[string]interface{}{"username": "pqr", "password": "456"}}
+	login_data["attributes"] = map[string]interface{}{"is_favourite": true, "require_master_password": false}
+
+	login_service := new(LoginDataService)
+	login_service.Init()
+
+	err := login_service.AddLoginData(login_data)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	fetched_login_data, _ := login_service.GetLoginData("github")
+
+	//Unchanged passwords are sent back encrypted
+	login_data["accounts"] = []interface{}{map[string]interface{}{"username": "abc", "password": "789"}, map[string]interface{}{"username": "pqr", "password": fetched_login_data.Accounts[1].Password}}
+
+	err = login_service.UpdateLoginData("github", login_data)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	checkPasswordHistoryLength(t, login_service, "github", "abc", 1)
+	checkPasswordHistoryLength(t, login_service, "github", "pqr", 0)
+
+	//Setting the same password again does not add to history
+	for _, password := range []string{"000", "000"} {
+		_, err = login_service.PatchLoginData("github", map[string]interface{}{"update_accounts": []interface{}{map[string]interface{}{"username": "abc", "password": password}}}, "")
+
+		if err != nil {
+			t.Error(err.Error())
+		}
+	}
+
+	checkPasswordHistoryLength(t, login_service, "github", "abc", 2)
+
+	//History is bound to login name and has to be carried over on rename
+	fetched_login_data, _ = login_service.GetLoginData("github")
+	login_data["name"] = "gitlab"
+	login_data["accounts"] = []interface{}{map[string]interface{}{"username": "abc", "password": fetched_login_data.Accounts[0].Password}, map[string]interface{}{"username": "pqr", "password": fetched_login_data.Accounts[1].Password}}
+
+	err = login_service.UpdateLoginData("github", login_data)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	checkPasswordHistoryLength(t, login_service, "gitlab", "abc", 2)
+
+	//History is ["789", "123"]
+	err = login_service.RestorePassword("gitlab", "abc", 1)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	checkAccountPassword(t, login_service, "gitlab", "abc", "123")
+	checkPasswordHistoryLength(t, login_service, "gitlab", "abc", 2)
+
+	//History is ["000", "789"]
+	err = login_service.RestorePassword("gitlab", "abc", 0)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	checkAccountPassword(t, login_service, "gitlab", "abc", "000")
+
+	if err = login_service.RestorePassword("gitlab", "abc", 2); err == nil {
+		t.Error("Should fail for invalid history index")
+	}
+
+	if err = login_service.RestorePassword("gitlab", "pqr", 0); err == nil {
+		t.Error("Should fail as account has no history")
+	}
+
+	t.Cleanup(login_service_test_cleanup)
+}
+
+func TestPasswordHistory_Bounded(t *testing.T) {
+	login_service_test_init()
+
+	login_data := make(map[string]interface{})
+	login_data["name"] = "github"
+	login_data["url"] = "https://github.com"
+	login_data["accounts"] = []interface{}{map[string]interface{}{"username": "abc", "password": "0"}}
+	login_data["attributes"] = map[string]interface{}{"is_favourite": true, "require_master_password": false}
+
+	login_service := new(LoginDataService)
+	login_service.Init()
+
+	err := login_service.AddLoginData(login_data)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	for index := range MAX_PASSWORD_HISTORY + 5 {
+		_, err = login_service.PatchLoginData("github", map[string]interface{}{"update_accounts": []interface{}{map[string]interface{}{"username": "abc", "password": strconv.Itoa(index + 1)}}}, "")
+
+		if err != nil {
+			t.Error(err.Error())
+		}
+	}
+
+	checkPasswordHistoryLength(t, login_service, "github", "abc", MAX_PASSWORD_HISTORY)
+
+	//Oldest entries are dropped
+	err = login_service.RestorePassword("github", "abc", MAX_PASSWORD_HISTORY-1)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	checkAccountPassword(t, login_service, "github", "abc", "5")
+
+	t.Cleanup(login_service_test_cleanup)
+}
+
+func TestPasswordHistory_UpdateMasterPassword(t *testing.T) {
+	login_service_test_init()
+
+	login_data := make(map[string]interface{})
+	login_data["name"] = "github"
+	login_data["url"] = "https://github.com"
+	login_data["accounts"] = []interface{}{map[string]interface{}{"username": "abc", "password": "123"}}
+	login_data["attributes"] = map[string]interface{}{"is_favourite": true, "require_master_password": false}
+
+	login_service := new(LoginDataService)
+	login_service.Init()
+
+	err := login_service.AddLoginData(login_data)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	_, err = login_service.PatchLoginData("github", map[string]interface{}{"update_accounts": []interface{}{map[string]interface{}{"username": "abc", "password": "456"}}}, "")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	fetched_login_data, _ := login_service.GetLoginData("github")
+	old_ciphertext := fetched_login_data.Accounts[0].PasswordHistory[0].Password
+
+	master_password_service := new(MasterPasswordService)
+	master_password_service.Init()
+
+	err = master_password_service.UpdateMasterPassword("12345", "54321")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	fetched_login_data, _ = login_service.GetLoginData("github")
+
+	if fetched_login_data.Accounts[0].PasswordHistory[0].Password == old_ciphertext {
+		t.Error("Password history should be re-encrypted")
+	}
+
+	err = login_service.RestorePassword("github", "abc", 0)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	checkAccountPassword(t, login_service, "github", "abc", "123")
+
+	t.Cleanup(login_service_test_cleanup)
+}
+
 func login_service_test_cleanup() {
+	database.Close()
 	os.RemoveAll(os.Getenv("STORAGE_FOLDER"))
 	os.RemoveAll("logs")
 }
+
+// Stage test data using a transaction and commit it
+func commitTestData(t *testing.T, stage func(transaction database.ITransaction) error) {
+	transaction := database.BeginBadgerTransaction()
+
+	if err := stage(transaction); err != nil {
+		transaction.Rollback()
+		t.Fatal(err.Error())
+	}
+
+	if err := transaction.Commit(); err != nil {
+		t.Fatal(err.Error())
+	}
+}
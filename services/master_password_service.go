@@ -1,16 +1,47 @@
 package services
 
 import (
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
 	"ncrypt/utils"
 	"ncrypt/utils/database"
 	"ncrypt/utils/encryptor"
+	"ncrypt/utils/jwt"
 	"ncrypt/utils/logger"
 	"os"
+	"sync"
 
 	"github.com/joho/godotenv"
 )
 
+var ErrVaultLocked = errors.New("vault is locked")
+
+// Keys derived from the master password. Only kept in memory while the vault is unlocked
+type masterKeys struct {
+	current string // Argon2id derived key
+	legacy  string // Unsalted SHA-256 hash used to encrypt data before Argon2id was introduced
+}
+
+// Get master key material the given ciphertext was encrypted with
+func (keys masterKeys) forCiphertext(ciphertext string) string {
+	if encryptor.Version(ciphertext) == encryptor.LEGACY_VERSION {
+		return keys.legacy
+	}
+	return keys.current
+}
+
+// Check if ciphertext has to be re-encrypted to move it to new_key and the current ciphertext version
+func (keys masterKeys) needsRecrypt(ciphertext string, new_key string) bool {
+	return keys.current != new_key || encryptor.Version(ciphertext) != encryptor.CURRENT_VERSION
+}
+
+var (
+	unlocked_keys_lock sync.RWMutex
+	unlocked_record    string
+	unlocked_keys      masterKeys
+)
+
 type MasterPasswordService struct {
 	database database.IDatabase
 }
@@ -33,33 +64,49 @@ func (obj *MasterPasswordService) Init() {
 // Function to set master_password
 func (obj *MasterPasswordService) SetMasterPassword(master_password string) error {
 	logger.Log.Printf("Setting master pasword")
-	master_password = encryptor.CreateHash(master_password)
-	logger.Log.Printf("Created hash")
+	record, keys, err := createMasterPassword(master_password)
 
-	err := obj.database.AddData(os.Getenv("MASTER_PASSWORD_KEY"), master_password)
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	err = obj.database.AddData(os.Getenv("MASTER_PASSWORD_KEY"), record)
 
 	if err != nil {
 		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
 	}
 
+	unlock(record, keys)
+
 	logger.Log.Printf("Saved to database!")
 	return err
 }
 
+// Derive keys from master password using a new salt. Returns record to be stored in database along with the derived keys
+func createMasterPassword(master_password string) (string, masterKeys, error) {
+	params, err := encryptor.NewKDFParams()
+
+	if err != nil {
+		return "", masterKeys{}, err
+	}
+
+	key, verifier := encryptor.DeriveKey(master_password, params)
+	logger.Log.Printf("Derived key")
+
+	return encryptor.EncodeKDFRecord(params, verifier), masterKeys{current: hex.EncodeToString(key), legacy: encryptor.CreateHash(master_password)}, nil
+}
+
 /*
 	Update master_password
 
 1. Decrypt all encrypted content using old master_password
-2. Encrypt all encrpyed content using new master_passwordl̥
+2. Encrypt all encrpyed content using new master_password
+3. Save new master_password and re-encrypted content in a single transaction, so a failure leaves the vault unchanged
 */
 func (obj *MasterPasswordService) UpdateMasterPassword(old_master_password string, new_master_password string) error {
 	logger.Log.Printf("Updating master pasword")
-	stored_master_password_hash, err := obj.GetMasterPassword()
-
-	if err != nil {
-		logger.Log.Printf("ERROR: %s", err.Error())
-		return nil
-	}
 
 	logger.Log.Print("Validating old password")
 	result, err := obj.Validate(old_master_password)
@@ -74,20 +121,95 @@ func (obj *MasterPasswordService) UpdateMasterPassword(old_master_password strin
 		return err
 	}
 
-	new_master_password_hash := encryptor.CreateHash(new_master_password)
+	old_keys, err := obj.getMasterKeys()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
 
 	logger.Log.Print("Checking if new password is same as old password")
-	if new_master_password_hash == stored_master_password_hash {
+	if old_master_password == new_master_password {
 		return errors.New("new password cannot be same as old password")
 	}
 
-	err = obj.SetMasterPassword(new_master_password)
+	new_record, new_keys, err := createMasterPassword(new_master_password)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	transaction := database.BeginBadgerTransaction()
+
+	err = transaction.AddData(obj.database, os.Getenv("MASTER_PASSWORD_KEY"), new_record)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		transaction.Rollback()
+		return err
+	}
+
+	err = obj.recryptData(transaction, old_keys, new_keys.current)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		transaction.Rollback()
+		return err
+	}
+
+	logger.Log.Printf("Committing changes")
+	err = transaction.Commit()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	unlock(new_record, new_keys)
+
+	//Tokens issued before the change are no longer valid
+	jwt.RevokeAllSessions()
+
+	logger.Log.Printf("Master password updated!")
+	recordAuditEvent(AUDIT_MASTER_PASSWORD_UPDATED, "", "")
+
+	return nil
+}
+
+// Re-encrypt data written using an older ciphertext version with the current key
+func (obj *MasterPasswordService) migrateData() error {
+	logger.Log.Printf("Migrating data to current ciphertext version")
+	keys, err := obj.getMasterKeys()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	transaction := database.BeginBadgerTransaction()
+
+	err = obj.recryptData(transaction, keys, keys.current)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		transaction.Rollback()
+		return err
+	}
+
+	err = transaction.Commit()
 
 	if err != nil {
 		logger.Log.Printf("ERROR: %s", err.Error())
-		return nil
+		return err
 	}
 
+	logger.Log.Printf("Migration completed!")
+	return nil
+}
+
+// Broadcast event to re-encrypt data across services. Re-encrypted data is added to the given transaction
+func (obj *MasterPasswordService) recryptData(transaction database.ITransaction, old_keys masterKeys, new_key string) error {
 	logger.Log.Printf("Creating new broadcast")
 	//Setup broadcast to update encrypted data across services
 	broadcast := utils.NewBroadcast()
@@ -95,18 +217,23 @@ func (obj *MasterPasswordService) UpdateMasterPassword(old_master_password strin
 	logger.Log.Printf("Subscribing login service to listen for changes")
 	login_service := InitBadgerLoginService()
 	login_service.Init()
-	broadcast.Subscribe("UPDATE_MASTER_PASSWORD", login_service.recryptData)
+	broadcast.Subscribe("UPDATE_MASTER_PASSWORD", func(data map[string]string) error {
+		return login_service.recryptData(transaction, data)
+	})
 
 	logger.Log.Printf("Subscribing note service to listen for changes")
 	note_service := InitBadgerNoteService()
 	note_service.Init()
-	broadcast.Subscribe("UPDATE_MASTER_PASSWORD", note_service.recryptData)
+	broadcast.Subscribe("UPDATE_MASTER_PASSWORD", func(data map[string]string) error {
+		return note_service.recryptData(transaction, data)
+	})
 
 	logger.Log.Printf("Creating event")
 
 	data_map := make(map[string]string)
-	data_map["OLD_PASSWORD"] = stored_master_password_hash
-	data_map["NEW_PASSWORD"] = new_master_password_hash
+	data_map["OLD_PASSWORD"] = old_keys.current
+	data_map["OLD_LEGACY_PASSWORD"] = old_keys.legacy
+	data_map["NEW_PASSWORD"] = new_key
 
 	event_data := utils.Event{
 		Type: "UPDATE_MASTER_PASSWORD",
@@ -114,17 +241,19 @@ func (obj *MasterPasswordService) UpdateMasterPassword(old_master_password strin
 	}
 
 	logger.Log.Printf("Broadcasting event")
-	broadcast.Publish(event_data)
-
-	logger.Log.Printf("Master password updated!")
-
-	return nil
+	return broadcast.Publish(event_data)
 }
 
+/*
+Validate master password and unlock the vault on success.
+
+Vaults created before Argon2id was introduced store an unsalted SHA-256 hash. These are upgraded on the first successful validation,
+existing ciphertexts are still decrypted using the legacy hash and re-encrypted on their next write.
+*/
 func (obj *MasterPasswordService) Validate(password string) (bool, error) {
 
 	logger.Log.Printf("Validating master password")
-	stored_password, err := obj.GetMasterPassword()
+	stored_record, err := obj.getMasterPasswordRecord()
 
 	if err != nil {
 		logger.Log.Printf("ERROR: %s", err.Error())
@@ -132,26 +261,121 @@ func (obj *MasterPasswordService) Validate(password string) (bool, error) {
 	}
 
 	logger.Log.Printf("Comparing password...")
-	result := stored_password == encryptor.CreateHash(password)
+	keys, is_valid, err := deriveMasterKeys(stored_record, password)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return false, err
+	}
+
+	if !is_valid {
+		return false, nil
+	}
+
+	if encryptor.IsLegacyHash(stored_record) {
+		logger.Log.Printf("Upgrading master password to Argon2id")
+		err = obj.SetMasterPassword(password)
+
+		if err != nil {
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	unlock(stored_record, keys)
 
 	logger.Log.Printf("Validation completed!")
-	return result, nil
+	return true, nil
 }
 
+// Check password against a master password record without unlocking the vault. Returns keys derived from the password if it matches
+func deriveMasterKeys(record string, password string) (masterKeys, bool, error) {
+	legacy_key := encryptor.CreateHash(password)
+
+	// Legacy records are the unsalted hash itself and only legacy ciphertexts exist alongside them
+	if encryptor.IsLegacyHash(record) {
+		return masterKeys{legacy: legacy_key}, subtle.ConstantTimeCompare([]byte(record), []byte(legacy_key)) == 1, nil
+	}
+
+	params, stored_verifier, err := encryptor.ParseKDFRecord(record)
+
+	if err != nil {
+		return masterKeys{}, false, err
+	}
+
+	key, verifier := encryptor.DeriveKey(password, params)
+
+	if subtle.ConstantTimeCompare(verifier, stored_verifier) != 1 {
+		return masterKeys{}, false, nil
+	}
+
+	return masterKeys{current: hex.EncodeToString(key), legacy: legacy_key}, true, nil
+}
+
+// Get key derived from master password. Vault has to be unlocked using Validate or SetMasterPassword
 func (obj *MasterPasswordService) GetMasterPassword() (string, error) {
 	logger.Log.Printf("Getting master password")
-	fetched_data, err := obj.database.GetData(os.Getenv("MASTER_PASSWORD_KEY"))
+	keys, err := obj.getMasterKeys()
+
+	if err != nil {
+		return "", err
+	}
+
+	return keys.current, nil
+}
+
+func (obj *MasterPasswordService) getMasterKeys() (masterKeys, error) {
+	stored_record, err := obj.getMasterPasswordRecord()
 
 	if err != nil {
 		logger.Log.Printf("ERROR: %s", err.Error())
+		return masterKeys{}, err
+	}
+
+	unlocked_keys_lock.RLock()
+	defer unlocked_keys_lock.RUnlock()
+
+	if unlocked_record != stored_record {
+		logger.Log.Printf("ERROR: %s", ErrVaultLocked.Error())
+		return masterKeys{}, ErrVaultLocked
+	}
+
+	return unlocked_keys, nil
+}
+
+func (obj *MasterPasswordService) getMasterPasswordRecord() (string, error) {
+	fetched_data, err := obj.database.GetData(os.Getenv("MASTER_PASSWORD_KEY"))
+
+	if err != nil {
 		return "", err
 	}
 
 	return fetched_data.(string), err
 }
 
-func (obj *MasterPasswordService) importData(password string) error {
-	err := obj.database.AddData(os.Getenv("MASTER_PASSWORD_KEY"), password)
+func unlock(record string, keys masterKeys) {
+	unlocked_keys_lock.Lock()
+	defer unlocked_keys_lock.Unlock()
+
+	unlocked_record = record
+	unlocked_keys = keys
+}
+
+// Forget unlocked keys, the master password has to be validated again to access encrypted data
+func lock() {
+	unlock("", masterKeys{})
+	clearSearchIndex()
+}
+
+// Stage removal of master password record. Vault has to be set up again afterwards
+func (obj *MasterPasswordService) deleteData(transaction database.ITransaction) error {
+	return transaction.DeleteData(obj.database, os.Getenv("MASTER_PASSWORD_KEY"))
+}
+
+func (obj *MasterPasswordService) importData(transaction database.ITransaction, password string) error {
+	err := transaction.AddData(obj.database, os.Getenv("MASTER_PASSWORD_KEY"), password)
 
 	return err
 }
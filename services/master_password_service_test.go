@@ -1,9 +1,14 @@
 package services
 
 import (
+	"ncrypt/models"
+	"ncrypt/utils/database"
 	"ncrypt/utils/encryptor"
+	"ncrypt/utils/jwt"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSetMasterPassword(t *testing.T) {
@@ -52,6 +57,77 @@ func TestUpdateMasterPassword(t *testing.T) {
 	t.Cleanup(master_password_service_test_cleanup)
 }
 
+func TestUpdateMasterPassword_RevokesSessions(t *testing.T) {
+	service := new(MasterPasswordService)
+	service.Init()
+
+	service.SetMasterPassword("12345")
+	jwt.GenerateToken(5)
+
+	if err := service.UpdateMasterPassword("12345", "123"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if sessions := jwt.GetSessions(); len(sessions) != 0 {
+		t.Errorf("Expected sessions to be revoked\n%+v", sessions)
+	}
+
+	t.Cleanup(master_password_service_test_cleanup)
+}
+
+func TestUpdateMasterPassword_FailureHalfway(t *testing.T) {
+	service := new(MasterPasswordService)
+	service.Init()
+
+	service.SetMasterPassword("12345")
+
+	login_data := make(map[string]interface{})
+	login_data["name"] = "github"
+	login_data["url"] = "https://github.com"
+	login_data["accounts"] = []interface{}{map[string]interface{}{"username": "abc", "password": "123"}}
+	login_data["attributes"] = map[string]interface{}{"is_favourite": true, "require_master_password": false}
+
+	login_service := new(LoginDataService)
+	login_service.Init()
+	login_service.AddLoginData(login_data)
+
+	//Note that cannot be decrypted fails re-encryption after login data is re-encrypted
+	note_service := new(NoteService)
+	note_service.Init()
+	commitTestData(t, func(transaction database.ITransaction) error {
+		_, err := note_service.importData(transaction, []models.Note{{CreatedDateTime: "testing1", Title: "test1", Content: encryptor.VERSION_PREFIX + "03zz"}}, false)
+		return err
+	})
+
+	err := service.UpdateMasterPassword("12345", "123")
+
+	if err == nil {
+		t.Error("Update should fail")
+	}
+
+	result, err := service.Validate("12345")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if !result {
+		t.Error("Old password should still be valid")
+	}
+
+	decrypted_password, err := login_service.GetDecryptedAccountPassword("github", "abc")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if decrypted_password != "123" {
+		t.Errorf("Expected: %s\nActual: %s", "123", decrypted_password)
+	}
+
+	t.Cleanup(master_password_service_test_cleanup)
+}
+
 func TestValidate_PASS(t *testing.T) {
 	new_password := map[string]string{"master_password": "12345"}
 
@@ -107,7 +183,7 @@ func TestValidate_FAIL(t *testing.T) {
 func TestGetMasterPassword(t *testing.T) {
 	password := "12345"
 
-	hashed_password := encryptor.CreateHash(password)
+	legacy_hash := encryptor.CreateHash(password)
 
 	service := new(MasterPasswordService)
 	service.Init()
@@ -120,25 +196,122 @@ func TestGetMasterPassword(t *testing.T) {
 		t.Error(err.Error())
 	}
 
-	if stored_password != hashed_password {
-		t.Error("Password mismatch")
+	if stored_password == "" || stored_password == legacy_hash {
+		t.Error("Key should be derived using Argon2id")
+	}
+
+	stored_record, err := service.getMasterPasswordRecord()
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if !strings.HasPrefix(stored_record, "$argon2id$") || strings.Contains(stored_record, stored_password) {
+		t.Errorf("Invalid stored record: %s", stored_record)
 	}
 
 	t.Cleanup(master_password_service_test_cleanup)
 }
 
-func TestMasterPasswordImport(t *testing.T) {
+func TestGetMasterPassword_Locked(t *testing.T) {
 	service := new(MasterPasswordService)
 	service.Init()
 
-	imported_password := "12345"
-	err := service.importData(imported_password)
+	service.SetMasterPassword("12345")
 
+	//Replace stored record to simulate a vault that has not been unlocked
+	params, err := encryptor.NewKDFParams()
 	if err != nil {
 		t.Error(err.Error())
 	}
+	_, verifier := encryptor.DeriveKey("12345", params)
+	commitTestData(t, func(transaction database.ITransaction) error {
+		return service.importData(transaction, encryptor.EncodeKDFRecord(params, verifier))
+	})
 
-	stored_password, err := service.GetMasterPassword()
+	_, err = service.GetMasterPassword()
+
+	if err != ErrVaultLocked {
+		t.Errorf("Expected: %v\nActual: %v", ErrVaultLocked, err)
+	}
+
+	result, err := service.Validate("12345")
+
+	if err != nil || !result {
+		t.Error("Validation should unlock vault")
+	}
+
+	_, err = service.GetMasterPassword()
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	t.Cleanup(master_password_service_test_cleanup)
+}
+
+func TestValidate_UpgradeLegacyHash(t *testing.T) {
+	service := new(MasterPasswordService)
+	service.Init()
+
+	legacy_hash := encryptor.CreateHash("12345")
+	commitTestData(t, func(transaction database.ITransaction) error {
+		return service.importData(transaction, legacy_hash)
+	})
+
+	result, err := service.Validate("123")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if result {
+		t.Errorf("Expected: %t\nActual: %t", false, result)
+	}
+
+	result, err = service.Validate("12345")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if !result {
+		t.Errorf("Expected: %t\nActual: %t", true, result)
+	}
+
+	stored_record, err := service.getMasterPasswordRecord()
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if !strings.HasPrefix(stored_record, "$argon2id$") {
+		t.Error("Legacy hash should be upgraded")
+	}
+
+	master_keys, err := service.getMasterKeys()
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if master_keys.legacy != legacy_hash {
+		t.Error("Legacy hash should be available to decrypt old data")
+	}
+
+	t.Cleanup(master_password_service_test_cleanup)
+}
+
+func TestMasterPasswordImport(t *testing.T) {
+	service := new(MasterPasswordService)
+	service.Init()
+
+	imported_password := "12345"
+	commitTestData(t, func(transaction database.ITransaction) error {
+		return service.importData(transaction, imported_password)
+	})
+
+	stored_password, err := service.getMasterPasswordRecord()
 
 	if err != nil {
 		t.Error(err.Error())
@@ -151,6 +324,60 @@ func TestMasterPasswordImport(t *testing.T) {
 	t.Cleanup(master_password_service_test_cleanup)
 }
 
+func TestSession(t *testing.T) {
+	service := new(MasterPasswordService)
+	service.Init()
+
+	service.SetMasterPassword("12345")
+
+	token, err := service.ExportSession(time.Minute)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Lock vault as a new CLI process would start
+	unlock("", masterKeys{})
+
+	if err = service.ResumeSession("invalid"); err == nil {
+		t.Error("Invalid token should be rejected")
+	}
+
+	if err = service.ResumeSession(token); err != nil {
+		t.Error(err.Error())
+	}
+
+	if _, err = service.getMasterKeys(); err != nil {
+		t.Errorf("Vault should be unlocked, got %s", err.Error())
+	}
+
+	service.UpdateMasterPassword("12345", "123")
+
+	if err = service.ResumeSession(token); err != ErrSessionExpired {
+		t.Errorf("Expected: %v\nActual: %v", ErrSessionExpired, err)
+	}
+
+	token, err = service.ExportSession(-time.Minute)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err = service.ResumeSession(token); err != ErrSessionExpired {
+		t.Errorf("Expected: %v\nActual: %v", ErrSessionExpired, err)
+	}
+
+	token, _ = service.ExportSession(time.Minute)
+	service.EndSession()
+
+	if err = service.ResumeSession(token); err != ErrVaultLocked {
+		t.Errorf("Expected: %v\nActual: %v", ErrVaultLocked, err)
+	}
+
+	t.Cleanup(master_password_service_test_cleanup)
+}
+
 func master_password_service_test_cleanup() {
+	database.Close()
 	os.RemoveAll(os.Getenv("STORAGE_FOLDER"))
 }
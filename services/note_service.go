@@ -8,6 +8,7 @@ import (
 	"ncrypt/utils/logger"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/dgraph-io/badger/v4"
 	"github.com/joho/godotenv"
@@ -65,7 +66,7 @@ func (obj *NoteService) GetAllNotes() ([]models.Note, error) {
 
 func (obj *NoteService) GetDecryptedContent(created_date_time string) (string, error) {
 	logger.Log.Printf("Decrypting note content")
-	master_password, err := obj.master_password_service.GetMasterPassword()
+	master_keys, err := obj.master_password_service.getMasterKeys()
 
 	if err != nil {
 		logger.Log.Printf("ERROR: " + err.Error())
@@ -79,13 +80,15 @@ func (obj *NoteService) GetDecryptedContent(created_date_time string) (string, e
 		return "", err
 	}
 
-	decrypted_content, err := encryptor.Decrypt(fetched_note.Content, master_password+fetched_note.CreatedDateTime)
+	decrypted_content, err := encryptor.Decrypt(fetched_note.Content, master_keys.forCiphertext(fetched_note.Content)+fetched_note.CreatedDateTime, fetched_note.CreatedDateTime)
 
 	if err != nil {
 		logger.Log.Printf("ERROR: " + err.Error())
 		return "", err
 	}
 
+	recordAuditEvent(AUDIT_REVEAL_NOTE, fetched_note.CreatedDateTime, "")
+
 	return decrypted_content, err
 }
 
@@ -116,7 +119,7 @@ func (obj *NoteService) AddNote(new_note map[string]interface{}) error {
 	}
 
 	logger.Log.Printf("Encrypting content")
-	encrypted_content, err := encryptor.Encrypt(note.Content, master_password_hash+note.CreatedDateTime)
+	encrypted_content, err := encryptor.Encrypt(note.Content, master_password_hash+note.CreatedDateTime, note.CreatedDateTime)
 
 	if err != nil {
 		return err
@@ -127,6 +130,11 @@ func (obj *NoteService) AddNote(new_note map[string]interface{}) error {
 	logger.Log.Printf("Storing to DB")
 	err = obj.database.AddData(note.CreatedDateTime, note)
 
+	if err == nil {
+		dataChanged()
+		recordAuditEvent(AUDIT_NOTE_CREATED, note.CreatedDateTime, "")
+	}
+
 	return err
 }
 func (obj *NoteService) UpdateNote(created_date_time string, updated_note map[string]interface{}) error {
@@ -147,7 +155,7 @@ func (obj *NoteService) UpdateNote(created_date_time string, updated_note map[st
 	var note models.Note
 	note.FromMap(updated_note)
 
-	encrypted_content, err := encryptor.Encrypt(note.Content, master_password+created_date_time)
+	encrypted_content, err := encryptor.Encrypt(note.Content, master_password+fetched_note.CreatedDateTime, fetched_note.CreatedDateTime)
 
 	if err == nil {
 		note.Content = encrypted_content
@@ -155,7 +163,14 @@ func (obj *NoteService) UpdateNote(created_date_time string, updated_note map[st
 
 	note.CreatedDateTime = fetched_note.CreatedDateTime
 
-	return obj.database.AddData(created_date_time, (&note))
+	err = obj.database.AddData(created_date_time, (&note))
+
+	if err == nil {
+		dataChanged()
+		recordAuditEvent(AUDIT_NOTE_UPDATED, created_date_time, "")
+	}
+
+	return err
 }
 
 func (obj *NoteService) DeleteNote(created_date_time string) error {
@@ -164,10 +179,13 @@ func (obj *NoteService) DeleteNote(created_date_time string) error {
 
 	if err != nil {
 		logger.Log.Printf("ERROR: %s", err.Error())
+	} else {
+		dataChanged()
+		recordAuditEvent(AUDIT_NOTE_DELETED, created_date_time, "")
 	}
 	return err
 }
-func (obj *NoteService) recryptData(password_data map[string]string) error {
+func (obj *NoteService) recryptData(transaction database.ITransaction, password_data map[string]string) error {
 	logger.Log.Printf("Recrypting notes content")
 	notes, err := obj.GetAllNotes()
 
@@ -177,11 +195,16 @@ func (obj *NoteService) recryptData(password_data map[string]string) error {
 	}
 
 	logger.Log.Printf("Decrypting all notes content")
-	old_password := password_data["OLD_PASSWORD"]
+	old_keys := masterKeys{current: password_data["OLD_PASSWORD"], legacy: password_data["OLD_LEGACY_PASSWORD"]}
 	new_password := password_data["NEW_PASSWORD"]
 
+	var updated_notes []models.Note
 	for i := range len(notes) {
-		decrypted_content, err := encryptor.Decrypt(notes[i].Content, old_password+notes[i].CreatedDateTime)
+		if !old_keys.needsRecrypt(notes[i].Content, new_password) {
+			continue
+		}
+
+		decrypted_content, err := encryptor.Decrypt(notes[i].Content, old_keys.forCiphertext(notes[i].Content)+notes[i].CreatedDateTime, notes[i].CreatedDateTime)
 
 		if err != nil {
 			logger.Log.Printf("ERROR: " + err.Error())
@@ -189,32 +212,189 @@ func (obj *NoteService) recryptData(password_data map[string]string) error {
 		}
 
 		notes[i].Content = decrypted_content
+		updated_notes = append(updated_notes, notes[i])
 	}
 
 	logger.Log.Printf("Recrypting content")
-	for i := range len(notes) {
-		notes[i].Content, err = encryptor.Encrypt(notes[i].Content, new_password+notes[i].CreatedDateTime)
+	for i := range len(updated_notes) {
+		updated_notes[i].Content, err = encryptor.Encrypt(updated_notes[i].Content, new_password+updated_notes[i].CreatedDateTime, updated_notes[i].CreatedDateTime)
 
 		if err != nil {
 			logger.Log.Printf("ERROR: " + err.Error())
 			return err
 		}
-		obj.database.AddData(notes[i].CreatedDateTime, notes[i])
+
+		err = transaction.AddData(obj.database, updated_notes[i].CreatedDateTime, updated_notes[i])
+
+		if err != nil {
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return err
+		}
 	}
 
 	return nil
 }
 
-func (obj *NoteService) importData(notes []models.Note) error {
+// Decrypt note content. Returns a copy, given note is left as is
+func decryptNote(note models.Note, master_keys masterKeys) (models.Note, error) {
+	decrypted_content, err := encryptor.Decrypt(note.Content, master_keys.forCiphertext(note.Content)+note.CreatedDateTime, note.CreatedDateTime)
+
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	note.Content = decrypted_content
+	return note, nil
+}
+
+/*
+Stage already encrypted notes from an export as is. If replace_existing is set, notes not in the export are deleted so the vault
+ends up with exactly the exported notes.
+*/
+func (obj *NoteService) importData(transaction database.ITransaction, notes []models.Note, replace_existing bool) (models.ImportChanges, error) {
 	logger.Log.Printf("Importing notes")
+	var changes models.ImportChanges
+
+	existing_notes, err := obj.GetAllNotes()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: " + err.Error())
+		return changes, err
+	}
+
+	existing_keys := make(map[string]bool)
+	for _, note := range existing_notes {
+		existing_keys[note.CreatedDateTime] = true
+	}
 
+	imported_keys := make(map[string]bool)
 	for _, note := range notes {
-		err := obj.database.AddData(note.CreatedDateTime, note)
+		imported_keys[note.CreatedDateTime] = true
+
+		if existing_keys[note.CreatedDateTime] {
+			changes.Overwritten = append(changes.Overwritten, note.CreatedDateTime)
+		} else {
+			changes.Added = append(changes.Added, note.CreatedDateTime)
+		}
+
+		err = transaction.AddData(obj.database, note.CreatedDateTime, note)
 		if err != nil {
 			logger.Log.Printf("ERROR: " + err.Error())
-			return err
+			return changes, err
 		}
 	}
 
-	return nil
+	if replace_existing {
+		for _, note := range existing_notes {
+			if imported_keys[note.CreatedDateTime] {
+				continue
+			}
+
+			changes.Removed = append(changes.Removed, note.CreatedDateTime)
+			err = transaction.DeleteData(obj.database, note.CreatedDateTime)
+
+			if err != nil {
+				logger.Log.Printf("ERROR: " + err.Error())
+				return changes, err
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+/*
+Stage decrypted notes into the vault, encrypted with the current master password.
+
+Notes whose created date time already exists are handled according to strategy:
+  - KEEP_EXISTING: imported note is skipped
+  - OVERWRITE: existing note is replaced
+  - KEEP_BOTH: " (1)", " (2)", ... is added to created date time and title of the imported note
+*/
+func (obj *NoteService) mergeData(transaction database.ITransaction, notes []models.Note, strategy string) (models.ImportChanges, error) {
+	logger.Log.Printf("Merging notes")
+	var changes models.ImportChanges
+
+	master_keys, err := obj.master_password_service.getMasterKeys()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: " + err.Error())
+		return changes, err
+	}
+
+	existing_notes, err := obj.GetAllNotes()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: " + err.Error())
+		return changes, err
+	}
+
+	taken_keys := make(map[string]bool)
+	for _, note := range existing_notes {
+		taken_keys[note.CreatedDateTime] = true
+	}
+
+	is_taken := func(created_date_time string) bool {
+		return taken_keys[created_date_time]
+	}
+
+	for _, note := range notes {
+		if is_taken(note.CreatedDateTime) {
+			switch strategy {
+			case models.IMPORT_KEEP_EXISTING:
+				changes.Skipped = append(changes.Skipped, note.CreatedDateTime)
+				continue
+			case models.IMPORT_OVERWRITE:
+				changes.Overwritten = append(changes.Overwritten, note.CreatedDateTime)
+			case models.IMPORT_KEEP_BOTH:
+				new_created_date_time := uniqueCreatedDateTime(note.CreatedDateTime, is_taken)
+				changes.Renamed = append(changes.Renamed, models.ImportRename{From: note.CreatedDateTime, To: new_created_date_time})
+				note.CreatedDateTime = new_created_date_time
+			default:
+				return changes, errors.New("invalid import strategy " + strategy)
+			}
+		} else {
+			changes.Added = append(changes.Added, note.CreatedDateTime)
+		}
+
+		taken_keys[note.CreatedDateTime] = true
+
+		// Created date time is part of the associated data, so renamed notes are encrypted after renaming
+		note.Content, err = encryptor.Encrypt(note.Content, master_keys.current+note.CreatedDateTime, note.CreatedDateTime)
+
+		if err != nil {
+			logger.Log.Printf("ERROR: " + err.Error())
+			return changes, err
+		}
+
+		err = transaction.AddData(obj.database, note.CreatedDateTime, note)
+
+		if err != nil {
+			logger.Log.Printf("ERROR: " + err.Error())
+			return changes, err
+		}
+	}
+
+	return changes, nil
+}
+
+/*
+Get a created date time that is not taken by moving it forward a millisecond at a time, so the key of a copied note is still a
+valid time and sorts next to the original. Keys that are not RFC3339 times get a numbered suffix instead.
+*/
+func uniqueCreatedDateTime(created_date_time string, is_taken func(string) bool) string {
+	created_time, err := time.Parse(time.RFC3339Nano, created_date_time)
+
+	if err != nil {
+		return uniqueName(created_date_time, is_taken)
+	}
+
+	for {
+		created_time = created_time.Add(time.Millisecond)
+		candidate := created_time.Format(time.RFC3339Nano)
+
+		if !is_taken(candidate) {
+			return candidate
+		}
+	}
 }
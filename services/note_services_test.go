@@ -2,6 +2,7 @@ package services
 
 import (
 	"ncrypt/models"
+	"ncrypt/utils/database"
 	"os"
 	"strings"
 	"testing"
@@ -426,11 +427,10 @@ func TestNoteImport(t *testing.T) {
 	note_service := new(NoteService)
 	note_service.Init()
 
-	err := note_service.importData(note_datas)
-
-	if err != nil {
-		t.Error(err.Error())
-	}
+	commitTestData(t, func(transaction database.ITransaction) error {
+		_, err := note_service.importData(transaction, note_datas, false)
+		return err
+	})
 
 	fetched_note_list, err := note_service.GetAllNotes()
 
@@ -489,7 +489,14 @@ func TestNoteRecrpyt(t *testing.T) {
 	data["OLD_PASSWORD"] = old_password
 	data["NEW_PASSWORD"] = "123"
 
-	err = note_service.recryptData(data)
+	transaction := database.BeginBadgerTransaction()
+	err = note_service.recryptData(transaction, data)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	err = transaction.Commit()
 
 	if err != nil {
 		t.Error(err.Error())
@@ -510,6 +517,28 @@ func TestNoteRecrpyt(t *testing.T) {
 }
 
 func note_service_test_cleanup() {
+	database.Close()
 	os.RemoveAll(os.Getenv("STORAGE_FOLDER"))
 	os.RemoveAll("logs")
 }
+
+func TestUniqueCreatedDateTime(t *testing.T) {
+	taken := map[string]bool{"2024-01-01T00:00:00Z": true, "2024-01-01T00:00:00.001Z": true, "123": true}
+	is_taken := func(created_date_time string) bool { return taken[created_date_time] }
+
+	test_cases := []struct {
+		created_date_time string
+		expected          string
+	}{
+		{"2024-01-01T00:00:00Z", "2024-01-01T00:00:00.002Z"},
+		{"2024-01-01T05:30:00+05:30", "2024-01-01T05:30:00.001+05:30"},
+		// Not a time, so a suffix is added
+		{"123", "123 (1)"},
+	}
+
+	for _, test_case := range test_cases {
+		if actual := uniqueCreatedDateTime(test_case.created_date_time, is_taken); actual != test_case.expected {
+			t.Errorf("%s\nExpected: %s\nActual: %s", test_case.created_date_time, test_case.expected, actual)
+		}
+	}
+}
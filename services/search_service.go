@@ -0,0 +1,304 @@
+package services
+
+import (
+	"errors"
+	"ncrypt/models"
+	"ncrypt/utils"
+	"ncrypt/utils/logger"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	DEFAULT_SEARCH_PAGE_SIZE = 20
+	MAX_SEARCH_PAGE_SIZE     = 100
+
+	// Characters of note content shown before and after the first match
+	SEARCH_SNIPPET_CONTEXT = 30
+)
+
+const (
+	SEARCH_LOGIN = "LOGIN"
+	SEARCH_NOTE  = "NOTE"
+)
+
+// Weight of a match in each field, a match in the name counts more than one in the content
+var search_field_weights = map[string]float64{
+	"name":     1.0,
+	"title":    1.0,
+	"username": 0.8,
+	"url":      0.6,
+	"content":  0.5,
+}
+
+type indexedContent struct {
+	ciphertext string
+	content    string
+}
+
+/*
+Decrypted note content by created date time, used to search content.
+
+Only built in memory once the vault is unlocked and cleared when it is locked. An entry is decrypted again when the stored
+ciphertext changes, so edits, imports and master password changes are picked up on the next search.
+*/
+var (
+	content_index_lock sync.Mutex
+	content_index      = make(map[string]indexedContent)
+)
+
+// Forget decrypted note content, called when the vault is locked
+func clearSearchIndex() {
+	content_index_lock.Lock()
+	defer content_index_lock.Unlock()
+
+	content_index = make(map[string]indexedContent)
+}
+
+type SearchService struct {
+	login_service           ILoginDataService
+	note_service            INoteService
+	master_password_service IMasterPasswordService
+}
+
+func (obj *SearchService) Init() {
+	logger.Log.Printf("Initializing search service")
+
+	obj.login_service = InitBadgerLoginService()
+	obj.login_service.Init()
+
+	obj.note_service = InitBadgerNoteService()
+	obj.note_service.Init()
+
+	obj.master_password_service = InitBadgerMasterPasswordService()
+	obj.master_password_service.Init()
+
+	logger.Log.Printf("DONE")
+}
+
+/*
+Search logins by name, URL and account usernames and notes by title, and by content if is_content_included.
+
+Every word of the query has to match a field, see utils.FuzzyMatch. Results are ranked favourites first, then by score.
+Content of notes that require master password is never searched. Searching content needs the vault to be unlocked.
+*/
+func (obj *SearchService) Search(query string, is_content_included bool, page int, page_size int) (models.SearchPage, error) {
+	logger.Log.Printf("Searching")
+
+	terms := strings.Fields(query)
+
+	if len(terms) == 0 {
+		err := errors.New("search query is empty")
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.SearchPage{}, err
+	}
+
+	if page_size == 0 {
+		page_size = DEFAULT_SEARCH_PAGE_SIZE
+	}
+
+	if page < 1 || page_size < 1 || page_size > MAX_SEARCH_PAGE_SIZE {
+		err := errors.New("invalid page or page size")
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.SearchPage{}, err
+	}
+
+	login_data_list, err := obj.login_service.GetAllLoginData()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.SearchPage{}, err
+	}
+
+	notes, err := obj.note_service.GetAllNotes()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.SearchPage{}, err
+	}
+
+	var contents map[string]string
+	if is_content_included {
+		contents, err = obj.getNoteContents(notes)
+
+		if err != nil {
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return models.SearchPage{}, err
+		}
+	}
+
+	results := []models.SearchResult{}
+
+	for _, login_data := range login_data_list {
+		fields := []searchField{{"name", login_data.Name}, {"url", login_data.URL}}
+		for _, account := range login_data.Accounts {
+			fields = append(fields, searchField{"username", account.Username})
+		}
+
+		if score, matches := matchFields(fields, terms); score > 0 {
+			results = append(results, models.SearchResult{Type: SEARCH_LOGIN, ID: login_data.Name, Title: login_data.Name, IsFavourite: login_data.Attributes.IsFavourite, Score: score, Matches: matches})
+		}
+	}
+
+	for _, note := range notes {
+		fields := []searchField{{"title", note.Title}}
+		if content, ok := contents[note.CreatedDateTime]; ok {
+			fields = append(fields, searchField{"content", content})
+		}
+
+		if score, matches := matchFields(fields, terms); score > 0 {
+			results = append(results, models.SearchResult{Type: SEARCH_NOTE, ID: note.CreatedDateTime, Title: note.Title, IsFavourite: note.Attributes.IsFavourite, Score: score, Matches: matches})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].IsFavourite != results[j].IsFavourite {
+			return results[i].IsFavourite
+		}
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return strings.ToUpper(results[i].Title) < strings.ToUpper(results[j].Title)
+	})
+
+	search_page := models.SearchPage{Query: query, Total: len(results), Page: page, PageSize: page_size, Results: []models.SearchResult{}}
+
+	// Checked before multiplying, so a huge page cannot overflow into a negative start
+	if page-1 <= len(results)/page_size {
+		start := (page - 1) * page_size
+		search_page.Results = results[start:min(start+page_size, len(results))]
+	}
+
+	logger.Log.Printf("DONE")
+	return search_page, nil
+}
+
+// Get decrypted content of notes that do not require master password, decrypting only notes changed since the last search
+func (obj *SearchService) getNoteContents(notes []models.Note) (map[string]string, error) {
+	master_keys, err := obj.master_password_service.getMasterKeys()
+
+	if err != nil {
+		return nil, err
+	}
+
+	content_index_lock.Lock()
+	defer content_index_lock.Unlock()
+
+	updated_index := make(map[string]indexedContent, len(notes))
+	contents := make(map[string]string, len(notes))
+
+	for _, note := range notes {
+		if note.Attributes.RequireMasterPassword {
+			continue
+		}
+
+		indexed, ok := content_index[note.CreatedDateTime]
+
+		if !ok || indexed.ciphertext != note.Content {
+			decrypted_note, err := decryptNote(note, master_keys)
+
+			if err != nil {
+				logger.Log.Printf("ERROR: %s", err.Error())
+				continue
+			}
+
+			indexed = indexedContent{ciphertext: note.Content, content: decrypted_note.Content}
+		}
+
+		updated_index[note.CreatedDateTime] = indexed
+		contents[note.CreatedDateTime] = indexed.content
+	}
+
+	// Deleted notes are dropped
+	content_index = updated_index
+
+	return contents, nil
+}
+
+type searchField struct {
+	name  string
+	value string
+}
+
+/*
+Match every term against the fields. Returns 0 if any term matches no field.
+
+Score is the average over terms of the best weighted field score. Matches hold the ranges of all terms per field.
+*/
+func matchFields(fields []searchField, terms []string) (float64, []models.SearchMatch) {
+	ranges := make([][]models.TextRange, len(fields))
+	total_score := 0.0
+
+	for _, term := range terms {
+		best_score := 0.0
+
+		for index, field := range fields {
+			// Characters of a term are spread over long content too easily
+			score, match_ranges := utils.FuzzyMatch(field.value, term, field.name != "content")
+
+			if score == 0 {
+				continue
+			}
+
+			ranges[index] = append(ranges[index], match_ranges...)
+			best_score = max(best_score, score*search_field_weights[field.name])
+		}
+
+		if best_score == 0 {
+			return 0, nil
+		}
+
+		total_score += best_score
+	}
+
+	var matches []models.SearchMatch
+	for index, field := range fields {
+		if len(ranges[index]) == 0 {
+			continue
+		}
+
+		match := models.SearchMatch{Field: field.name, Value: field.value, Ranges: mergeRanges(ranges[index])}
+		if field.name == "content" {
+			match = snippet(match)
+		}
+
+		matches = append(matches, match)
+	}
+
+	return total_score / float64(len(terms)), matches
+}
+
+// Sort ranges and join overlapping ones
+func mergeRanges(ranges []models.TextRange) []models.TextRange {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+
+	merged := []models.TextRange{ranges[0]}
+	for _, current := range ranges[1:] {
+		last := &merged[len(merged)-1]
+
+		if current.Start <= last.End {
+			last.End = max(last.End, current.End)
+		} else {
+			merged = append(merged, current)
+		}
+	}
+
+	return merged
+}
+
+// Cut value down to SEARCH_SNIPPET_CONTEXT characters around the first range, keeping ranges inside the snippet
+func snippet(match models.SearchMatch) models.SearchMatch {
+	runes := []rune(match.Value)
+	start := max(0, match.Ranges[0].Start-SEARCH_SNIPPET_CONTEXT)
+	end := min(len(runes), match.Ranges[0].End+SEARCH_SNIPPET_CONTEXT)
+
+	result := models.SearchMatch{Field: match.Field, Value: string(runes[start:end])}
+	for _, match_range := range match.Ranges {
+		if match_range.Start >= start && match_range.End <= end {
+			result.Ranges = append(result.Ranges, models.TextRange{Start: match_range.Start - start, End: match_range.End - start})
+		}
+	}
+
+	return result
+}
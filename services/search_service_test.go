@@ -0,0 +1,191 @@
+package services
+
+import (
+	"math"
+	"ncrypt/models"
+	"ncrypt/utils/database"
+	"os"
+	"slices"
+	"testing"
+)
+
+func search_service_test_init(t *testing.T) *SearchService {
+	master_password_service := new(MasterPasswordService)
+	master_password_service.Init()
+	master_password_service.SetMasterPassword("12345")
+
+	login_service := InitBadgerLoginService()
+	login_service.Init()
+
+	note_service := InitBadgerNoteService()
+	note_service.Init()
+
+	for _, login_data := range []map[string]interface{}{
+		{"name": "github", "url": "https://github.com", "is_favourite": false, "username": "octocat"},
+		{"name": "gitlab", "url": "https://gitlab.com", "is_favourite": true, "username": "tanuki"},
+		{"name": "bank", "url": "https://mybank.example", "is_favourite": false, "username": "octocat@mail.com"},
+	} {
+		err := login_service.AddLoginData(map[string]interface{}{
+			"name":       login_data["name"],
+			"url":        login_data["url"],
+			"attributes": map[string]interface{}{"is_favourite": login_data["is_favourite"], "require_master_password": false},
+			"accounts":   []interface{}{map[string]interface{}{"username": login_data["username"], "password": "123"}},
+		})
+
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	for _, note := range []map[string]interface{}{
+		{"created_date_time": "1", "title": "wifi", "content": "router password is on the sticker", "require_master_password": false},
+		{"created_date_time": "2", "title": "recovery codes", "content": "github recovery codes 1234-5678", "require_master_password": true},
+	} {
+		err := note_service.AddNote(map[string]interface{}{
+			"created_date_time": note["created_date_time"],
+			"title":             note["title"],
+			"content":           note["content"],
+			"attributes":        map[string]interface{}{"is_favourite": false, "require_master_password": note["require_master_password"]},
+		})
+
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	search_service := InitSearchService()
+	search_service.Init()
+
+	return search_service
+}
+
+func searchResultIDs(results []models.SearchResult) []string {
+	var ids []string
+	for _, result := range results {
+		ids = append(ids, result.ID)
+	}
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	search_service := search_service_test_init(t)
+
+	test_cases := []struct {
+		query        string
+		expected_ids []string
+	}{
+		// Favourites first
+		{"git", []string{"gitlab", "github"}},
+		{"GitHub", []string{"github"}},
+		{"gitbub", []string{"github"}},
+		{"octocat", []string{"github", "bank"}},
+		{"mybank", []string{"bank"}},
+		{"octocat mail", []string{"bank"}},
+		{"wifi", []string{"1"}},
+		{"sticker", nil},
+		{"dropbox", nil},
+	}
+
+	for _, test_case := range test_cases {
+		search_page, err := search_service.Search(test_case.query, false, 1, 0)
+
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if ids := searchResultIDs(search_page.Results); !slices.Equal(ids, test_case.expected_ids) {
+			t.Errorf("%s\nExpected: %v\nActual: %v", test_case.query, test_case.expected_ids, ids)
+		}
+	}
+
+	search_page, _ := search_service.Search("hub", false, 1, 0)
+	match := search_page.Results[0].Matches[0]
+
+	if match.Field != "name" || match.Value != "github" || len(match.Ranges) != 1 || match.Ranges[0] != (models.TextRange{Start: 3, End: 6}) {
+		t.Errorf("Unexpected highlight %+v", search_page.Results[0].Matches)
+	}
+
+	if _, err := search_service.Search("  ", false, 1, 0); err == nil {
+		t.Error("Should fail for empty query")
+	}
+
+	t.Cleanup(search_service_test_cleanup)
+}
+
+func TestSearch_Content(t *testing.T) {
+	search_service := search_service_test_init(t)
+
+	search_page, err := search_service.Search("sticker", true, 1, 0)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(search_page.Results) != 1 || search_page.Results[0].ID != "1" {
+		t.Fatalf("Expected note 1, got %v", searchResultIDs(search_page.Results))
+	}
+
+	match := search_page.Results[0].Matches[0]
+	if match.Field != "content" || []rune(match.Value)[match.Ranges[0].Start] != 's' {
+		t.Errorf("Unexpected highlight %+v", match)
+	}
+
+	// Content of notes that require master password is not searched
+	if search_page, _ := search_service.Search("1234-5678", true, 1, 0); len(search_page.Results) != 0 {
+		t.Errorf("Protected note should not be found, got %v", searchResultIDs(search_page.Results))
+	}
+
+	// Edited content is decrypted again
+	note_service := InitBadgerNoteService()
+	note_service.Init()
+	note_service.UpdateNote("1", map[string]interface{}{"created_date_time": "1", "title": "wifi", "content": "password is on the fridge", "attributes": map[string]interface{}{"is_favourite": false, "require_master_password": false}})
+
+	if search_page, _ := search_service.Search("fridge", true, 1, 0); len(search_page.Results) != 1 {
+		t.Error("Updated content should be found")
+	}
+
+	lock()
+
+	if len(content_index) != 0 {
+		t.Error("Decrypted content should be cleared on lock")
+	}
+
+	if _, err := search_service.Search("fridge", true, 1, 0); err != ErrVaultLocked {
+		t.Errorf("Expected %v, got %v", ErrVaultLocked, err)
+	}
+
+	t.Cleanup(search_service_test_cleanup)
+}
+
+func TestSearch_Pagination(t *testing.T) {
+	search_service := search_service_test_init(t)
+
+	search_page, err := search_service.Search("o", false, 2, 2)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if search_page.Total != 4 || len(search_page.Results) != 2 || search_page.Page != 2 || search_page.PageSize != 2 {
+		t.Errorf("Unexpected page %+v", search_page)
+	}
+
+	if search_page, _ := search_service.Search("o", false, 3, 2); len(search_page.Results) != 0 {
+		t.Error("Page past the end should be empty")
+	}
+
+	if search_page, err := search_service.Search("o", false, math.MaxInt, 2); err != nil || len(search_page.Results) != 0 {
+		t.Errorf("Page past the end should be empty, got %v %v", searchResultIDs(search_page.Results), err)
+	}
+
+	if _, err := search_service.Search("o", false, 1, MAX_SEARCH_PAGE_SIZE+1); err == nil {
+		t.Error("Should fail for page size above maximum")
+	}
+
+	t.Cleanup(search_service_test_cleanup)
+}
+
+func search_service_test_cleanup() {
+	database.Close()
+	os.RemoveAll(os.Getenv("STORAGE_FOLDER"))
+}
@@ -1,28 +1,51 @@
 package services
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"io"
+	"fmt"
 	"ncrypt/models"
 	"ncrypt/utils"
+	"ncrypt/utils/backup"
+	"ncrypt/utils/breach"
 	"ncrypt/utils/database"
 	"ncrypt/utils/encryptor"
+	"ncrypt/utils/importer"
 	"ncrypt/utils/jwt"
 	"ncrypt/utils/logger"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
-	"unsafe"
 
 	"github.com/dgraph-io/badger/v4"
 	"github.com/joho/godotenv"
 )
 
+// Passwords not changed for longer than this are flagged by the audit unless another age is requested
+const DEFAULT_PASSWORD_MAX_AGE_IN_DAYS = 90
+
+const (
+	FREE_SIGN_IN_ATTEMPTS = 3               // Failed attempts allowed before lockout starts
+	BASE_SIGN_IN_LOCKOUT  = 5 * time.Second // Doubled for every further failed attempt
+	MAX_SIGN_IN_LOCKOUT   = time.Hour
+
+	MIN_WIPE_AFTER_FAILED_ATTEMPTS = 5 // Lower values risk wiping the vault on a few typos
+)
+
+var (
+	ErrSignInLocked = errors.New("too many failed attempts")
+	ErrVaultWiped   = errors.New("too many failed attempts, vault has been wiped")
+)
+
+// Held while checking a master password, so concurrent attempts cannot skip the lockout
+var sign_in_lock sync.Mutex
+
 type SystemService struct {
 	database                    database.IDatabase
 	database_name               string
@@ -30,24 +53,10 @@ type SystemService struct {
 	SESSION_DURATION_IN_MINUTES int
 }
 
+// Initialize system service and launch the UI set using SetUILauncher
 func (obj *SystemService) Init() {
+	obj.InitHeadless()
 
-	logger.Log.Printf("Initializing system service")
-	logger.Log.Printf("Setting up database")
-	godotenv.Load("../.env")
-
-	obj.database = database.InitBadgerDb()
-	obj.database_name = "SYSTEM"
-	obj.database.SetDatabase(obj.database_name)
-
-	logger.Log.Printf("Setting up master password service")
-	obj.master_password_service = InitBadgerMasterPasswordService()
-	obj.master_password_service.Init()
-
-	obj.SESSION_DURATION_IN_MINUTES = 20 //20 minutes is default
-	logger.Log.Printf("System service initialized")
-
-	// Code to launch UI - comment these lines to prevent launching of multiple UI instances while testing.
 	system_data, err := obj.GetSystemData()
 
 	isNewUser := "false"
@@ -61,54 +70,29 @@ func (obj *SystemService) Init() {
 		theme = system_data.Theme
 	}
 
-	go obj.launchUI(os.Getenv("UI_EXECUTABLE_PATH"), []string{utils.PORT, isNewUser, theme})
-	restoreAndBringWindowToFront("NCRYPT")
-}
+	err = getUILauncher().Launch([]string{utils.PORT, isNewUser, theme}, func() { obj.Logout() })
 
-func (obj *SystemService) launchUI(commandPath string, args []string) {
-	cmd := exec.Command(commandPath, args...)
-	// Run the command and wait for it to complete
-	err := cmd.Run()
 	if err != nil {
-		// Handle error
-		return
+		logger.Log.Printf("ERROR: %s", err.Error())
 	}
-	obj.Logout()
-	os.Exit(0)
 }
 
-func restoreAndBringWindowToFront(title string) error {
-	user32 := syscall.NewLazyDLL("user32.dll")
-
-	findWindow := user32.NewProc("FindWindowW")
-	setForegroundWindow := user32.NewProc("SetForegroundWindow")
-
-	showWindow := user32.NewProc("ShowWindow")
-	const SW_RESTORE = 9
-
-	// Convert string to UTF16
-	u16Title, err := syscall.UTF16PtrFromString(title)
-	if err != nil {
-		return err
-	}
-
-	// Find the window by its title
-	hwnd, _, err := findWindow.Call(0, uintptr(unsafe.Pointer(u16Title)))
-	if hwnd == 0 {
-		logger.Log.Printf("ERROR: window not found: %v", err.Error())
-		return err
-	}
+// Set up database and dependent services without launching the UI, used by the CLI and background jobs
+func (obj *SystemService) InitHeadless() {
+	logger.Log.Printf("Initializing system service")
+	logger.Log.Printf("Setting up database")
+	godotenv.Load("../.env")
 
-	// Restore the window if minimized
-	showWindow.Call(hwnd, SW_RESTORE)
+	obj.database = database.InitBadgerDb()
+	obj.database_name = "SYSTEM"
+	obj.database.SetDatabase(obj.database_name)
 
-	// Bring the window to the foreground
-	_, _, err = setForegroundWindow.Call(hwnd)
-	if err != nil {
-		return err
-	}
+	logger.Log.Printf("Setting up master password service")
+	obj.master_password_service = InitBadgerMasterPasswordService()
+	obj.master_password_service.Init()
 
-	return nil
+	obj.SESSION_DURATION_IN_MINUTES = 20 //20 minutes is default
+	logger.Log.Printf("System service initialized")
 }
 
 func (obj *SystemService) initSystem(system_data models.SystemData) error {
@@ -157,7 +141,7 @@ func (obj *SystemService) Setup(master_password string, auto_backup_setting map[
 	_, err := obj.master_password_service.GetMasterPassword()
 
 	if err != nil {
-		if err != badger.ErrKeyNotFound {
+		if err != badger.ErrKeyNotFound && err != ErrVaultLocked {
 			logger.Log.Printf("ERROR: %s", err.Error())
 			return err
 		}
@@ -180,6 +164,11 @@ func (obj *SystemService) Setup(master_password string, auto_backup_setting map[
 	password_generator_preferance.HasUpperCase = false
 	password_generator_preferance.HasSpecialChar = false
 	password_generator_preferance.Length = 8
+	password_generator_preferance.SpecialCharSet = models.DEFAULT_SPECIAL_CHAR_SET
+	password_generator_preferance.ExcludeAmbiguous = true
+	password_generator_preferance.Mode = models.PASSWORD_MODE
+	password_generator_preferance.WordCount = models.DEFAULT_WORD_COUNT
+	password_generator_preferance.WordSeparator = models.DEFAULT_WORD_SEPARATOR
 
 	err = obj.initSystem(models.SystemData{LoginCount: 0, LastLoginDateTime: "", CurrentLoginDateTime: "", IsLoggedIn: false, PasswordGeneratorPreference: *password_generator_preferance, AutoBackupSetting: *new_auto_backup_setting, SessionDurationInMinutes: obj.SESSION_DURATION_IN_MINUTES, Theme: "SYSTEM"})
 	if err != nil {
@@ -193,7 +182,7 @@ func (obj *SystemService) Setup(master_password string, auto_backup_setting map[
 
 func (obj *SystemService) SignIn(password string) (string, error) {
 	logger.Log.Printf("Logging in")
-	result, err := obj.master_password_service.Validate(password)
+	result, err := obj.ValidateMasterPassword(password)
 
 	if err != nil {
 		logger.Log.Printf("ERROR: %s", err.Error())
@@ -205,6 +194,11 @@ func (obj *SystemService) SignIn(password string) (string, error) {
 		return "", errors.New("invalid password")
 	}
 
+	//Data written by older versions is upgraded once the vault is unlocked
+	if err := obj.master_password_service.migrateData(); err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+	}
+
 	system_data, err := obj.GetSystemData()
 	if err != nil {
 		logger.Log.Printf("ERROR: %s", err.Error())
@@ -213,6 +207,10 @@ func (obj *SystemService) SignIn(password string) (string, error) {
 
 	system_data.IsLoggedIn = true
 	system_data.LoginCount += 1
+
+	// Shown to the user until the next sign in
+	system_data.FailedSignInsBeforeLogin = system_data.FailedSignIn.SinceLastLogin
+	system_data.FailedSignIn.SinceLastLogin = models.FailedSignInRecord{}
 	system_data.CurrentLoginDateTime = time.Now().Format(time.RFC3339)
 
 	err = obj.setSystemData(*system_data)
@@ -223,6 +221,7 @@ func (obj *SystemService) SignIn(password string) (string, error) {
 	}
 
 	logger.Log.Printf("Logged in")
+	recordAuditEvent(AUDIT_SIGN_IN, "", "")
 
 	token, err := jwt.GenerateToken(system_data.SessionDurationInMinutes)
 
@@ -234,8 +233,214 @@ func (obj *SystemService) SignIn(password string) (string, error) {
 	return token, nil
 }
 
+/*
+Validate master password with brute-force protection.
+
+After FREE_SIGN_IN_ATTEMPTS consecutive failures, further attempts are refused with ErrSignInLocked for BASE_SIGN_IN_LOCKOUT,
+doubling with every failure up to MAX_SIGN_IN_LOCKOUT. The count is persisted in system data and reset by a correct password.
+If WipeAfterFailedAttempts is set, all logins, notes, the master password and system data are deleted once reached.
+*/
+func (obj *SystemService) ValidateMasterPassword(password string) (bool, error) {
+	sign_in_lock.Lock()
+	defer sign_in_lock.Unlock()
+
+	system_data, err := obj.GetSystemData()
+
+	// Nothing to track attempts in before setup completes
+	if err == badger.ErrKeyNotFound {
+		return obj.master_password_service.Validate(password)
+	}
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return false, err
+	}
+
+	now := time.Now()
+	failed_sign_in := &system_data.FailedSignIn
+
+	if locked_until, err := time.Parse(time.RFC3339, failed_sign_in.LockedUntilDateTime); err == nil && now.Before(locked_until) {
+		err = fmt.Errorf("%w, try again in %d seconds", ErrSignInLocked, int(locked_until.Sub(now).Seconds())+1)
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return false, err
+	}
+
+	result, err := obj.master_password_service.Validate(password)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return false, err
+	}
+
+	if result {
+		if failed_sign_in.ConsecutiveCount != 0 || failed_sign_in.LockedUntilDateTime != "" {
+			failed_sign_in.ConsecutiveCount = 0
+			failed_sign_in.LockedUntilDateTime = ""
+			err = obj.setSystemData(*system_data)
+		}
+
+		return true, err
+	}
+
+	failed_sign_in.ConsecutiveCount += 1
+	failed_sign_in.SinceLastLogin.Add(now.Format(time.RFC3339))
+	logger.Log.Printf("Failed master password attempt %d", failed_sign_in.ConsecutiveCount)
+	recordAuditEvent(AUDIT_SIGN_IN_FAILED, "", fmt.Sprintf("%d consecutive failed attempts", failed_sign_in.ConsecutiveCount))
+
+	if system_data.WipeAfterFailedAttempts > 0 && failed_sign_in.ConsecutiveCount >= system_data.WipeAfterFailedAttempts {
+		if err := obj.wipe(); err != nil {
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return false, err
+		}
+
+		return false, ErrVaultWiped
+	}
+
+	failed_sign_in.LockedUntilDateTime = ""
+	if lockout := signInLockout(failed_sign_in.ConsecutiveCount); lockout > 0 {
+		failed_sign_in.LockedUntilDateTime = now.Add(lockout).Format(time.RFC3339)
+	}
+
+	err = obj.setSystemData(*system_data)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return false, err
+	}
+
+	return false, nil
+}
+
+// Get how long sign in is refused after the given number of consecutive failed attempts
+func signInLockout(failed_count int) time.Duration {
+	if failed_count <= FREE_SIGN_IN_ATTEMPTS {
+		return 0
+	}
+
+	lockout := BASE_SIGN_IN_LOCKOUT
+	for range failed_count - FREE_SIGN_IN_ATTEMPTS - 1 {
+		lockout *= 2
+
+		if lockout >= MAX_SIGN_IN_LOCKOUT {
+			return MAX_SIGN_IN_LOCKOUT
+		}
+	}
+
+	return lockout
+}
+
+// Delete all logins, notes, the master password and system data in a single transaction. The app has to be set up again
+func (obj *SystemService) wipe() error {
+	logger.Log.Printf("Wiping vault")
+	transaction := database.BeginBadgerTransaction()
+
+	login_service := InitBadgerLoginService()
+	login_service.Init()
+
+	if _, err := login_service.importData(transaction, nil, true); err != nil {
+		transaction.Rollback()
+		return err
+	}
+
+	note_service := InitBadgerNoteService()
+	note_service.Init()
+
+	if _, err := note_service.importData(transaction, nil, true); err != nil {
+		transaction.Rollback()
+		return err
+	}
+
+	if err := obj.master_password_service.deleteData(transaction); err != nil {
+		transaction.Rollback()
+		return err
+	}
+
+	if err := transaction.DeleteData(obj.database, obj.database_name); err != nil {
+		transaction.Rollback()
+		return err
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return err
+	}
+
+	lock()
+	jwt.RevokeAllSessions()
+	obj.master_password_service.EndSession()
+
+	logger.Log.Printf("Vault wiped")
+	recordAuditEvent(AUDIT_VAULT_WIPED, "", "")
+	return nil
+}
+
+// Set number of consecutive failed master password attempts after which the vault is wiped. 0 disables wiping
+func (obj *SystemService) UpdateWipeAfterFailedAttempts(wipe_after_failed_attempts int) error {
+	logger.Log.Printf("Updating wipe after failed attempts")
+
+	if wipe_after_failed_attempts != 0 && wipe_after_failed_attempts < MIN_WIPE_AFTER_FAILED_ATTEMPTS {
+		err := fmt.Errorf("wipe after failed attempts has to be 0 or at least %d", MIN_WIPE_AFTER_FAILED_ATTEMPTS)
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	system_data, err := obj.GetSystemData()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	system_data.WipeAfterFailedAttempts = wipe_after_failed_attempts
+
+	err = obj.setSystemData(*system_data)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+/*
+Re-check master password before revealing an entry that requires it.
+
+Returns a short-lived token scoped to the entry and bound to the session it is issued to. entry_type is LOGIN or NOTE and
+entry_id the login name or note created date time.
+*/
+func (obj *SystemService) Reauthenticate(master_password string, session_id string, entry_type string, entry_id string) (string, error) {
+	logger.Log.Printf("Re-authenticating for %s", entry_type)
+
+	if entry_type != jwt.LOGIN_SCOPE && entry_type != jwt.NOTE_SCOPE {
+		err := errors.New("invalid entry type " + entry_type)
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return "", err
+	}
+
+	if entry_id == "" {
+		err := errors.New("entry id is required")
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return "", err
+	}
+
+	result, err := obj.ValidateMasterPassword(master_password)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return "", err
+	}
+
+	if !result {
+		logger.Log.Printf("ERROR: invalid password")
+		return "", errors.New("invalid password")
+	}
+
+	return jwt.GenerateElevatedToken(session_id, jwt.Scope(entry_type, entry_id), jwt.ELEVATED_TOKEN_VALIDITY)
+}
+
 func (obj *SystemService) Logout() error {
 	logger.Log.Printf("Logging out")
+	jwt.RevokeAllSessions()
 
 	system_data, err := obj.GetSystemData()
 
@@ -261,9 +466,50 @@ func (obj *SystemService) Logout() error {
 	}
 
 	logger.Log.Printf("Logged out")
+	recordAuditEvent(AUDIT_SIGN_OUT, "", "")
 	return err
 }
 
+/*
+Prepare for the server to stop.
+
+The signed in user is logged out, a final backup is taken if automatic backup is enabled and the vault is unlocked, and the
+vault is locked. Database handles are left open to be closed by the caller once nothing else uses them.
+*/
+func (obj *SystemService) Shutdown() error {
+	logger.Log.Printf("Shutting down system service")
+	var errs []error
+
+	system_data, err := obj.GetSystemData()
+
+	if err != nil {
+		// Nothing to log out of or back up before setup
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	if system_data.IsLoggedIn {
+		if err := obj.Logout(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if system_data.AutoBackupSetting.IsEnabled {
+		if _, err := obj.master_password_service.getMasterKeys(); err != nil {
+			logger.Log.Printf("Vault is locked, skipping final backup")
+		} else if err := obj.Backup(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	lock()
+
+	return errors.Join(errs...)
+}
+
 func (obj *SystemService) Export(file_name string, file_path string) error {
 	logger.Log.Println("Exporting data...")
 
@@ -275,13 +521,26 @@ func (obj *SystemService) Export(file_name string, file_path string) error {
 
 	logger.Log.Println("Fetching master password")
 	//Get master password data
-	master_password, err := obj.master_password_service.GetMasterPassword()
+	master_keys, err := obj.master_password_service.getMasterKeys()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	master_password_record, err := obj.master_password_service.getMasterPasswordRecord()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
+	//Only KDF params are exported, the verifier is derived again from the master password on import
+	kdf_params, _, err := encryptor.ParseKDFRecord(master_password_record)
 
 	if err != nil {
 		logger.Log.Printf("ERROR: %s", err.Error())
 		return err
-	} else {
-		export_data.MASTER_PASSWORD = master_password
 	}
 
 	system_data, err := obj.GetSystemData()
@@ -344,13 +603,8 @@ func (obj *SystemService) Export(file_name string, file_path string) error {
 		return err
 	}
 
-	logger.Log.Println("Exporting to " + file_path + "\\" + file_name)
-	var path string
-	if file_path != "" {
-		path = file_path + "\\" + file_name
-	} else {
-		path = file_name
-	}
+	path := exportFilePath(file_name, file_path)
+	logger.Log.Println("Exporting to " + path)
 
 	file, err := os.Create(path)
 
@@ -368,21 +622,22 @@ func (obj *SystemService) Export(file_name string, file_path string) error {
 	}
 
 	logger.Log.Println("Encrypting export data")
-	//Encrpyt data using master_password
-	encrypted_export_data, err := encryptor.Encrypt(base64.StdEncoding.EncodeToString(export_data_bytes), master_password)
-	if err != nil {
-		logger.Log.Printf("ERROR: %s", err.Error())
-		return err
+	//Encrypt data using key derived from master_password, KDF params are stored in the header
+	header := models.BackupHeader{
+		KDF:             backup.KDFHeader(kdf_params),
+		CreatedDateTime: time.Now().Format(time.RFC3339),
+		LoginCount:      len(export_data.LOGIN_DATA),
+		NoteCount:       len(export_data.NOTE_DATA),
 	}
 
-	encrypted_export_data_bytes, err := base64.StdEncoding.DecodeString(encrypted_export_data)
+	container, err := backup.Seal(header, export_data_bytes, master_keys.current)
 	if err != nil {
 		logger.Log.Printf("ERROR: %s", err.Error())
 		return err
 	}
 
 	logger.Log.Println("Saving to file")
-	_, err = file.Write(encrypted_export_data_bytes)
+	_, err = file.Write(container)
 
 	if err != nil {
 		logger.Log.Printf("ERROR: %s", err.Error())
@@ -390,154 +645,871 @@ func (obj *SystemService) Export(file_name string, file_path string) error {
 	}
 
 	logger.Log.Printf("Export complete!")
+	recordAuditEvent(AUDIT_EXPORT, path, fmt.Sprintf("%d logins, %d notes", header.LoginCount, header.NoteCount))
 	return nil
 }
 
 func (obj *SystemService) Import(file_name string, file_path string, master_password string) error {
-	os.RemoveAll(os.Getenv("STORAGE_FOLDER"))
+	_, err := obj.ImportWithStrategy(file_name, file_path, master_password, models.IMPORT_REPLACE)
 
-	logger.Log.Println("Importing data")
-	file, err := os.Open(file_path + "\\" + file_name)
+	return err
+}
 
-	if err != nil {
-		logger.Log.Printf("ERROR: %s", err.Error())
-		return err
+/*
+Import a .ncrypt export without wiping the vault first.
+
+The file is decrypted and every login and note is validated before anything is written. Changes are then committed in a single
+transaction, so a wrong master password or a corrupted file leaves the vault untouched.
+
+With REPLACE the vault ends up with exactly the exported data, including the exported master password and system data. Other
+strategies merge exported logins (by name) and notes (by created date time) into the vault, see LoginDataService.mergeData. Merged
+data is encrypted with the current master password, so the vault must be unlocked.
+*/
+func (obj *SystemService) ImportWithStrategy(file_name string, file_path string, master_password string, strategy string) (models.ImportSummary, error) {
+	summary := models.ImportSummary{Strategy: strategy}
+
+	if !slices.Contains([]string{models.IMPORT_REPLACE, models.IMPORT_KEEP_EXISTING, models.IMPORT_OVERWRITE, models.IMPORT_KEEP_BOTH}, strategy) {
+		return summary, errors.New("invalid import strategy " + strategy)
 	}
-	defer file.Close()
 
-	logger.Log.Println("Reading import file")
-	data, err := io.ReadAll(file)
+	logger.Log.Println("Importing data")
+	imported_data, imported_keys, err := readExportFile(exportFilePath(file_name, file_path), master_password)
 	if err != nil {
 		logger.Log.Printf("ERROR: %s", err.Error())
-		return err
+		return summary, err
 	}
 
-	logger.Log.Println("Decrypting import content")
-	decrypted_data, err := encryptor.Decrypt(base64.StdEncoding.EncodeToString(data), encryptor.CreateHash(master_password))
-	if err != nil {
-		logger.Log.Printf("ERROR: %s", err.Error())
-		return err
+	//Validate everything before touching the vault
+	logger.Log.Println("Validating import content")
+	decrypted_login_data := make([]models.Login, len(imported_data.LOGIN_DATA))
+	for index, login_data := range imported_data.LOGIN_DATA {
+		decrypted_login_data[index], err = decryptLoginData(login_data, imported_keys)
+		if err != nil {
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return summary, errors.New("corrupted login data " + login_data.Name)
+		}
 	}
 
-	decrypted_data_bytes, err := base64.StdEncoding.DecodeString(decrypted_data)
-	if err != nil {
-		logger.Log.Printf("ERROR: %s", err.Error())
-		return errors.New("incorrect master password or corrupted file")
+	decrypted_notes := make([]models.Note, len(imported_data.NOTE_DATA))
+	for index, note := range imported_data.NOTE_DATA {
+		decrypted_notes[index], err = decryptNote(note, imported_keys)
+		if err != nil {
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return summary, errors.New("corrupted note " + note.CreatedDateTime)
+		}
 	}
 
-	logger.Log.Println("Importing system data")
-	imported_data := new(ExportData)
-	json.Unmarshal(decrypted_data_bytes, &imported_data)
+	login_service := InitBadgerLoginService()
+	login_service.Init()
+
+	note_service := InitBadgerNoteService()
+	note_service.Init()
+
+	logger.Log.Println("Staging import")
+	transaction := database.BeginBadgerTransaction()
+
+	if strategy == models.IMPORT_REPLACE {
+		err = transaction.AddData(obj.database, obj.database_name, imported_data.SYSTEM_DATA)
+
+		if err == nil {
+			err = obj.master_password_service.importData(transaction, imported_data.MASTER_PASSWORD)
+		}
+		if err == nil {
+			summary.Logins, err = login_service.importData(transaction, imported_data.LOGIN_DATA, true)
+		}
+		if err == nil {
+			summary.Notes, err = note_service.importData(transaction, imported_data.NOTE_DATA, true)
+		}
+	} else {
+		summary.Logins, err = login_service.mergeData(transaction, decrypted_login_data, strategy)
+
+		if err == nil {
+			summary.Notes, err = note_service.mergeData(transaction, decrypted_notes, strategy)
+		}
+	}
 
-	//Import system data
-	logger.Log.Println("Importing system data")
-	err = obj.setSystemData(imported_data.SYSTEM_DATA)
 	if err != nil {
 		logger.Log.Printf("ERROR: %s", err.Error())
-		return err
+		transaction.Rollback()
+		return models.ImportSummary{Strategy: strategy}, err
 	}
 
-	//Import master password
-	logger.Log.Println("Importing master password")
-	err = obj.master_password_service.importData(imported_data.MASTER_PASSWORD)
+	logger.Log.Println("Committing import")
+	err = transaction.Commit()
 	if err != nil {
 		logger.Log.Printf("ERROR: %s", err.Error())
-		return err
+		return models.ImportSummary{Strategy: strategy}, err
 	}
 
-	var wg sync.WaitGroup
-	err_channel := make(chan error)
+	data_change_count.Add(int64(len(imported_data.LOGIN_DATA) + len(imported_data.NOTE_DATA)))
+	recordAuditEvent(AUDIT_IMPORT, exportFilePath(file_name, file_path), fmt.Sprintf("%s, %d logins, %d notes", strategy, len(imported_data.LOGIN_DATA), len(imported_data.NOTE_DATA)))
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		//Import login data
-		logger.Log.Println("Importing login data")
-		login_service := InitBadgerLoginService()
-		login_service.Init()
-		err = login_service.importData(imported_data.LOGIN_DATA)
+	if strategy == models.IMPORT_REPLACE {
+		//Sessions of the replaced vault are no longer valid
+		jwt.RevokeAllSessions()
+
+		//Unlock imported vault. Also upgrades master password imported from older exports
+		logger.Log.Println("Unlocking imported data")
+		result, err := obj.master_password_service.Validate(master_password)
 		if err != nil {
 			logger.Log.Printf("ERROR: %s", err.Error())
-			// return err
-			err_channel <- err
+			return summary, err
 		}
+		if !result {
+			return summary, errors.New("incorrect master password or corrupted file")
+		}
+	}
 
-	}()
+	logger.Log.Println("DONE")
+	return summary, nil
+}
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+/*
+Import logins and notes exported by another password manager, see importer package for supported formats.
+
+Accounts of the same site are merged into one login. If the vault already has a login with that name, accounts are added to it
+and accounts whose username is already there are reported as conflicts and left untouched. With dry run nothing is written, the
+report shows what would be imported.
+*/
+func (obj *SystemService) ImportExternal(format string, file_path string, is_dry_run bool) (models.ImportReport, error) {
+	logger.Log.Printf("Importing %s export", format)
+
+	parser, err := importer.GetParser(format)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.ImportReport{}, err
+	}
+
+	data, err := os.ReadFile(file_path)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.ImportReport{}, err
+	}
+
+	records, skipped, err := parser.Parse(data)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.ImportReport{}, err
+	}
+
+	result := importer.Build(records, skipped)
+
+	report := models.ImportReport{Format: format, IsDryRun: is_dry_run, Logins: []models.ImportedLogin{}, Notes: []string{}, Conflicts: result.Conflicts, Skipped: result.Skipped}
+
+	login_service := InitBadgerLoginService()
+	login_service.Init()
+
+	logger.Log.Printf("Importing login data")
+	for _, login_data := range result.Logins {
+		var usernames []string
+		for _, account := range login_data.Accounts {
+			usernames = append(usernames, account.Username)
+		}
+
+		existing_login_data, err := login_service.GetLoginData(login_data.Name)
+
+		if err != nil && err != badger.ErrKeyNotFound {
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return report, err
+		}
+
+		if existing_login_data.Name != "" {
+			if imported_login, ok := mergeExternalLogin(login_service, existing_login_data, login_data, is_dry_run, &report); ok {
+				report.Logins = append(report.Logins, imported_login)
+			}
+			continue
+		}
+
+		if !is_dry_run {
+			// Services take the same maps as the API
+			login_data_bytes, _ := json.Marshal(login_data)
+			login_data_map := make(map[string]interface{})
+			json.Unmarshal(login_data_bytes, &login_data_map)
+
+			err = login_service.AddLoginData(login_data_map)
+
+			if err != nil {
+				logger.Log.Printf("ERROR: %s", err.Error())
+				report.Conflicts = append(report.Conflicts, models.ImportIssue{Name: login_data.Name, Reason: err.Error()})
+				continue
+			}
+		}
+
+		report.Logins = append(report.Logins, models.ImportedLogin{Name: login_data.Name, URL: login_data.URL, Usernames: usernames})
+	}
+
+	note_service := InitBadgerNoteService()
+	note_service.Init()
+
+	logger.Log.Printf("Importing notes")
+	imported_date_time := time.Now()
+	for index, note := range result.Notes {
+		if !is_dry_run {
+			// Notes are identified by created time, so each one gets a distinct time
+			note_data := map[string]interface{}{
+				"created_date_time": imported_date_time.Add(time.Duration(index) * time.Millisecond).Format(time.RFC3339Nano),
+				"title":             note.Title,
+				"content":           note.Content,
+				"attributes":        map[string]interface{}{"is_favourite": note.Attributes.IsFavourite, "require_master_password": false},
+			}
+
+			err = note_service.AddNote(note_data)
+
+			if err != nil {
+				logger.Log.Printf("ERROR: %s", err.Error())
+				report.Conflicts = append(report.Conflicts, models.ImportIssue{Name: note.Title, Reason: err.Error()})
+				continue
+			}
+		}
+
+		report.Notes = append(report.Notes, note.Title)
+	}
+
+	if !is_dry_run {
+		recordAuditEvent(AUDIT_IMPORT, file_path, fmt.Sprintf("%s, %d logins, %d notes", format, len(report.Logins), len(report.Notes)))
+	}
+
+	logger.Log.Printf("DONE")
+	return report, nil
+}
+
+/*
+Add accounts of an imported login to the login of the same name already in the vault. Accounts whose username already exists
+are reported as conflicts. Returns false if no account was added.
+*/
+func mergeExternalLogin(login_service *LoginDataService, existing_login_data models.Login, login_data models.Login, is_dry_run bool, report *models.ImportReport) (models.ImportedLogin, bool) {
+	var usernames []string
+	var add_accounts []interface{}
+
+	for _, account := range login_data.Accounts {
+		if findAccount(existing_login_data.Accounts, account.Username) != -1 {
+			report.Conflicts = append(report.Conflicts, models.ImportIssue{Name: existing_login_data.Name, Username: account.Username, Reason: account.Username + " already exists in " + existing_login_data.Name})
+			continue
+		}
+
+		// Services take the same maps as the API
+		account_bytes, _ := json.Marshal(account)
+		account_map := make(map[string]interface{})
+		json.Unmarshal(account_bytes, &account_map)
+
+		usernames = append(usernames, account.Username)
+		add_accounts = append(add_accounts, account_map)
+	}
+
+	if len(add_accounts) == 0 {
+		return models.ImportedLogin{}, false
+	}
+
+	if !is_dry_run {
+		_, err := login_service.PatchLoginData(existing_login_data.Name, map[string]interface{}{"add_accounts": add_accounts}, "")
 
-		//Import note data
-		logger.Log.Println("Importing note data")
-		note_service := InitBadgerNoteService()
-		note_service.Init()
-		err = note_service.importData(imported_data.NOTE_DATA)
 		if err != nil {
 			logger.Log.Printf("ERROR: %s", err.Error())
-			// return err
-			err_channel <- err
+			report.Conflicts = append(report.Conflicts, models.ImportIssue{Name: existing_login_data.Name, Reason: err.Error()})
+			return models.ImportedLogin{}, false
 		}
-	}()
+	}
 
-	go func() {
-		wg.Wait()
-		close(err_channel)
-	}()
+	return models.ImportedLogin{Name: existing_login_data.Name, URL: existing_login_data.URL, Usernames: usernames, IsMerged: true}, true
+}
 
-	for err := range err_channel {
+// Get path of an export file. Files are saved to the current folder if no path is given
+func exportFilePath(file_name string, file_path string) string {
+	return filepath.Join(file_path, file_name)
+}
+
+/*
+Read and decrypt an export file, see backup package for the container format.
+
+Returns the exported data along with keys derived from master_password. Files written before the container was introduced are
+the encrypted export data alone, keyed by the unsalted master password hash, and still carry the master password record.
+*/
+func readExportFile(path string, master_password string) (*ExportData, masterKeys, error) {
+	logger.Log.Println("Reading import file")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, masterKeys{}, err
+	}
+
+	imported_data := new(ExportData)
+
+	if backup.IsContainer(data) {
+		header, err := backup.ReadHeader(data)
 		if err != nil {
-			return err
+			return nil, masterKeys{}, err
 		}
+
+		kdf_params, err := backup.KDFParams(header)
+		if err != nil {
+			return nil, masterKeys{}, err
+		}
+
+		logger.Log.Println("Decrypting import content")
+		key, verifier := encryptor.DeriveKey(master_password, kdf_params)
+		keys := masterKeys{current: hex.EncodeToString(key), legacy: encryptor.CreateHash(master_password)}
+
+		_, payload, err := backup.Open(data, keys.current)
+		if err != nil {
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return nil, masterKeys{}, errors.New("incorrect master password or corrupted file")
+		}
+
+		if err = json.Unmarshal(payload, imported_data); err != nil {
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return nil, masterKeys{}, errors.New("incorrect master password or corrupted file")
+		}
+
+		imported_data.MASTER_PASSWORD = encryptor.EncodeKDFRecord(kdf_params, verifier)
+		return imported_data, keys, nil
+	}
+
+	logger.Log.Println("Decrypting legacy import content")
+	//Files exported before versioned ciphertexts were introduced store raw bytes of the ciphertext
+	encrypted_data := string(data)
+	if !strings.HasPrefix(encrypted_data, encryptor.VERSION_PREFIX) {
+		encrypted_data = base64.StdEncoding.EncodeToString(data)
+	}
+
+	decrypted_data, err := encryptor.Decrypt(encrypted_data, encryptor.CreateHash(master_password))
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return nil, masterKeys{}, errors.New("incorrect master password or corrupted file")
+	}
+
+	decrypted_data_bytes, err := base64.StdEncoding.DecodeString(decrypted_data)
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return nil, masterKeys{}, errors.New("incorrect master password or corrupted file")
+	}
+
+	if err = json.Unmarshal(decrypted_data_bytes, imported_data); err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return nil, masterKeys{}, errors.New("incorrect master password or corrupted file")
+	}
+
+	keys, is_valid, err := deriveMasterKeys(imported_data.MASTER_PASSWORD, master_password)
+	if err != nil || !is_valid {
+		return nil, masterKeys{}, errors.New("incorrect master password or corrupted file")
+	}
+
+	return imported_data, keys, nil
+}
+
+// Read cleartext header of an export file, master password is not needed. Files in the legacy format only report their format version
+func (obj *SystemService) InspectBackup(file_name string, file_path string) (models.BackupHeader, error) {
+	logger.Log.Println("Inspecting backup")
+	data, err := os.ReadFile(exportFilePath(file_name, file_path))
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.BackupHeader{}, err
+	}
+
+	if !backup.IsContainer(data) {
+		return models.BackupHeader{FormatVersion: backup.LEGACY_FORMAT_VERSION}, nil
+	}
+
+	header, err := backup.ReadHeader(data)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+	}
+
+	return header, err
+}
+
+/*
+Check a backup can be restored, without touching the vault.
+
+The file is decrypted with master_password and its logins and notes are written to a temporary store, read back and decrypted. If
+the vault is unlocked, decrypted data is compared with the vault and differences are reported.
+*/
+func (obj *SystemService) VerifyBackup(file_name string, file_path string, master_password string) (models.BackupVerification, error) {
+	logger.Log.Println("Verifying backup")
+	path := exportFilePath(file_name, file_path)
+
+	header, err := obj.InspectBackup(file_name, file_path)
+	if err != nil {
+		return models.BackupVerification{}, err
+	}
+
+	export_data, keys, err := readExportFile(path, master_password)
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.BackupVerification{}, err
+	}
+
+	return obj.verifyExportData(header, export_data, keys)
+}
+
+// Verify a backup written by Backup, which is encrypted with the current master key
+func (obj *SystemService) verifyBackupFile(path string) error {
+	keys, err := obj.master_password_service.getMasterKeys()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	header, payload, err := backup.Open(data, keys.current)
+	if err != nil {
+		return err
+	}
+
+	export_data := new(ExportData)
+	if err = json.Unmarshal(payload, export_data); err != nil {
+		return err
+	}
+
+	verification, err := obj.verifyExportData(header, export_data, keys)
+	if err != nil {
+		return err
+	}
+
+	if !verification.IsValid {
+		return errors.New("backup verification failed: " + strings.Join(verification.Errors, "; "))
 	}
 
-	logger.Log.Println("DONE")
 	return nil
 }
 
+func (obj *SystemService) verifyExportData(header models.BackupHeader, export_data *ExportData, keys masterKeys) (models.BackupVerification, error) {
+	verification := models.BackupVerification{Header: header}
+
+	logger.Log.Println("Restoring backup to a temporary store")
+	storage_folder, err := os.MkdirTemp("", "ncrypt_verify_")
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return verification, err
+	}
+	defer os.RemoveAll(storage_folder)
+	defer database.CloseStorageFolder(storage_folder)
+
+	//Same services as the vault, backed by the temporary store
+	restored_login_service := &LoginDataService{database: database.InitBadgerDbAt(storage_folder)}
+	restored_login_service.database.SetDatabase(os.Getenv("LOGIN_DB_NAME"))
+
+	restored_note_service := &NoteService{database: database.InitBadgerDbAt(storage_folder)}
+	restored_note_service.database.SetDatabase(os.Getenv("NOTE_DB_NAME"))
+
+	//Journaled in the temporary store, so the vault's journal and commits are left alone
+	transaction := database.BeginBadgerTransactionAt(storage_folder)
+	_, err = restored_login_service.importData(transaction, export_data.LOGIN_DATA, false)
+	if err == nil {
+		_, err = restored_note_service.importData(transaction, export_data.NOTE_DATA, false)
+	}
+	if err == nil {
+		err = transaction.Commit()
+	} else {
+		transaction.Rollback()
+	}
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return verification, err
+	}
+
+	restored_login_data, err := restored_login_service.GetAllLoginData()
+	if err != nil {
+		return verification, err
+	}
+
+	restored_notes, err := restored_note_service.GetAllNotes()
+	if err != nil {
+		return verification, err
+	}
+
+	verification.LoginCount = len(restored_login_data)
+	verification.NoteCount = len(restored_notes)
+
+	if header.FormatVersion == backup.CURRENT_FORMAT_VERSION && (header.LoginCount != verification.LoginCount || header.NoteCount != verification.NoteCount) {
+		verification.Errors = append(verification.Errors, fmt.Sprintf("header lists %d logins and %d notes, restored %d logins and %d notes", header.LoginCount, header.NoteCount, verification.LoginCount, verification.NoteCount))
+	}
+
+	logger.Log.Println("Decrypting restored data")
+	backup_login_hashes, login_errors := hashLoginData(restored_login_data, keys)
+	backup_note_hashes, note_errors := hashNotes(restored_notes, keys)
+
+	verification.Errors = append(verification.Errors, login_errors...)
+	verification.Errors = append(verification.Errors, note_errors...)
+	verification.IsValid = len(verification.Errors) == 0
+
+	vault_keys, err := obj.master_password_service.getMasterKeys()
+	if err != nil {
+		logger.Log.Println("Vault is locked, skipping comparison")
+		return verification, nil
+	}
+
+	logger.Log.Println("Comparing with vault")
+	login_service := InitBadgerLoginService()
+	login_service.Init()
+
+	vault_login_data, err := login_service.GetAllLoginData()
+	if err != nil {
+		return verification, err
+	}
+
+	note_service := InitBadgerNoteService()
+	note_service.Init()
+
+	vault_notes, err := note_service.GetAllNotes()
+	if err != nil {
+		return verification, err
+	}
+
+	vault_login_hashes, _ := hashLoginData(vault_login_data, vault_keys)
+	vault_note_hashes, _ := hashNotes(vault_notes, vault_keys)
+
+	verification.IsComparedWithVault = true
+	verification.Logins = diffHashes(backup_login_hashes, vault_login_hashes)
+	verification.Notes = diffHashes(backup_note_hashes, vault_note_hashes)
+
+	logger.Log.Println("DONE")
+	return verification, nil
+}
+
+// Hash decrypted login data by name, so data encrypted with different keys or nonces can be compared
+func hashLoginData(login_data_list []models.Login, keys masterKeys) (map[string]string, []string) {
+	hashes := make(map[string]string)
+	var errs []string
+
+	for _, login_data := range login_data_list {
+		decrypted_login_data, err := decryptLoginData(login_data, keys)
+		if err != nil {
+			errs = append(errs, "login "+login_data.Name+": "+err.Error())
+			hashes[login_data.Name] = ""
+			continue
+		}
+
+		hashes[login_data.Name] = hashJSON(decrypted_login_data)
+	}
+
+	return hashes, errs
+}
+
+// Hash decrypted notes by created date time
+func hashNotes(notes []models.Note, keys masterKeys) (map[string]string, []string) {
+	hashes := make(map[string]string)
+	var errs []string
+
+	for _, note := range notes {
+		decrypted_note, err := decryptNote(note, keys)
+		if err != nil {
+			errs = append(errs, "note "+note.CreatedDateTime+": "+err.Error())
+			hashes[note.CreatedDateTime] = ""
+			continue
+		}
+
+		hashes[note.CreatedDateTime] = hashJSON(decrypted_note)
+	}
+
+	return hashes, errs
+}
+
+func hashJSON(data interface{}) string {
+	data_bytes, _ := json.Marshal(data)
+	hash := sha256.Sum256(data_bytes)
+
+	return hex.EncodeToString(hash[:])
+}
+
+// Entries that could not be decrypted are always reported as different
+func diffHashes(backup_hashes map[string]string, vault_hashes map[string]string) models.BackupDiff {
+	var diff models.BackupDiff
+
+	for key, backup_hash := range backup_hashes {
+		vault_hash, exists := vault_hashes[key]
+
+		if !exists {
+			diff.OnlyInBackup = append(diff.OnlyInBackup, key)
+		} else if backup_hash == "" || backup_hash != vault_hash {
+			diff.Different = append(diff.Different, key)
+		}
+	}
+
+	for key := range vault_hashes {
+		if _, exists := backup_hashes[key]; !exists {
+			diff.OnlyInVault = append(diff.OnlyInVault, key)
+		}
+	}
+
+	slices.Sort(diff.OnlyInBackup)
+	slices.Sort(diff.OnlyInVault)
+	slices.Sort(diff.Different)
+
+	return diff
+}
+
 type ExportData struct {
 	SYSTEM_DATA     models.SystemData `json:"SYSTEM" bson:"SYSTEM"`
 	LOGIN_DATA      []models.Login    `json:"LOGIN_DATA" bson:"LOGIN_DATA"`
 	NOTE_DATA       []models.Note     `json:"NOTE_DATA" bson:"NOTE_DATA"`
-	MASTER_PASSWORD string            `json:"MASTER_PASSWORD" bson:"MASTER_PASSWORD"`
+	MASTER_PASSWORD string            `json:"MASTER_PASSWORD,omitempty" bson:"MASTER_PASSWORD"` // Only in legacy exports
 }
 
-func (obj *SystemService) GeneratePassword() string {
+func (obj *SystemService) GeneratePassword() (models.GeneratedPassword, error) {
 	password_preference, err := obj.GetPasswordGeneratorPreference()
 
 	if err != nil {
-		return ""
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.GeneratedPassword{}, err
+	}
+
+	var password string
+	var entropy_bits float64
+
+	if password_preference.Mode == models.PASSPHRASE_MODE {
+		password, entropy_bits, err = utils.GeneratePassphrase(*password_preference)
+	} else {
+		password, entropy_bits, err = utils.GeneratePassword(*password_preference)
 	}
 
-	return utils.GeneratePassword(password_preference.HasDigits, password_preference.HasUpperCase, password_preference.HasSpecialChar, password_preference.Length)
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.GeneratedPassword{}, err
+	}
+
+	return models.GeneratedPassword{Password: password, EntropyBits: entropy_bits}, nil
 }
 
+/*
+Audit health of all account passwords.
+
+Passwords are decrypted in memory only to score them, to find reused passwords and are never part of the report.
+Reuse is detected by comparing hashes of the passwords across all login data. Accounts without a password updated
+time (created before it was tracked) are treated as old.
+*/
+func (obj *SystemService) Audit(max_age_in_days int) (models.AuditReport, error) {
+	logger.Log.Printf("Auditing passwords")
+
+	if max_age_in_days <= 0 {
+		return models.AuditReport{}, errors.New("max age must be greater than 0")
+	}
+
+	login_service := InitBadgerLoginService()
+	login_service.Init()
+	login_data_list, err := login_service.getDecryptedLoginData()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.AuditReport{}, err
+	}
+
+	report := models.AuditReport{Accounts: []models.AccountAudit{}, ReusedGroups: [][]models.AccountReference{}, MaxAgeInDays: max_age_in_days}
+	oldest_allowed_time := time.Now().AddDate(0, 0, -max_age_in_days)
+
+	var password_hashes [][sha256.Size]byte
+	accounts_by_hash := make(map[[sha256.Size]byte][]int)
+
+	for _, login_data := range login_data_list {
+		for _, account := range login_data.Accounts {
+			entropy_bits, score := utils.EstimatePasswordStrength(account.Password)
+
+			account_audit := models.AccountAudit{
+				Name:                    login_data.Name,
+				Username:                account.Username,
+				Score:                   score,
+				EntropyBits:             entropy_bits,
+				IsWeak:                  score < utils.FAIR_PASSWORD,
+				IsOld:                   true,
+				PasswordUpdatedDateTime: account.PasswordUpdatedDateTime,
+			}
+
+			if updated_time, err := time.Parse(time.RFC3339, account.PasswordUpdatedDateTime); err == nil {
+				account_audit.IsOld = updated_time.Before(oldest_allowed_time)
+			}
+
+			password_hash := sha256.Sum256([]byte(account.Password))
+			password_hashes = append(password_hashes, password_hash)
+			accounts_by_hash[password_hash] = append(accounts_by_hash[password_hash], len(report.Accounts))
+
+			report.Accounts = append(report.Accounts, account_audit)
+		}
+	}
+
+	logger.Log.Printf("Grouping reused passwords")
+	for _, password_hash := range password_hashes {
+		indexes := accounts_by_hash[password_hash]
+
+		if len(indexes) < 2 {
+			continue
+		}
+
+		var reused_group []models.AccountReference
+		for _, index := range indexes {
+			report.Accounts[index].IsReused = true
+			reused_group = append(reused_group, models.AccountReference{Name: report.Accounts[index].Name, Username: report.Accounts[index].Username})
+		}
+
+		report.ReusedGroups = append(report.ReusedGroups, reused_group)
+		// Add each group only once
+		delete(accounts_by_hash, password_hash)
+	}
+
+	for _, account_audit := range report.Accounts {
+		if account_audit.IsWeak {
+			report.WeakCount++
+		}
+		if account_audit.IsReused {
+			report.ReusedCount++
+		}
+		if account_audit.IsOld {
+			report.OldCount++
+		}
+	}
+
+	logger.Log.Printf("DONE")
+	return report, nil
+}
+
+// Check all account passwords against a locally downloaded Have I Been Pwned corpus. Reused passwords are looked up only once
+func (obj *SystemService) BreachCheck(corpus_path string) (models.BreachReport, error) {
+	logger.Log.Printf("Checking passwords for breaches")
+
+	corpus, err := breach.Open(corpus_path)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.BreachReport{}, err
+	}
+	defer corpus.Close()
+
+	login_service := InitBadgerLoginService()
+	login_service.Init()
+	login_data_list, err := login_service.getDecryptedLoginData()
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return models.BreachReport{}, err
+	}
+
+	report := models.BreachReport{Accounts: []models.BreachedAccount{}}
+	breach_counts := make(map[string]int)
+
+	for _, login_data := range login_data_list {
+		for _, account := range login_data.Accounts {
+			breach_count, exists := breach_counts[account.Password]
+
+			if !exists {
+				breach_count, err = corpus.Count(account.Password)
+
+				if err != nil {
+					logger.Log.Printf("ERROR: %s", err.Error())
+					return models.BreachReport{}, err
+				}
+
+				breach_counts[account.Password] = breach_count
+			}
+
+			if breach_count > 0 {
+				report.Accounts = append(report.Accounts, models.BreachedAccount{Name: login_data.Name, Username: account.Username, BreachCount: breach_count})
+			}
+
+			report.CheckedCount++
+		}
+	}
+
+	logger.Log.Printf("DONE")
+	return report, nil
+}
+
+/*
+Backup data using path and file name in auto backup setting. Does nothing if automatic backup is not enabled.
+
+If VerifyAfterBackup is set, the new file is checked using VerifyBackup and a backup that cannot be restored counts as failed.
+Outcome is saved as last backup in system data. After a successful backup, older backups not kept by the retention rules are
+deleted.
+*/
 func (obj *SystemService) Backup() error {
+	// Scheduled and manual backups must not run at the same time
+	backup_lock.Lock()
+	defer backup_lock.Unlock()
+
 	logger.Log.Printf("Backing up data")
 	logger.Log.Printf("Getting system data")
 	system_data, err := obj.GetSystemData()
 
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
 	logger.Log.Printf("Checking for automatic backup setting")
 	auto_backup_setting := system_data.AutoBackupSetting
 
-	if err == nil {
-		if auto_backup_setting.IsEnabled {
-			logger.Log.Printf("Automatic backup is enabled")
-			file_name := auto_backup_setting.BackupFileName + "_" + time.Now().Format(time.RFC3339) + ".ncrypt"
-			file_name = strings.Replace(file_name, ":", "-", -1)
-			logger.Log.Printf("Exporting data")
-			err = obj.Export(file_name, auto_backup_setting.BackupLocation)
-			if err != nil {
-				logger.Log.Printf("ERROR: %s", err.Error())
-			}
-		} else {
-			logger.Log.Printf("Automatic backup is not enabled")
-		}
-	} else {
+	if !auto_backup_setting.IsEnabled {
+		logger.Log.Printf("Automatic backup is not enabled")
+		return nil
+	}
+
+	logger.Log.Printf("Automatic backup is enabled")
+	change_count := data_change_count.Load()
+	now := time.Now()
+	file_name := backup.FileName(auto_backup_setting.BackupFileName, now)
+
+	logger.Log.Printf("Exporting data")
+	err = obj.Export(file_name, auto_backup_setting.BackupLocation)
+
+	if err == nil && auto_backup_setting.VerifyAfterBackup {
+		logger.Log.Printf("Verifying backup")
+		err = obj.verifyBackupFile(exportFilePath(file_name, auto_backup_setting.BackupLocation))
+	}
+
+	status := models.BackupStatus{DateTime: now.Format(time.RFC3339), FileName: file_name, IsSuccessful: err == nil, IsVerified: err == nil && auto_backup_setting.VerifyAfterBackup}
+	if err != nil {
 		logger.Log.Printf("ERROR: %s", err.Error())
+		status.Error = err.Error()
 	}
 
-	return err
+	if status_err := obj.setLastBackup(status); status_err != nil {
+		logger.Log.Printf("ERROR: %s", status_err.Error())
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// Changes made while exporting count towards the next backup
+	data_change_count.Add(-change_count)
+
+	obj.pruneBackups(auto_backup_setting, now)
+
+	return nil
+}
+
+func (obj *SystemService) setLastBackup(status models.BackupStatus) error {
+	system_data, err := obj.GetSystemData()
+
+	if err != nil {
+		return err
+	}
+
+	system_data.LastBackup = status
+
+	return obj.setSystemData(*system_data)
+}
+
+// Delete automatic backups not kept by the retention rules. Failures are only logged, the backup itself has succeeded
+func (obj *SystemService) pruneBackups(auto_backup_setting models.AutoBackupSetting, now time.Time) {
+	files, err := backup.List(auto_backup_setting.BackupLocation, auto_backup_setting.BackupFileName)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return
+	}
+
+	for _, file := range backup.Expired(files, auto_backup_setting.Retention, now) {
+		logger.Log.Printf("Deleting old backup %s", file.Name)
+
+		if err := os.Remove(file.Name); err != nil {
+			logger.Log.Printf("ERROR: %s", err.Error())
+		}
+	}
 }
 
 func (obj *SystemService) UpdateAutomaticBackup(updated_auto_backup_setting map[string]interface{}) error {
@@ -558,6 +1530,11 @@ func (obj *SystemService) UpdateAutomaticBackup(updated_auto_backup_setting map[
 		}
 	}
 
+	retention := auto_backup_setting.Retention
+	if auto_backup_setting.IntervalInMinutes < 0 || auto_backup_setting.AfterChanges < 0 || retention.KeepLast < 0 || retention.KeepDailyForDays < 0 || retention.KeepWeeklyForWeeks < 0 {
+		return errors.New("backup interval, change count and retention cannot be negative")
+	}
+
 	system_data.AutoBackupSetting = *auto_backup_setting
 
 	err = obj.setSystemData(*system_data)
@@ -591,6 +1568,13 @@ func (obj *SystemService) UpdatePasswordGeneratorPreference(data map[string]inte
 	password_generator_preference := new(models.PasswordGeneratorPreference)
 	password_generator_preference.FromMap(data)
 
+	err = utils.ValidatePasswordPolicy(*password_generator_preference)
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return err
+	}
+
 	system_data.PasswordGeneratorPreference = *password_generator_preference
 	err = obj.setSystemData(*system_data)
 
@@ -629,6 +1613,25 @@ func (obj *SystemService) ExtendSession() (string, error) {
 	return jwt.GenerateToken(system_data.SessionDurationInMinutes)
 }
 
+// Get active API sessions. Session with current_session_id is marked as current
+func (obj *SystemService) GetSessions(current_session_id string) []models.Session {
+	session_list := []models.Session{}
+
+	for _, session := range jwt.GetSessions() {
+		session_list = append(session_list, models.Session{
+			ID:               session.ID,
+			IssuedDateTime:   session.IssuedAt.Format(time.RFC3339),
+			ExpiryDateTime:   session.ExpiresAt.Format(time.RFC3339),
+			LastUsedDateTime: session.LastUsedAt.Format(time.RFC3339),
+			ClientIP:         session.ClientIP,
+			UserAgent:        session.UserAgent,
+			IsCurrent:        session.ID == current_session_id,
+		})
+	}
+
+	return session_list
+}
+
 func (obj *SystemService) UpdateTheme(theme string) error {
 	logger.Log.Printf("Setting theme")
 	system_data, err := obj.GetSystemData()
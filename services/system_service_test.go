@@ -1,11 +1,23 @@
 package services
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"ncrypt/models"
+	"ncrypt/utils/backup"
+	"ncrypt/utils/database"
+	"ncrypt/utils/encryptor"
+	"ncrypt/utils/importer"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/dgraph-io/badger/v4"
 )
 
 func TestSetSystemData(t *testing.T) {
@@ -163,6 +175,130 @@ func TestSignIn_WithIncorrectPassword(t *testing.T) {
 	t.Cleanup(system_service_test_cleanup)
 }
 
+func TestSignIn_LockoutAfterFailedAttempts(t *testing.T) {
+	service := new(SystemService)
+	service.Init()
+
+	password := "12345"
+	auto_backup_setting := map[string]interface{}{"is_enabled": false, "backup_location": "", "backup_file_name": ""}
+
+	if err := service.Setup(password, auto_backup_setting); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for i := 0; i <= FREE_SIGN_IN_ATTEMPTS; i++ {
+		if _, err := service.SignIn("123"); err == nil || errors.Is(err, ErrSignInLocked) {
+			t.Errorf("Attempt %d should fail as invalid password, got %v", i+1, err)
+		}
+	}
+
+	// Even the correct password is refused while locked out
+	if _, err := service.SignIn(password); !errors.Is(err, ErrSignInLocked) {
+		t.Errorf("Should be locked out, got %v", err)
+	}
+
+	if _, err := service.ValidateMasterPassword(password); !errors.Is(err, ErrSignInLocked) {
+		t.Errorf("Validate should share lockout, got %v", err)
+	}
+
+	system_data, _ := service.GetSystemData()
+
+	if system_data.FailedSignIn.ConsecutiveCount != FREE_SIGN_IN_ATTEMPTS+1 {
+		t.Errorf("Expected %d consecutive failures, got %d", FREE_SIGN_IN_ATTEMPTS+1, system_data.FailedSignIn.ConsecutiveCount)
+	}
+
+	// Let lockout expire
+	system_data.FailedSignIn.LockedUntilDateTime = time.Now().Add(-time.Second).Format(time.RFC3339)
+	service.setSystemData(*system_data)
+
+	if _, err := service.SignIn(password); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	system_data, _ = service.GetSystemData()
+
+	if system_data.FailedSignIn.ConsecutiveCount != 0 || system_data.FailedSignIn.LockedUntilDateTime != "" {
+		t.Error("Failed attempts should be reset after successful sign in")
+	}
+
+	if system_data.FailedSignInsBeforeLogin.Count != FREE_SIGN_IN_ATTEMPTS+1 || len(system_data.FailedSignInsBeforeLogin.DateTimes) != FREE_SIGN_IN_ATTEMPTS+1 {
+		t.Errorf("Failed attempts before login not recorded: %+v", system_data.FailedSignInsBeforeLogin)
+	}
+
+	if system_data.FailedSignIn.SinceLastLogin.Count != 0 {
+		t.Error("Failed attempts since last login should be cleared")
+	}
+
+	t.Cleanup(system_service_test_cleanup)
+}
+
+func TestSignInLockout(t *testing.T) {
+	expected := map[int]time.Duration{
+		FREE_SIGN_IN_ATTEMPTS:      0,
+		FREE_SIGN_IN_ATTEMPTS + 1:  BASE_SIGN_IN_LOCKOUT,
+		FREE_SIGN_IN_ATTEMPTS + 3:  4 * BASE_SIGN_IN_LOCKOUT,
+		FREE_SIGN_IN_ATTEMPTS + 50: MAX_SIGN_IN_LOCKOUT,
+	}
+
+	for failed_count, lockout := range expected {
+		if result := signInLockout(failed_count); result != lockout {
+			t.Errorf("Expected lockout %s after %d failures, got %s", lockout, failed_count, result)
+		}
+	}
+}
+
+func TestSignIn_WipeAfterFailedAttempts(t *testing.T) {
+	service := new(SystemService)
+	service.Init()
+
+	password := "12345"
+	auto_backup_setting := map[string]interface{}{"is_enabled": false, "backup_location": "", "backup_file_name": ""}
+
+	if err := service.Setup(password, auto_backup_setting); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := service.UpdateWipeAfterFailedAttempts(MIN_WIPE_AFTER_FAILED_ATTEMPTS - 1); err == nil {
+		t.Error("Should reject wipe after fewer than minimum attempts")
+	}
+
+	if err := service.UpdateWipeAfterFailedAttempts(MIN_WIPE_AFTER_FAILED_ATTEMPTS); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	login_service := InitBadgerLoginService()
+	login_service.Init()
+
+	attributes := map[string]interface{}{"is_favourite": false, "require_master_password": false}
+	login_service.AddLoginData(map[string]interface{}{"name": "github", "url": "https://github.com", "attributes": attributes, "accounts": []interface{}{
+		map[string]interface{}{"username": "abc", "password": "123"},
+	}})
+
+	// Skip waiting for lockouts of earlier attempts
+	system_data, _ := service.GetSystemData()
+	system_data.FailedSignIn.ConsecutiveCount = MIN_WIPE_AFTER_FAILED_ATTEMPTS - 1
+	service.setSystemData(*system_data)
+
+	if _, err := service.SignIn("123"); !errors.Is(err, ErrVaultWiped) {
+		t.Fatalf("Vault should be wiped, got %v", err)
+	}
+
+	if _, err := service.GetSystemData(); err != badger.ErrKeyNotFound {
+		t.Error("System data should be deleted")
+	}
+
+	if logins, _ := login_service.GetAllLoginData(); len(logins) != 0 {
+		t.Error("Logins should be deleted")
+	}
+
+	// Vault can be set up again
+	if err := service.Setup("67890", auto_backup_setting); err != nil {
+		t.Error(err.Error())
+	}
+
+	t.Cleanup(system_service_test_cleanup)
+}
+
 func TestLogout(t *testing.T) {
 	service := new(SystemService)
 	service.Init()
@@ -272,15 +408,15 @@ func TestExport_WithCustomPath(t *testing.T) {
 		t.Error(err.Error())
 	}
 
-	err = service.Export("test_export.ncrypt", "..\\models")
+	err = service.Export("test_export.ncrypt", filepath.Join("..", "models"))
 	if err != nil {
 		t.Error(err.Error())
 	}
 
-	if _, err := os.Stat("..\\models\\test_export.ncrypt"); os.IsNotExist(err) {
+	if _, err := os.Stat(filepath.Join("..", "models", "test_export.ncrypt")); os.IsNotExist(err) {
 		t.Error("Exported file not found")
 	} else {
-		err = os.Remove("..\\models\\test_export.ncrypt")
+		err = os.Remove(filepath.Join("..", "models", "test_export.ncrypt"))
 		if err != nil {
 			t.Error(err.Error())
 		}
@@ -305,7 +441,7 @@ func TestExport_WithInvalidPath(t *testing.T) {
 		t.Error(err.Error())
 	}
 
-	err = service.Export("test_export.ncrypt", "..\\test")
+	err = service.Export("test_export.ncrypt", filepath.Join("..", "test"))
 	if err == nil {
 		t.Error("should result in an error as folder is not found")
 	}
@@ -744,10 +880,65 @@ func TestSetPasswordPreference_GeneratePassword(t *testing.T) {
 		t.Errorf("Mismatch in data\nExpected:\t%v\nActual:\t%v", passwordPreference, fetched_preference)
 	}
 
-	generated_password := service.GeneratePassword()
+	generated_password, err := service.GeneratePassword()
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if len(generated_password.Password) != 8 {
+		t.Error("Generated password should be of length 8")
+	}
+
+	t.Cleanup(system_service_test_cleanup)
+}
+
+func TestSetPasswordPreference_GeneratePassphrase(t *testing.T) {
+	service := new(SystemService)
+	service.Init()
+
+	auto_backup_setting := make(map[string]interface{})
+	auto_backup_setting["is_enabled"] = false
+	auto_backup_setting["backup_location"] = ""
+	auto_backup_setting["backup_file_name"] = ""
+
+	err := service.Setup("12345", auto_backup_setting)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	passwordPreference := make(map[string]interface{})
+	passwordPreference["mode"] = "PASSPHRASE"
+	passwordPreference["has_digits"] = false
+	passwordPreference["has_uppercase"] = false
+	passwordPreference["has_special_char"] = false
+	passwordPreference["length"] = float64(8)
+	passwordPreference["word_count"] = float64(4)
+	passwordPreference["word_separator"] = "."
+
+	err = service.UpdatePasswordGeneratorPreference(passwordPreference)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	generated_password, err := service.GeneratePassword()
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if len(strings.Split(generated_password.Password, ".")) != 4 {
+		t.Errorf("Passphrase should have 4 words: %s", generated_password.Password)
+	}
+
+	passwordPreference["word_count"] = float64(0)
+
+	err = service.UpdatePasswordGeneratorPreference(passwordPreference)
 
-	if len(generated_password) == 0 {
-		t.Error("Generated password should be empty")
+	if err == nil {
+		t.Error("Should fail as word count is invalid")
 	}
 
 	t.Cleanup(system_service_test_cleanup)
@@ -910,6 +1101,121 @@ func TestBackup(t *testing.T) {
 	t.Cleanup(system_service_test_cleanup)
 }
 
+func TestBackup_Retention(t *testing.T) {
+	service := new(SystemService)
+	service.Init()
+
+	location := t.TempDir()
+	auto_backup_setting := map[string]interface{}{"is_enabled": true, "backup_location": "", "backup_file_name": filepath.Join(location, "test_backup"),
+		"retention": map[string]interface{}{"keep_last": float64(2)}}
+
+	if err := service.Setup("12345", auto_backup_setting); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Backups from previous days
+	for days := 1; days <= 3; days++ {
+		os.WriteFile(backup.FileName(filepath.Join(location, "test_backup"), time.Now().AddDate(0, 0, -days)), nil, 0644)
+	}
+	os.WriteFile(filepath.Join(location, "test_backup.ncrypt"), nil, 0644)
+
+	if err := service.Backup(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	files, _ := backup.List(location, "test_backup")
+
+	if len(files) != 2 || time.Since(files[0].CreatedDateTime) > time.Minute {
+		t.Errorf("Expected new backup and most recent old backup\n%+v", files)
+	}
+
+	if _, err := os.Stat(filepath.Join(location, "test_backup.ncrypt")); err != nil {
+		t.Error("Files not written by automatic backup should not be deleted")
+	}
+
+	system_data, _ := service.GetSystemData()
+
+	if !system_data.LastBackup.IsSuccessful || system_data.LastBackup.DateTime == "" || system_data.LastBackup.Error != "" {
+		t.Errorf("Incorrect last backup %+v", system_data.LastBackup)
+	}
+
+	t.Cleanup(system_service_test_cleanup)
+}
+
+func TestBackup_VerifyAfterBackup(t *testing.T) {
+	service := new(SystemService)
+	service.Init()
+
+	location := t.TempDir()
+	auto_backup_setting := map[string]interface{}{"is_enabled": true, "backup_location": "", "backup_file_name": filepath.Join(location, "test_backup"), "verify_after_backup": true}
+
+	if err := service.Setup("12345", auto_backup_setting); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := service.Backup(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	system_data, _ := service.GetSystemData()
+
+	if !system_data.LastBackup.IsSuccessful || !system_data.LastBackup.IsVerified {
+		t.Errorf("Incorrect last backup %+v", system_data.LastBackup)
+	}
+
+	t.Cleanup(system_service_test_cleanup)
+}
+
+func TestBackupScheduler(t *testing.T) {
+	service := new(SystemService)
+	service.Init()
+
+	location := t.TempDir()
+	auto_backup_setting := map[string]interface{}{"is_enabled": true, "backup_location": "", "backup_file_name": filepath.Join(location, "test_backup"),
+		"interval_in_minutes": float64(60), "after_changes": float64(2)}
+
+	if err := service.Setup("12345", auto_backup_setting); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	scheduler := InitBackupScheduler()
+	scheduler.Init()
+
+	// No backup yet, so interval has passed
+	if !scheduler.isDue(time.Now()) {
+		t.Error("First backup should be due")
+	}
+
+	scheduler.runIfDue(time.Now())
+
+	if scheduler.isDue(time.Now()) {
+		t.Error("Backup should not be due right after a backup")
+	}
+
+	if !scheduler.isDue(time.Now().Add(61 * time.Minute)) {
+		t.Error("Backup should be due after interval")
+	}
+
+	note_service := InitBadgerNoteService()
+	note_service.Init()
+
+	for _, created_date_time := range []string{"1", "2"} {
+		note_service.AddNote(map[string]interface{}{"created_date_time": created_date_time, "title": "test", "content": "test", "attributes": map[string]interface{}{"is_favourite": false, "require_master_password": false}})
+	}
+
+	if !scheduler.isDue(time.Now()) {
+		t.Error("Backup should be due after changes")
+	}
+
+	scheduler.runIfDue(time.Now())
+
+	if files, _ := backup.List(location, "test_backup"); len(files) == 0 {
+		t.Error("Backup file not found")
+	}
+
+	t.Cleanup(system_service_test_cleanup)
+}
+
 func TestUpadteAutomaticBackupData_SettingToTrue(t *testing.T) {
 	service := new(SystemService)
 	service.Init()
@@ -1182,6 +1488,839 @@ func TestUpdateTheme(t *testing.T) {
 	t.Cleanup(system_service_test_cleanup)
 }
 
+func TestAudit(t *testing.T) {
+	service := new(SystemService)
+	service.Init()
+
+	password := "12345"
+	auto_backup_setting := make(map[string]interface{})
+	auto_backup_setting["is_enabled"] = false
+	auto_backup_setting["backup_location"] = ""
+	auto_backup_setting["backup_file_name"] = ""
+
+	err := service.Setup(password, auto_backup_setting)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	login_service := InitBadgerLoginService()
+	login_service.Init()
+
+	login_data_list := []map[string]interface{}{
+		{"name": "github", "url": "https://github.com", "accounts": []interface{}{
+			map[string]interface{}{"username": "abc", "password": "password"},
+			map[string]interface{}{"username": "pqr", "password": "t8#Kd9$vQ2!mZr4&"},
+		}},
+		{"name": "gitlab", "url": "https://gitlab.com", "accounts": []interface{}{
+			map[string]interface{}{"username": "abc", "password": "t8#Kd9$vQ2!mZr4&"},
+			map[string]interface{}{"username": "xyz", "password": "Wq7!zP3#rK9@mX2$"},
+		}},
+	}
+
+	for _, login_data := range login_data_list {
+		login_data["attributes"] = map[string]interface{}{"is_favourite": false, "require_master_password": false}
+
+		if err := login_service.AddLoginData(login_data); err != nil {
+			t.Error(err.Error())
+		}
+	}
+
+	//Accounts created before password age was tracked have no updated time
+	gitlab_login_data, err := login_service.GetLoginData("gitlab")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	gitlab_login_data.Accounts[1].PasswordUpdatedDateTime = ""
+	login_service.database.AddData("GITLAB", gitlab_login_data)
+
+	report, err := service.Audit(DEFAULT_PASSWORD_MAX_AGE_IN_DAYS)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if len(report.Accounts) != 4 {
+		t.Fatalf("Expected: %d\nActual: %d", 4, len(report.Accounts))
+	}
+
+	if report.WeakCount != 1 || report.ReusedCount != 2 || report.OldCount != 1 || report.MaxAgeInDays != DEFAULT_PASSWORD_MAX_AGE_IN_DAYS {
+		t.Errorf("Incorrect counts %+v", report)
+	}
+
+	expected_group := []models.AccountReference{{Name: "github", Username: "pqr"}, {Name: "gitlab", Username: "abc"}}
+	if len(report.ReusedGroups) != 1 || len(report.ReusedGroups[0]) != 2 || report.ReusedGroups[0][0] != expected_group[0] || report.ReusedGroups[0][1] != expected_group[1] {
+		t.Errorf("Incorrect reused groups\nExpected: %+v\nActual: %+v", expected_group, report.ReusedGroups)
+	}
+
+	for _, account_audit := range report.Accounts {
+		switch account_audit.Name + "/" + account_audit.Username {
+		case "github/abc":
+			if !account_audit.IsWeak || account_audit.IsReused || account_audit.IsOld {
+				t.Errorf("Incorrect audit %+v", account_audit)
+			}
+		case "gitlab/xyz":
+			if account_audit.IsWeak || account_audit.IsReused || !account_audit.IsOld {
+				t.Errorf("Incorrect audit %+v", account_audit)
+			}
+		}
+	}
+
+	//Report should never contain any password
+	report_bytes, _ := json.Marshal(report)
+	for _, plaintext := range []string{"password\"", "t8#Kd9", "Wq7!zP3"} {
+		if strings.Contains(string(report_bytes), plaintext) {
+			t.Errorf("Report contains password %s", plaintext)
+		}
+	}
+
+	if _, err := service.Audit(0); err == nil {
+		t.Error("Should fail for invalid max age")
+	}
+
+	t.Cleanup(system_service_test_cleanup)
+}
+
+func TestBreachCheck(t *testing.T) {
+	service := new(SystemService)
+	service.Init()
+
+	password := "12345"
+	auto_backup_setting := make(map[string]interface{})
+	auto_backup_setting["is_enabled"] = false
+	auto_backup_setting["backup_location"] = ""
+	auto_backup_setting["backup_file_name"] = ""
+
+	err := service.Setup(password, auto_backup_setting)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	login_service := InitBadgerLoginService()
+	login_service.Init()
+
+	login_data := make(map[string]interface{})
+	login_data["name"] = "github"
+	login_data["url"] = "https://github.com"
+	login_data["accounts"] = []interface{}{
+		map[string]interface{}{"username": "abc", "password": "password"},
+		map[string]interface{}{"username": "pqr", "password": "t8#Kd9$vQ2!mZr4&"},
+	}
+	login_data["attributes"] = map[string]interface{}{"is_favourite": false, "require_master_password": false}
+
+	err = login_service.AddLoginData(login_data)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	//SHA-1 of "password" and "123456"
+	corpus_path := filepath.Join(t.TempDir(), "pwned-passwords.txt")
+	os.WriteFile(corpus_path, []byte("5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:10434004\r\n7C4A8D09CA3762AF61E59520943DC26494F8941B:37359195\r\n"), 0644)
+
+	report, err := service.BreachCheck(corpus_path)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	expected_accounts := []models.BreachedAccount{{Name: "github", Username: "abc", BreachCount: 10434004}}
+	if report.CheckedCount != 2 || len(report.Accounts) != 1 || report.Accounts[0] != expected_accounts[0] {
+		t.Errorf("Expected: %+v\nActual: %+v", expected_accounts, report)
+	}
+
+	if _, err := service.BreachCheck(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("Should fail for missing corpus")
+	}
+
+	t.Cleanup(system_service_test_cleanup)
+}
+
+func TestImportExternal(t *testing.T) {
+	service := new(SystemService)
+	service.Init()
+
+	password := "12345"
+	auto_backup_setting := make(map[string]interface{})
+	auto_backup_setting["is_enabled"] = false
+	auto_backup_setting["backup_location"] = ""
+	auto_backup_setting["backup_file_name"] = ""
+
+	err := service.Setup(password, auto_backup_setting)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	file_path := filepath.Join(t.TempDir(), "bitwarden_export.json")
+	os.WriteFile(file_path, []byte(`{"encrypted": false, "items": [
+		{"type": 1, "name": "github", "favorite": true, "login": {"uris": [{"uri": "https://github.com"}], "username": "abc", "password": "123", "totp": "JBSWY3DPEHPK3PXP"}},
+		{"type": 1, "name": "github", "favorite": false, "login": {"uris": [], "username": "pqr", "password": "456"}},
+		{"type": 2, "name": "Wifi", "notes": "password is 789", "secureNote": {"type": 0}}
+	]}`), 0644)
+
+	//Dry run should not write anything
+	report, err := service.ImportExternal(importer.BITWARDEN_JSON, file_path, true)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if !report.IsDryRun || len(report.Logins) != 1 || len(report.Logins[0].Usernames) != 2 || len(report.Notes) != 1 {
+		t.Errorf("Incorrect report %+v", report)
+	}
+
+	login_service := InitBadgerLoginService()
+	login_service.Init()
+
+	if login_data_list, _ := login_service.GetAllLoginData(); len(login_data_list) != 0 {
+		t.Errorf("Dry run should not import data\n%+v", login_data_list)
+	}
+
+	report, err = service.ImportExternal(importer.BITWARDEN_JSON, file_path, false)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if len(report.Logins) != 1 || len(report.Notes) != 1 || len(report.Conflicts) != 0 {
+		t.Errorf("Incorrect report %+v", report)
+	}
+
+	decrypted_password, err := login_service.GetDecryptedAccountPassword("github", "pqr")
+
+	if err != nil || decrypted_password != "456" {
+		t.Errorf("Expected: %s\nActual: %s", "456", decrypted_password)
+	}
+
+	if _, err := login_service.GetTOTPCode("github", "abc"); err != nil {
+		t.Error(err.Error())
+	}
+
+	note_service := InitBadgerNoteService()
+	note_service.Init()
+
+	if notes, _ := note_service.GetAllNotes(); len(notes) != 1 || notes[0].Title != "Wifi" {
+		t.Errorf("Incorrect notes %+v", notes)
+	}
+
+	//Existing accounts are not overwritten
+	report, err = service.ImportExternal(importer.BITWARDEN_JSON, file_path, false)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if len(report.Logins) != 0 || len(report.Conflicts) != 2 {
+		t.Errorf("Incorrect report %+v", report)
+	}
+
+	if _, err := service.ImportExternal("UNKNOWN", file_path, true); err == nil {
+		t.Error("Should fail for unsupported format")
+	}
+
+	t.Cleanup(system_service_test_cleanup)
+}
+
+func TestImportExternal_MergeIntoExistingLogin(t *testing.T) {
+	service := new(SystemService)
+	service.Init()
+
+	auto_backup_setting := map[string]interface{}{"is_enabled": false, "backup_location": "", "backup_file_name": ""}
+
+	if err := service.Setup("12345", auto_backup_setting); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	login_service := InitBadgerLoginService()
+	login_service.Init()
+
+	err := login_service.AddLoginData(map[string]interface{}{"name": "GitHub", "url": "https://github.com", "attributes": map[string]interface{}{"is_favourite": false, "require_master_password": false},
+		"accounts": []interface{}{map[string]interface{}{"username": "abc", "password": "old"}}})
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	file_path := filepath.Join(t.TempDir(), "bitwarden_export.json")
+	os.WriteFile(file_path, []byte(`{"encrypted": false, "items": [
+		{"type": 1, "name": "github", "login": {"uris": [{"uri": "https://github.com"}], "username": "abc", "password": "123"}},
+		{"type": 1, "name": "github", "login": {"uris": [], "username": "pqr", "password": "456", "totp": "JBSWY3DPEHPK3PXP"}}
+	]}`), 0644)
+
+	checkReport := func(report models.ImportReport) {
+		if len(report.Logins) != 1 || !report.Logins[0].IsMerged || report.Logins[0].Name != "GitHub" || !slices.Equal(report.Logins[0].Usernames, []string{"pqr"}) {
+			t.Errorf("Incorrect logins %+v", report.Logins)
+		}
+
+		if len(report.Conflicts) != 1 || report.Conflicts[0].Username != "abc" {
+			t.Errorf("Incorrect conflicts %+v", report.Conflicts)
+		}
+	}
+
+	//Dry run shows accounts to be merged without adding them
+	report, err := service.ImportExternal(importer.BITWARDEN_JSON, file_path, true)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	checkReport(report)
+
+	if login_data, _ := login_service.GetLoginData("github"); len(login_data.Accounts) != 1 {
+		t.Errorf("Dry run should not add accounts\n%+v", login_data.Accounts)
+	}
+
+	report, err = service.ImportExternal(importer.BITWARDEN_JSON, file_path, false)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	checkReport(report)
+
+	if decrypted_password, _ := login_service.GetDecryptedAccountPassword("github", "pqr"); decrypted_password != "456" {
+		t.Errorf("Expected: %s\nActual: %s", "456", decrypted_password)
+	}
+
+	if _, err := login_service.GetTOTPCode("github", "pqr"); err != nil {
+		t.Error(err.Error())
+	}
+
+	//Existing account is left untouched
+	if decrypted_password, _ := login_service.GetDecryptedAccountPassword("github", "abc"); decrypted_password != "old" {
+		t.Errorf("Expected: %s\nActual: %s", "old", decrypted_password)
+	}
+
+	t.Cleanup(system_service_test_cleanup)
+}
+
+// Set up a vault with github (abc/123) and a note, export it, then change the vault so it differs from the export
+func setupImportTest(t *testing.T) *SystemService {
+	service := new(SystemService)
+	service.Init()
+
+	auto_backup_setting := map[string]interface{}{"is_enabled": false, "backup_location": "", "backup_file_name": ""}
+
+	if err := service.Setup("12345", auto_backup_setting); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	login_service := InitBadgerLoginService()
+	login_service.Init()
+
+	note_service := InitBadgerNoteService()
+	note_service.Init()
+
+	attributes := map[string]interface{}{"is_favourite": false, "require_master_password": false}
+
+	login_service.AddLoginData(map[string]interface{}{"name": "github", "url": "https://github.com", "attributes": attributes, "accounts": []interface{}{
+		map[string]interface{}{"username": "abc", "password": "123", "totp_secret": map[string]interface{}{"secret": "JBSWY3DPEHPK3PXP"}},
+	}})
+	note_service.AddNote(map[string]interface{}{"created_date_time": "2024-01-01T00:00:00Z", "title": "wifi", "content": "789", "attributes": attributes})
+
+	if err := service.Export("test_import.ncrypt", ""); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	login_service.UpdateLoginData("github", map[string]interface{}{"name": "github", "url": "https://github.com", "attributes": attributes, "accounts": []interface{}{
+		map[string]interface{}{"username": "abc", "password": "456"},
+	}})
+	login_service.AddLoginData(map[string]interface{}{"name": "gitlab", "url": "https://gitlab.com", "attributes": attributes, "accounts": []interface{}{
+		map[string]interface{}{"username": "abc", "password": "000"},
+	}})
+	note_service.UpdateNote("2024-01-01T00:00:00Z", map[string]interface{}{"created_date_time": "2024-01-01T00:00:00Z", "title": "wifi", "content": "987", "attributes": attributes})
+
+	return service
+}
+
+func checkImportedPassword(t *testing.T, login_data_name string, expected_password string) {
+	login_service := InitBadgerLoginService()
+	login_service.Init()
+
+	password, err := login_service.GetDecryptedAccountPassword(login_data_name, "abc")
+
+	if err != nil {
+		t.Errorf("%s: %s", login_data_name, err.Error())
+	} else if password != expected_password {
+		t.Errorf("%s\nExpected: %s\nActual: %s", login_data_name, expected_password, password)
+	}
+}
+
+func checkImportedNote(t *testing.T, created_date_time string, expected_content string) {
+	note_service := InitBadgerNoteService()
+	note_service.Init()
+
+	content, err := note_service.GetDecryptedContent(created_date_time)
+
+	if err != nil {
+		t.Errorf("%s: %s", created_date_time, err.Error())
+	} else if content != expected_content {
+		t.Errorf("%s\nExpected: %s\nActual: %s", created_date_time, expected_content, content)
+	}
+}
+
+func import_test_cleanup() {
+	os.Remove("test_import.ncrypt")
+	system_service_test_cleanup()
+}
+
+func TestImportWithStrategy_IncorrectMasterPassword(t *testing.T) {
+	service := setupImportTest(t)
+	t.Cleanup(import_test_cleanup)
+
+	if _, err := service.ImportWithStrategy("test_import.ncrypt", "", "123", models.IMPORT_REPLACE); err == nil {
+		t.Error("Should fail for incorrect master password")
+	}
+
+	if _, err := service.ImportWithStrategy("test_import.ncrypt", "", "12345", "UNKNOWN"); err == nil {
+		t.Error("Should fail for invalid strategy")
+	}
+
+	//Vault is left untouched
+	checkImportedPassword(t, "github", "456")
+	checkImportedPassword(t, "gitlab", "000")
+}
+
+func TestImportWithStrategy_Replace(t *testing.T) {
+	service := setupImportTest(t)
+	t.Cleanup(import_test_cleanup)
+
+	summary, err := service.ImportWithStrategy("test_import.ncrypt", "", "12345", models.IMPORT_REPLACE)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !slices.Equal(summary.Logins.Overwritten, []string{"github"}) || !slices.Equal(summary.Logins.Removed, []string{"gitlab"}) {
+		t.Errorf("Incorrect summary %+v", summary.Logins)
+	}
+
+	checkImportedPassword(t, "github", "123")
+	checkImportedNote(t, "2024-01-01T00:00:00Z", "789")
+
+	login_service := InitBadgerLoginService()
+	login_service.Init()
+
+	if _, err := login_service.GetLoginData("gitlab"); err != badger.ErrKeyNotFound {
+		t.Error("Login data not in the export should be removed")
+	}
+}
+
+func TestImportWithStrategy_Merge(t *testing.T) {
+	test_cases := []struct {
+		strategy         string
+		github_password  string
+		note_content     string
+		expected_summary func(summary models.ImportSummary) bool
+	}{
+		{models.IMPORT_KEEP_EXISTING, "456", "987", func(summary models.ImportSummary) bool {
+			return slices.Equal(summary.Logins.Skipped, []string{"github"}) && slices.Equal(summary.Notes.Skipped, []string{"2024-01-01T00:00:00Z"})
+		}},
+		{models.IMPORT_OVERWRITE, "123", "789", func(summary models.ImportSummary) bool {
+			return slices.Equal(summary.Logins.Overwritten, []string{"github"}) && slices.Equal(summary.Notes.Overwritten, []string{"2024-01-01T00:00:00Z"})
+		}},
+		{models.IMPORT_KEEP_BOTH, "456", "987", func(summary models.ImportSummary) bool {
+			// Note keys stay valid times
+			return slices.Equal(summary.Logins.Renamed, []models.ImportRename{{From: "github", To: "github (1)"}}) &&
+				slices.Equal(summary.Notes.Renamed, []models.ImportRename{{From: "2024-01-01T00:00:00Z", To: "2024-01-01T00:00:00.001Z"}})
+		}},
+	}
+
+	for _, test_case := range test_cases {
+		t.Run(test_case.strategy, func(t *testing.T) {
+			service := setupImportTest(t)
+			t.Cleanup(import_test_cleanup)
+
+			summary, err := service.ImportWithStrategy("test_import.ncrypt", "", "12345", test_case.strategy)
+
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			if !test_case.expected_summary(summary) || len(summary.Logins.Removed) != 0 {
+				t.Errorf("Incorrect summary %+v", summary)
+			}
+
+			checkImportedPassword(t, "github", test_case.github_password)
+			checkImportedPassword(t, "gitlab", "000")
+			checkImportedNote(t, "2024-01-01T00:00:00Z", test_case.note_content)
+
+			if test_case.strategy == models.IMPORT_KEEP_BOTH {
+				checkImportedPassword(t, "github (1)", "123")
+				checkImportedNote(t, "2024-01-01T00:00:00.001Z", "789")
+
+				note_service := InitBadgerNoteService()
+				note_service.Init()
+
+				if notes, _ := note_service.GetAllNotes(); len(notes) != 2 || notes[0].Title != notes[1].Title {
+					t.Errorf("Title of the copied note should be unchanged\n%+v", notes)
+				}
+
+				login_service := InitBadgerLoginService()
+				login_service.Init()
+
+				if _, err := login_service.GetTOTPCode("github (1)", "abc"); err != nil {
+					t.Error(err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestInspectBackup(t *testing.T) {
+	service := setupImportTest(t)
+	t.Cleanup(import_test_cleanup)
+
+	header, err := service.InspectBackup("test_import.ncrypt", "")
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if header.FormatVersion != backup.CURRENT_FORMAT_VERSION || header.Cipher != backup.CIPHER_AES_256_GCM || header.KDF.Algorithm != backup.KDF_ARGON2ID || header.LoginCount != 1 || header.NoteCount != 1 {
+		t.Errorf("Incorrect header %+v", header)
+	}
+
+	data, _ := os.ReadFile("test_import.ncrypt")
+
+	if strings.Contains(string(data), "argon2id$") {
+		t.Error("Master password record should not be exported")
+	}
+}
+
+func TestVerifyBackup(t *testing.T) {
+	service := setupImportTest(t)
+	t.Cleanup(import_test_cleanup)
+
+	if _, err := service.VerifyBackup("test_import.ncrypt", "", "123"); err == nil {
+		t.Error("Should fail for incorrect master password")
+	}
+
+	verification, err := service.VerifyBackup("test_import.ncrypt", "", "12345")
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !verification.IsValid || verification.LoginCount != 1 || verification.NoteCount != 1 || !verification.IsComparedWithVault {
+		t.Errorf("Incorrect verification %+v", verification)
+	}
+
+	if !slices.Equal(verification.Logins.Different, []string{"github"}) || !slices.Equal(verification.Logins.OnlyInVault, []string{"gitlab"}) || len(verification.Logins.OnlyInBackup) != 0 {
+		t.Errorf("Incorrect login diff %+v", verification.Logins)
+	}
+
+	if !slices.Equal(verification.Notes.Different, []string{"2024-01-01T00:00:00Z"}) {
+		t.Errorf("Incorrect note diff %+v", verification.Notes)
+	}
+
+	//Vault is left untouched
+	checkImportedPassword(t, "github", "456")
+	checkImportedPassword(t, "gitlab", "000")
+
+	//Modified header fails authentication
+	data, _ := os.ReadFile("test_import.ncrypt")
+	os.WriteFile("test_import.ncrypt", []byte(strings.Replace(string(data), `"login_count":1`, `"login_count":2`, 1)), 0644)
+
+	if _, err := service.VerifyBackup("test_import.ncrypt", "", "12345"); err == nil {
+		t.Error("Should fail for modified header")
+	}
+}
+
+func TestVerifyBackup_StorageFolderUnchanged(t *testing.T) {
+	service := setupImportTest(t)
+	t.Cleanup(import_test_cleanup)
+
+	storage_folder := os.Getenv("STORAGE_FOLDER")
+	entries_before, _ := os.ReadDir(storage_folder)
+	info_before, _ := os.Stat(storage_folder)
+
+	if _, err := service.VerifyBackup("test_import.ncrypt", "", "12345"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	entries_after, _ := os.ReadDir(storage_folder)
+	info_after, _ := os.Stat(storage_folder)
+
+	var names_before, names_after []string
+	for _, entry := range entries_before {
+		names_before = append(names_before, entry.Name())
+	}
+	for _, entry := range entries_after {
+		names_after = append(names_after, entry.Name())
+	}
+
+	if !slices.Equal(names_before, names_after) {
+		t.Errorf("Expected: %v\nActual: %v", names_before, names_after)
+	}
+
+	//Creating and removing a journal would change the folder even if nothing is left behind
+	if !info_before.ModTime().Equal(info_after.ModTime()) {
+		t.Error("Storage folder should not be modified")
+	}
+}
+
+func TestImport_LegacyFormat(t *testing.T) {
+	service := setupImportTest(t)
+	t.Cleanup(import_test_cleanup)
+
+	login_service := InitBadgerLoginService()
+	login_service.Init()
+
+	login_data_list, _ := login_service.GetAllLoginData()
+	system_data, _ := service.GetSystemData()
+	master_password_record, _ := service.master_password_service.getMasterPasswordRecord()
+
+	//Exports written before the container was introduced
+	export_data_bytes, _ := json.Marshal(ExportData{SYSTEM_DATA: *system_data, LOGIN_DATA: login_data_list, MASTER_PASSWORD: master_password_record})
+	encrypted_export_data, _ := encryptor.Encrypt(base64.StdEncoding.EncodeToString(export_data_bytes), encryptor.CreateHash("12345"))
+	os.WriteFile("test_import.ncrypt", []byte(encrypted_export_data), 0644)
+
+	header, err := service.InspectBackup("test_import.ncrypt", "")
+
+	if err != nil || header.FormatVersion != backup.LEGACY_FORMAT_VERSION {
+		t.Errorf("Expected legacy format\n%+v %v", header, err)
+	}
+
+	if _, err := service.ImportWithStrategy("test_import.ncrypt", "", "123", models.IMPORT_REPLACE); err == nil {
+		t.Error("Should fail for incorrect master password")
+	}
+
+	if _, err := service.ImportWithStrategy("test_import.ncrypt", "", "12345", models.IMPORT_REPLACE); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	checkImportedPassword(t, "github", "456")
+	checkImportedPassword(t, "gitlab", "000")
+}
+
+func TestAuditLog(t *testing.T) {
+	service := new(SystemService)
+	service.Init()
+
+	password := "12345"
+	auto_backup_setting := map[string]interface{}{"is_enabled": false, "backup_location": "", "backup_file_name": ""}
+
+	if err := service.Setup(password, auto_backup_setting); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	start := time.Now().Add(-time.Second).Format(time.RFC3339)
+
+	service.SignIn("123")
+	if _, err := service.SignIn(password); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	login_service := InitBadgerLoginService()
+	login_service.Init()
+
+	attributes := map[string]interface{}{"is_favourite": false, "require_master_password": false}
+	login_service.AddLoginData(map[string]interface{}{"name": "github", "url": "https://github.com", "attributes": attributes, "accounts": []interface{}{
+		map[string]interface{}{"username": "abc", "password": "secret_password", "totp_secret": map[string]interface{}{"secret": "JBSWY3DPEHPK3PXP"}},
+	}})
+
+	if _, err := login_service.GetDecryptedAccountPassword("github", "abc"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, err := login_service.GetTOTPCode("github", "abc"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	login_service.DeleteLoginData("github")
+	service.Logout()
+
+	entries, err := service.GetAuditLog("", "", nil)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var event_types []string
+	for _, entry := range entries {
+		event_types = append(event_types, entry.EventType)
+
+		if strings.Contains(entry.Target+entry.Detail, "secret_password") || strings.Contains(entry.Target+entry.Detail, "JBSWY3DPEHPK3PXP") {
+			t.Error("Audit log should not contain secrets")
+		}
+	}
+
+	expected := []string{AUDIT_SIGN_IN_FAILED, AUDIT_SIGN_IN, AUDIT_LOGIN_CREATED, AUDIT_REVEAL_PASSWORD, AUDIT_REVEAL_TOTP, AUDIT_LOGIN_DELETED, AUDIT_SIGN_OUT}
+	if !slices.Equal(event_types, expected) {
+		t.Errorf("Expected events %v, got %v", expected, event_types)
+	}
+
+	event_type_filter := []string{"reveal_password", AUDIT_SIGN_OUT}
+	entries, _ = service.GetAuditLog("", "", event_type_filter)
+
+	if len(entries) != 2 || entries[0].Target != "github" {
+		t.Errorf("Event type filter returned %+v", entries)
+	}
+
+	if event_type_filter[0] != "reveal_password" {
+		t.Errorf("Event types passed in should not be modified, got %v", event_type_filter)
+	}
+
+	if entries, _ = service.GetAuditLog(start, "", nil); len(entries) != len(expected) {
+		t.Errorf("Expected %d entries from %s, got %d", len(expected), start, len(entries))
+	}
+
+	if entries, _ = service.GetAuditLog("", start, nil); len(entries) != 0 {
+		t.Errorf("Expected no entries before %s, got %d", start, len(entries))
+	}
+
+	if _, err := service.GetAuditLog("yesterday", "", nil); err == nil {
+		t.Error("Should fail for invalid from date time")
+	}
+
+	verification, err := service.VerifyAuditLog()
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !verification.IsValid || verification.EntryCount != len(expected) {
+		t.Errorf("Audit log should be valid: %+v", verification)
+	}
+
+	t.Cleanup(system_service_test_cleanup)
+}
+
+func TestVerifyAuditLog_Tampered(t *testing.T) {
+	service := new(SystemService)
+	service.Init()
+
+	for _, event_type := range []string{AUDIT_SIGN_IN, AUDIT_EXPORT, AUDIT_SIGN_OUT} {
+		recordAuditEvent(event_type, "", "")
+	}
+
+	audit_log_database := auditLogDatabase()
+	entries, _ := getAuditLogEntries(audit_log_database)
+
+	// Hide an export
+	modified_entry := entries[1]
+	modified_entry.EventType = AUDIT_SIGN_IN
+	audit_log_database.AddData(auditLogKey(modified_entry.Sequence), modified_entry)
+
+	verification, _ := service.VerifyAuditLog()
+
+	if verification.IsValid || verification.FirstInvalidSequence != 2 {
+		t.Errorf("Modified entry should be detected: %+v", verification)
+	}
+
+	// Rewriting the hash breaks the link to the next entry
+	modified_entry.Hash = auditLogHash(modified_entry)
+	audit_log_database.AddData(auditLogKey(modified_entry.Sequence), modified_entry)
+
+	verification, _ = service.VerifyAuditLog()
+
+	if verification.IsValid || verification.FirstInvalidSequence != 3 {
+		t.Errorf("Rewritten entry should be detected: %+v", verification)
+	}
+
+	// Removing entries from the end
+	audit_log_database.AddData(auditLogKey(modified_entry.Sequence), entries[1])
+	audit_log_database.DeleteData(auditLogKey(3))
+
+	verification, _ = service.VerifyAuditLog()
+
+	if verification.IsValid || verification.FirstInvalidSequence != 3 {
+		t.Errorf("Removed entry should be detected: %+v", verification)
+	}
+
+	t.Cleanup(system_service_test_cleanup)
+}
+
+func TestShutdown(t *testing.T) {
+	service := new(SystemService)
+	service.Init()
+
+	location := t.TempDir()
+	auto_backup_setting := map[string]interface{}{"is_enabled": true, "backup_location": "", "backup_file_name": filepath.Join(location, "test_backup")}
+
+	if err := service.Setup("12345", auto_backup_setting); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, err := service.SignIn("12345"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := service.Shutdown(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	system_data, _ := service.GetSystemData()
+
+	if system_data.IsLoggedIn {
+		t.Error("Should be logged out")
+	}
+
+	if files, _ := backup.List(location, "test_backup"); len(files) != 1 {
+		t.Errorf("Expected final backup\n%+v", files)
+	}
+
+	if _, err := service.master_password_service.getMasterKeys(); err != ErrVaultLocked {
+		t.Errorf("Expected: %v\nActual: %v", ErrVaultLocked, err)
+	}
+
+	// Nothing to back up once locked
+	if err := service.Shutdown(); err != nil {
+		t.Error(err.Error())
+	}
+
+	if files, _ := backup.List(location, "test_backup"); len(files) != 1 {
+		t.Errorf("No backup expected while locked\n%+v", files)
+	}
+
+	t.Cleanup(system_service_test_cleanup)
+}
+
+func TestInit_UILauncher(t *testing.T) {
+	executable_path, err := exec.LookPath("true")
+
+	if err != nil {
+		t.Skip("true executable not found")
+	}
+
+	service := new(SystemService)
+	service.InitHeadless()
+	service.Setup("12345", map[string]interface{}{"is_enabled": false, "backup_location": "", "backup_file_name": ""})
+	service.SignIn("12345")
+
+	launcher := InitExecutableUILauncher(executable_path)
+	SetUILauncher(launcher)
+
+	t.Cleanup(func() {
+		SetUILauncher(InitHeadlessUILauncher())
+		system_service_test_cleanup()
+	})
+
+	service.Init()
+
+	select {
+	case <-launcher.Closed():
+	case <-time.After(5 * time.Second):
+		t.Fatal("UI should have quit")
+	}
+
+	system_data, _ := service.GetSystemData()
+
+	if system_data.IsLoggedIn {
+		t.Error("Should be logged out once the UI quits")
+	}
+
+	if err := InitExecutableUILauncher(filepath.Join(t.TempDir(), "missing")).Launch(nil, func() {}); err == nil {
+		t.Error("Should fail for missing executable")
+	}
+}
+
 func system_service_test_cleanup() {
+	database.Close()
 	os.RemoveAll(os.Getenv("STORAGE_FOLDER"))
 }
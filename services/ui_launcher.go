@@ -0,0 +1,84 @@
+package services
+
+import (
+	"ncrypt/utils/logger"
+	"os/exec"
+	"sync"
+)
+
+// Title of the UI window, used to bring an already running instance to the front
+const UI_WINDOW_TITLE = "NCRYPT"
+
+var (
+	ui_launcher_lock sync.RWMutex
+	ui_launcher      IUILauncher = InitHeadlessUILauncher()
+)
+
+// Set UI launched by SystemService.Init. Defaults to headless so tests and the CLI never start a UI
+func SetUILauncher(launcher IUILauncher) {
+	ui_launcher_lock.Lock()
+	defer ui_launcher_lock.Unlock()
+
+	ui_launcher = launcher
+}
+
+func getUILauncher() IUILauncher {
+	ui_launcher_lock.RLock()
+	defer ui_launcher_lock.RUnlock()
+
+	return ui_launcher
+}
+
+// Runs the desktop UI executable with the server port, whether the user is new and the theme as arguments
+type ExecutableUILauncher struct {
+	executable_path string
+	closed          chan struct{}
+	launch_once     sync.Once
+}
+
+func (obj *ExecutableUILauncher) Launch(args []string, on_exit func()) error {
+	var err error
+
+	// Controllers each initialize a system service, only the first one starts the UI
+	obj.launch_once.Do(func() {
+		logger.Log.Printf("Launching UI")
+		cmd := exec.Command(obj.executable_path, args...)
+
+		err = cmd.Start()
+
+		if err != nil {
+			logger.Log.Printf("ERROR: %s", err.Error())
+			return
+		}
+
+		go func() {
+			if err := cmd.Wait(); err != nil {
+				logger.Log.Printf("ERROR: UI exited with %s", err.Error())
+			}
+
+			logger.Log.Printf("UI closed")
+			on_exit()
+			close(obj.closed)
+		}()
+
+		restoreAndBringWindowToFront(UI_WINDOW_TITLE)
+	})
+
+	return err
+}
+
+func (obj *ExecutableUILauncher) Closed() <-chan struct{} {
+	return obj.closed
+}
+
+// Used when running as a server or library. Clients connect to the server on their own
+type HeadlessUILauncher struct{}
+
+func (obj *HeadlessUILauncher) Launch(args []string, on_exit func()) error {
+	logger.Log.Printf("Running headless, UI not launched")
+	return nil
+}
+
+func (obj *HeadlessUILauncher) Closed() <-chan struct{} {
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package services
+
+// Windows are only managed on Windows
+func restoreAndBringWindowToFront(title string) error {
+	return nil
+}
@@ -0,0 +1,43 @@
+//go:build windows
+
+package services
+
+import (
+	"ncrypt/utils/logger"
+	"syscall"
+	"unsafe"
+)
+
+func restoreAndBringWindowToFront(title string) error {
+	user32 := syscall.NewLazyDLL("user32.dll")
+
+	findWindow := user32.NewProc("FindWindowW")
+	setForegroundWindow := user32.NewProc("SetForegroundWindow")
+
+	showWindow := user32.NewProc("ShowWindow")
+	const SW_RESTORE = 9
+
+	// Convert string to UTF16
+	u16Title, err := syscall.UTF16PtrFromString(title)
+	if err != nil {
+		return err
+	}
+
+	// Find the window by its title
+	hwnd, _, err := findWindow.Call(0, uintptr(unsafe.Pointer(u16Title)))
+	if hwnd == 0 {
+		logger.Log.Printf("ERROR: window not found: %v", err.Error())
+		return err
+	}
+
+	// Restore the window if minimized
+	showWindow.Call(hwnd, SW_RESTORE)
+
+	// Bring the window to the foreground
+	_, _, err = setForegroundWindow.Call(hwnd)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
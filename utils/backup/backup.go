@@ -0,0 +1,142 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"ncrypt/models"
+	"ncrypt/utils/encryptor"
+	"strconv"
+)
+
+/*
+Layout of a .ncrypt container:
+
+	MAGIC | format version (1 byte) | header length (4 bytes, big endian) | header JSON | ciphertext
+
+Header is cleartext so a backup can be inspected without the master password. Ciphertext is AES-GCM output of encryptor.Encrypt
+with the magic, format version and header bytes as associated data, so modifying the header makes decryption fail.
+
+Files written before the container was introduced (format version 1) have no magic and are the ciphertext alone.
+*/
+const (
+	MAGIC = "NCRYPT"
+
+	LEGACY_FORMAT_VERSION  = 1
+	CURRENT_FORMAT_VERSION = 2
+
+	CIPHER_AES_256_GCM = "AES-256-GCM"
+	KDF_ARGON2ID       = "argon2id"
+
+	// Headers are a few hundred bytes, anything larger is a corrupted file
+	MAX_HEADER_LENGTH = 64 * 1024
+)
+
+var ErrInvalidContainer = errors.New("invalid .ncrypt container")
+
+// Check if data starts with the container magic. Files without it are in the legacy format
+func IsContainer(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(MAGIC))
+}
+
+// Build a container of payload encrypted with key. Format version and cipher of the header are filled in
+func Seal(header models.BackupHeader, payload []byte, key string) ([]byte, error) {
+	header.FormatVersion = CURRENT_FORMAT_VERSION
+	header.Cipher = CIPHER_AES_256_GCM
+
+	header_bytes, err := json.Marshal(header)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := encryptor.Encrypt(string(payload), key, associatedData(CURRENT_FORMAT_VERSION, header_bytes)...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data := append([]byte(MAGIC), CURRENT_FORMAT_VERSION)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(header_bytes)))
+	data = append(data, header_bytes...)
+	data = append(data, ciphertext...)
+
+	return data, nil
+}
+
+// Read header of a container without decrypting it
+func ReadHeader(data []byte) (models.BackupHeader, error) {
+	header, _, _, err := split(data)
+
+	return header, err
+}
+
+// Decrypt payload of a container. Returns encryptor.ErrTampered if the key is incorrect or the file was modified
+func Open(data []byte, key string) (models.BackupHeader, []byte, error) {
+	header, header_bytes, ciphertext, err := split(data)
+
+	if err != nil {
+		return header, nil, err
+	}
+
+	payload, err := encryptor.Decrypt(string(ciphertext), key, associatedData(header.FormatVersion, header_bytes)...)
+
+	if err != nil {
+		return header, nil, err
+	}
+
+	return header, []byte(payload), nil
+}
+
+func split(data []byte) (models.BackupHeader, []byte, []byte, error) {
+	prefix_length := len(MAGIC) + 1 + 4
+
+	if !IsContainer(data) || len(data) < prefix_length {
+		return models.BackupHeader{}, nil, nil, ErrInvalidContainer
+	}
+
+	format_version := int(data[len(MAGIC)])
+
+	if format_version != CURRENT_FORMAT_VERSION {
+		return models.BackupHeader{}, nil, nil, errors.New("unsupported .ncrypt format version " + strconv.Itoa(format_version))
+	}
+
+	header_length := binary.BigEndian.Uint32(data[len(MAGIC)+1 : prefix_length])
+
+	if header_length > MAX_HEADER_LENGTH || int(header_length) > len(data)-prefix_length {
+		return models.BackupHeader{}, nil, nil, ErrInvalidContainer
+	}
+
+	header_bytes := data[prefix_length : prefix_length+int(header_length)]
+
+	var header models.BackupHeader
+	if err := json.Unmarshal(header_bytes, &header); err != nil {
+		return models.BackupHeader{}, nil, nil, ErrInvalidContainer
+	}
+
+	// Version in the prefix is the one authenticated along with the header
+	header.FormatVersion = format_version
+
+	return header, header_bytes, data[prefix_length+int(header_length):], nil
+}
+
+func associatedData(format_version int, header_bytes []byte) []string {
+	return []string{MAGIC, strconv.Itoa(format_version), string(header_bytes)}
+}
+
+// Convert KDF params to their header representation
+func KDFHeader(params encryptor.KDFParams) models.BackupKDF {
+	return models.BackupKDF{Algorithm: KDF_ARGON2ID, Memory: params.Memory, Iterations: params.Iterations, Parallelism: params.Parallelism, Salt: params.Salt}
+}
+
+// Get KDF params stored in a header
+func KDFParams(header models.BackupHeader) (encryptor.KDFParams, error) {
+	kdf := header.KDF
+
+	if kdf.Algorithm != KDF_ARGON2ID || len(kdf.Salt) == 0 || kdf.Memory == 0 || kdf.Iterations == 0 || kdf.Parallelism == 0 {
+		return encryptor.KDFParams{}, errors.New("unsupported kdf in .ncrypt header")
+	}
+
+	return encryptor.KDFParams{Memory: kdf.Memory, Iterations: kdf.Iterations, Parallelism: kdf.Parallelism, Salt: kdf.Salt}, nil
+}
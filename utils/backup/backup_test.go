@@ -0,0 +1,78 @@
+package backup
+
+import (
+	"bytes"
+	"ncrypt/models"
+	"ncrypt/utils/encryptor"
+	"testing"
+)
+
+func TestSealAndOpen(t *testing.T) {
+	header := models.BackupHeader{CreatedDateTime: "2024-01-01T00:00:00Z", LoginCount: 2, NoteCount: 1, KDF: models.BackupKDF{Algorithm: KDF_ARGON2ID, Memory: 8, Iterations: 1, Parallelism: 1, Salt: []byte("0123456789abcdef")}}
+	payload := []byte(`{"LOGIN_DATA": []}`)
+
+	data, err := Seal(header, payload, "key")
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !IsContainer(data) {
+		t.Fatal("Sealed data should be a container")
+	}
+
+	read_header, err := ReadHeader(data)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if read_header.FormatVersion != CURRENT_FORMAT_VERSION || read_header.Cipher != CIPHER_AES_256_GCM || read_header.LoginCount != 2 || read_header.NoteCount != 1 {
+		t.Errorf("Incorrect header %+v", read_header)
+	}
+
+	if _, err := KDFParams(read_header); err != nil {
+		t.Error(err.Error())
+	}
+
+	_, opened_payload, err := Open(data, "key")
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !bytes.Equal(opened_payload, payload) {
+		t.Errorf("Expected: %s\nActual: %s", payload, opened_payload)
+	}
+
+	if _, _, err := Open(data, "wrong key"); err != encryptor.ErrTampered {
+		t.Errorf("Expected: %v\nActual: %v", encryptor.ErrTampered, err)
+	}
+}
+
+func TestOpen_ModifiedHeader(t *testing.T) {
+	data, err := Seal(models.BackupHeader{LoginCount: 2}, []byte("payload"), "key")
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	modified_data := bytes.Replace(data, []byte(`"login_count":2`), []byte(`"login_count":5`), 1)
+
+	if _, _, err := Open(modified_data, "key"); err != encryptor.ErrTampered {
+		t.Errorf("Expected: %v\nActual: %v", encryptor.ErrTampered, err)
+	}
+}
+
+func TestReadHeader_Invalid(t *testing.T) {
+	for _, data := range [][]byte{
+		[]byte("$03abcdef"),
+		[]byte(MAGIC),
+		append([]byte(MAGIC), CURRENT_FORMAT_VERSION, 0xff, 0xff, 0xff, 0xff),
+		append([]byte(MAGIC), 9, 0, 0, 0, 2, '{', '}'),
+	} {
+		if _, err := ReadHeader(data); err == nil {
+			t.Errorf("Should fail for %q", data)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package backup
+
+import (
+	"fmt"
+	"ncrypt/models"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const FILE_EXTENSION = ".ncrypt"
+
+// Backup file written by automatic backups
+type File struct {
+	Name            string
+	CreatedDateTime time.Time
+}
+
+// Name of an automatic backup file - <name>_<RFC3339 time with ':' replaced by '-'>.ncrypt
+func FileName(base_name string, created_date_time time.Time) string {
+	return base_name + "_" + strings.ReplaceAll(created_date_time.Format(time.RFC3339), ":", "-") + FILE_EXTENSION
+}
+
+// Get created time from name of an automatic backup file, false if the file was not written for base_name
+func parseFileName(file_name string, base_name string) (time.Time, bool) {
+	prefix := base_name + "_"
+
+	if !strings.HasPrefix(file_name, prefix) || !strings.HasSuffix(file_name, FILE_EXTENSION) {
+		return time.Time{}, false
+	}
+
+	timestamp := strings.TrimSuffix(strings.TrimPrefix(file_name, prefix), FILE_EXTENSION)
+	layout := "2006-01-02T15-04-05Z"
+
+	// Offset is the only part where '-' has to be put back to ':'
+	if !strings.HasSuffix(timestamp, "Z") {
+		if len(timestamp) < 6 {
+			return time.Time{}, false
+		}
+
+		timestamp = timestamp[:len(timestamp)-3] + ":" + timestamp[len(timestamp)-2:]
+		layout = "2006-01-02T15-04-05Z07:00"
+	}
+
+	created_date_time, err := time.Parse(layout, timestamp)
+
+	return created_date_time, err == nil
+}
+
+// List automatic backups of base_name in location, most recent first. Other files in location are ignored
+func List(location string, base_name string) ([]File, error) {
+	// File name may include a folder
+	location = filepath.Join(location, filepath.Dir(base_name))
+	base_name = filepath.Base(base_name)
+
+	entries, err := os.ReadDir(location)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var files []File
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		if created_date_time, ok := parseFileName(entry.Name(), base_name); ok {
+			files = append(files, File{Name: filepath.Join(location, entry.Name()), CreatedDateTime: created_date_time})
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].CreatedDateTime.After(files[j].CreatedDateTime)
+	})
+
+	return files, nil
+}
+
+/*
+Get backups that are not kept by the retention rules. files has to be sorted most recent first.
+
+Daily and weekly rules keep the latest backup of each day/week within the last KeepDailyForDays days/KeepWeeklyForWeeks weeks
+of now. Nothing is expired if no rule is set.
+*/
+func Expired(files []File, retention models.BackupRetention, now time.Time) []File {
+	if retention.KeepLast <= 0 && retention.KeepDailyForDays <= 0 && retention.KeepWeeklyForWeeks <= 0 {
+		return nil
+	}
+
+	kept_days := make(map[string]bool)
+	kept_weeks := make(map[string]bool)
+
+	var expired []File
+	for index, file := range files {
+		is_kept := index < retention.KeepLast
+
+		day := file.CreatedDateTime.In(now.Location()).Format(time.DateOnly)
+		if now.Sub(file.CreatedDateTime) < time.Duration(retention.KeepDailyForDays)*24*time.Hour && !kept_days[day] {
+			kept_days[day] = true
+			is_kept = true
+		}
+
+		year, week_number := file.CreatedDateTime.In(now.Location()).ISOWeek()
+		week := fmt.Sprintf("%d-%02d", year, week_number)
+		if now.Sub(file.CreatedDateTime) < time.Duration(retention.KeepWeeklyForWeeks)*7*24*time.Hour && !kept_weeks[week] {
+			kept_weeks[week] = true
+			is_kept = true
+		}
+
+		if !is_kept {
+			expired = append(expired, file)
+		}
+	}
+
+	return expired
+}
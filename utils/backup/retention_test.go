@@ -0,0 +1,78 @@
+package backup
+
+import (
+	"ncrypt/models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileName(t *testing.T) {
+	for _, created_date_time := range []time.Time{
+		time.Date(2024, 3, 1, 10, 30, 15, 0, time.UTC),
+		time.Date(2024, 3, 1, 10, 30, 15, 0, time.FixedZone("IST", 5*60*60+30*60)),
+		time.Date(2024, 3, 1, 10, 30, 15, 0, time.FixedZone("EST", -5*60*60)),
+	} {
+		file_name := FileName("backup", created_date_time)
+		parsed_date_time, ok := parseFileName(file_name, "backup")
+
+		if !ok || !parsed_date_time.Equal(created_date_time) {
+			t.Errorf("%s\nExpected: %v\nActual: %v", file_name, created_date_time, parsed_date_time)
+		}
+	}
+
+	for _, file_name := range []string{"backup.ncrypt", "other_2024-03-01T10-30-15Z.ncrypt", "backup_2024-03-01T10-30-15Z.txt", "backup_yesterday.ncrypt"} {
+		if _, ok := parseFileName(file_name, "backup"); ok {
+			t.Errorf("%s should not be an automatic backup", file_name)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	location := t.TempDir()
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	for _, file_name := range []string{FileName("backup", now.Add(-time.Hour)), FileName("backup", now), FileName("other", now), "backup.ncrypt"} {
+		os.WriteFile(filepath.Join(location, file_name), nil, 0644)
+	}
+
+	files, err := List(location, "backup")
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(files) != 2 || !files[0].CreatedDateTime.Equal(now) {
+		t.Errorf("Incorrect files %+v", files)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+
+	// Two backups a day for the last 60 days, most recent first
+	var files []File
+	for hours := 0; hours < 60*24; hours += 12 {
+		files = append(files, File{Name: "backup", CreatedDateTime: now.Add(-time.Duration(hours) * time.Hour)})
+	}
+
+	test_cases := []struct {
+		retention      models.BackupRetention
+		expected_count int
+	}{
+		{models.BackupRetention{}, len(files)},
+		{models.BackupRetention{KeepLast: 3}, 3},
+		{models.BackupRetention{KeepDailyForDays: 7}, 7},
+		// Latest backup of each of the last 7 days and of the 3 weeks before the current one
+		{models.BackupRetention{KeepLast: 1, KeepDailyForDays: 7, KeepWeeklyForWeeks: 4}, 7 + 3},
+	}
+
+	for _, test_case := range test_cases {
+		kept_count := len(files) - len(Expired(files, test_case.retention, now))
+
+		if kept_count != test_case.expected_count {
+			t.Errorf("%+v\nExpected: %d\nActual: %d", test_case.retention, test_case.expected_count, kept_count)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package breach
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	HASH_LENGTH   = 40 // SHA-1 in hex
+	PREFIX_LENGTH = 5  // Length of hash prefix used to name range files
+)
+
+var ErrInvalidCorpus = errors.New("invalid breach corpus")
+
+/*
+Locally downloaded Have I Been Pwned password corpus. Nothing is sent over the network.
+
+Two layouts are supported:
+  - A single file of "HASH:COUNT" lines ordered by hash, as downloaded from HIBP. Looked up using binary search directly on
+    the file so the corpus (tens of GBs) is never loaded into memory.
+  - A directory of range files named by the 5 character hash prefix (e.g. 5BAA6.txt) containing "SUFFIX:COUNT" lines,
+    as written by the HIBP downloader.
+*/
+type Corpus struct {
+	path         string
+	is_directory bool
+	file         *os.File
+	size         int64
+}
+
+func Open(path string) (*Corpus, error) {
+	file_info, err := os.Stat(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if file_info.IsDir() {
+		return &Corpus{path: path, is_directory: true}, nil
+	}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Corpus{path: path, file: file, size: file_info.Size()}, nil
+}
+
+func (obj *Corpus) Close() error {
+	if obj.file == nil {
+		return nil
+	}
+
+	return obj.file.Close()
+}
+
+// Number of times the password appears in breaches, 0 if not found
+func (obj *Corpus) Count(password string) (int, error) {
+	hash := sha1.Sum([]byte(password))
+	return obj.CountHash(strings.ToUpper(hex.EncodeToString(hash[:])))
+}
+
+// Same as Count for an upper case hex SHA-1 hash
+func (obj *Corpus) CountHash(hash string) (int, error) {
+	if obj.is_directory {
+		return obj.searchRangeFile(hash)
+	}
+
+	return obj.searchSortedFile(hash)
+}
+
+func (obj *Corpus) searchRangeFile(hash string) (int, error) {
+	file, err := os.Open(filepath.Join(obj.path, hash[:PREFIX_LENGTH]+".txt"))
+
+	if err != nil {
+		// No range file means nothing with this prefix was breached
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		suffix, count, err := parseLine(scanner.Text())
+
+		if err != nil {
+			return 0, err
+		}
+
+		if suffix == hash[PREFIX_LENGTH:] {
+			return count, nil
+		}
+	}
+
+	return 0, scanner.Err()
+}
+
+/*
+Binary search over byte offsets of the file. Invariant is that the line for hash, if present, starts in [low, high).
+Each step reads the first line starting at or after the midpoint and halves the range, so a lookup takes ~log2(file size) small reads.
+*/
+func (obj *Corpus) searchSortedFile(hash string) (int, error) {
+	low, high := int64(0), obj.size
+
+	for low < high {
+		middle := low + (high-low)/2
+
+		line_start, line, err := obj.lineAt(middle)
+
+		if err != nil {
+			return 0, err
+		}
+
+		// No line starts in [middle, high)
+		if line_start >= high || line == "" {
+			high = middle
+			continue
+		}
+
+		line_hash, count, err := parseLine(line)
+
+		if err != nil {
+			return 0, err
+		}
+
+		if len(line_hash) != HASH_LENGTH {
+			return 0, ErrInvalidCorpus
+		}
+
+		switch strings.Compare(line_hash, hash) {
+		case 0:
+			return count, nil
+		case -1:
+			low = line_start + int64(len(line))
+		default:
+			high = middle
+		}
+	}
+
+	return 0, nil
+}
+
+// First line (including line ending) that starts at or after offset
+func (obj *Corpus) lineAt(offset int64) (int64, string, error) {
+	line_start := offset
+
+	// Offset might be in the middle of a line, so skip to the start of the next one
+	if offset > 0 {
+		line_start = offset - 1
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(obj.file, line_start, obj.size-line_start))
+
+	if offset > 0 {
+		skipped, err := reader.ReadString('\n')
+
+		if err == io.EOF {
+			return obj.size, "", nil
+		} else if err != nil {
+			return 0, "", err
+		}
+
+		line_start += int64(len(skipped))
+	}
+
+	line, err := reader.ReadString('\n')
+
+	if err != nil && err != io.EOF {
+		return 0, "", err
+	}
+
+	return line_start, line, nil
+}
+
+// Split "HASH:COUNT" line, ignoring line endings
+func parseLine(line string) (string, int, error) {
+	hash, count, found := strings.Cut(strings.TrimSpace(line), ":")
+
+	if !found {
+		return "", 0, ErrInvalidCorpus
+	}
+
+	parsed_count, err := strconv.Atoi(count)
+
+	if err != nil {
+		return "", 0, ErrInvalidCorpus
+	}
+
+	return strings.ToUpper(hash), parsed_count, nil
+}
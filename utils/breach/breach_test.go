@@ -0,0 +1,137 @@
+package breach
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var breached_passwords = map[string]int{
+	"password": 10434004,
+	"123456":   37359195,
+	"qwerty":   10556095,
+	"letmein":  711122,
+}
+
+// Corpus lines for breached_passwords along with random hashes, sorted by hash
+func corpusLines(random_count int) []string {
+	var lines []string
+
+	for password, count := range breached_passwords {
+		hash := sha1.Sum([]byte(password))
+		lines = append(lines, strings.ToUpper(hex.EncodeToString(hash[:]))+":"+strconv.Itoa(count))
+	}
+
+	for index := range random_count {
+		random_bytes := make([]byte, sha1.Size)
+		rand.Read(random_bytes)
+		lines = append(lines, strings.ToUpper(hex.EncodeToString(random_bytes))+":"+strconv.Itoa(index+1))
+	}
+
+	slices.Sort(lines)
+	return lines
+}
+
+func checkCorpus(t *testing.T, corpus *Corpus) {
+	for password, expected_count := range breached_passwords {
+		count, err := corpus.Count(password)
+
+		if err != nil {
+			t.Error(err.Error())
+		}
+
+		if count != expected_count {
+			t.Errorf("%s\nExpected: %d\nActual: %d", password, expected_count, count)
+		}
+	}
+
+	for _, password := range []string{"", "t8#Kd9$vQ2!mZr4&", "correct horse battery staple"} {
+		count, err := corpus.Count(password)
+
+		if err != nil {
+			t.Error(err.Error())
+		}
+
+		if count != 0 {
+			t.Errorf("%s should not be breached", password)
+		}
+	}
+}
+
+func TestCount_SortedFile(t *testing.T) {
+	lines := corpusLines(5000)
+
+	// HIBP files use CRLF line endings
+	for _, line_ending := range []string{"\r\n", "\n"} {
+		path := filepath.Join(t.TempDir(), "pwned-passwords-sha1-ordered-by-hash.txt")
+		os.WriteFile(path, []byte(strings.Join(lines, line_ending)+line_ending), 0644)
+
+		corpus, err := Open(path)
+
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		checkCorpus(t, corpus)
+
+		// Every line should be found, including first and last
+		for _, line := range lines {
+			hash, expected_count, _ := strings.Cut(line, ":")
+			count, err := corpus.CountHash(hash)
+
+			if err != nil || strconv.Itoa(count) != expected_count {
+				t.Errorf("%s\nExpected: %s\nActual: %d", hash, expected_count, count)
+			}
+		}
+
+		corpus.Close()
+	}
+}
+
+func TestCount_RangeDirectory(t *testing.T) {
+	directory := t.TempDir()
+	range_files := make(map[string][]string)
+
+	for _, line := range corpusLines(500) {
+		range_files[line[:PREFIX_LENGTH]] = append(range_files[line[:PREFIX_LENGTH]], line[PREFIX_LENGTH:])
+	}
+
+	for prefix, range_lines := range range_files {
+		os.WriteFile(filepath.Join(directory, prefix+".txt"), []byte(strings.Join(range_lines, "\r\n")), 0644)
+	}
+
+	corpus, err := Open(directory)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer corpus.Close()
+
+	checkCorpus(t, corpus)
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("Should fail for missing corpus")
+	}
+
+	path := filepath.Join(t.TempDir(), "invalid.txt")
+	os.WriteFile(path, []byte("not a corpus\n"), 0644)
+
+	corpus, err := Open(path)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer corpus.Close()
+
+	if _, err := corpus.Count("password"); err == nil {
+		t.Error("Should fail for invalid corpus")
+	}
+}
@@ -1,6 +1,9 @@
 package utils
 
-import "sync"
+import (
+	"errors"
+	"sync"
+)
 
 type Event struct {
 	Type string
@@ -24,19 +27,28 @@ func (b *Broadcast) Subscribe(event_type string, handler EventHandler) {
 	b.subcribers[event_type] = append(b.subcribers[event_type], handler)
 }
 
-func (b *Broadcast) Publish(event Event) {
+// Publish event to all subscribers and wait for them to complete. Returns errors from all failed handlers
+func (b *Broadcast) Publish(event Event) error {
 	b.mutex_lock.RLock()
 	defer b.mutex_lock.RUnlock()
 
+	var errs []error
 	if handlers, exists := b.subcribers[event.Type]; exists {
 		var wg sync.WaitGroup
+		var errs_lock sync.Mutex
 		for _, handler := range handlers {
 			wg.Add(1)
 			go func(h EventHandler, data map[string]string) {
 				defer wg.Done()
-				h(data)
+				if err := h(data); err != nil {
+					errs_lock.Lock()
+					errs = append(errs, err)
+					errs_lock.Unlock()
+				}
 			}(handler, event.Data)
 		}
 		wg.Wait()
 	}
+
+	return errors.Join(errs...)
 }
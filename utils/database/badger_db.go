@@ -2,26 +2,115 @@ package database
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/dgraph-io/badger/v4"
 )
 
+// Returned when data was modified after the version being updated was read
+var ErrConflict = errors.New("data has been modified, fetch latest data and retry")
+
+var (
+	connections_lock sync.Mutex
+	connections      = make(map[string]*badger.DB)
+	is_logging_quiet bool
+)
+
 type BadgerDb struct {
-	database_name string
+	database_name  string
+	storage_folder string // STORAGE_FOLDER is used if empty
 }
 
 func (obj *BadgerDb) SetDatabase(database_name string) {
-	obj.database_name = os.Getenv("STORAGE_FOLDER") + "/" + database_name
+	storage_folder := obj.storage_folder
+	if storage_folder == "" {
+		storage_folder = os.Getenv("STORAGE_FOLDER")
+	}
+
+	obj.database_name = storage_folder + "/" + database_name
+}
+
+func (obj *BadgerDb) GetDatabase() string {
+	return obj.database_name
+}
+
+// Get shared connection to the database. Connection is opened on first use and kept open until Close is called
+func (obj *BadgerDb) getConnection() (*badger.DB, error) {
+	connections_lock.Lock()
+	defer connections_lock.Unlock()
+
+	if db, exists := connections[obj.database_name]; exists {
+		return db, nil
+	}
+
+	options := badger.DefaultOptions(obj.database_name)
+	if is_logging_quiet {
+		options = options.WithLogger(nil)
+	}
+
+	db, err := badger.Open(options)
+	if err != nil {
+		return nil, err
+	}
+
+	connections[obj.database_name] = db
+	return db, nil
+}
+
+// Stop badger from logging to stderr for connections opened afterwards, used to keep command line output clean
+func QuietLogging() {
+	connections_lock.Lock()
+	defer connections_lock.Unlock()
+
+	is_logging_quiet = true
+}
+
+// Close all shared database connections. Connections are re-opened on next use
+func Close() error {
+	connections_lock.Lock()
+	defer connections_lock.Unlock()
+
+	var close_err error
+	for database_name, db := range connections {
+		if err := db.Close(); err != nil {
+			close_err = err
+		}
+		delete(connections, database_name)
+	}
+
+	return close_err
+}
+
+// Close shared connections to databases in storage_folder only
+func CloseStorageFolder(storage_folder string) error {
+	connections_lock.Lock()
+	defer connections_lock.Unlock()
+
+	var close_err error
+	for database_name, db := range connections {
+		if !strings.HasPrefix(database_name, storage_folder+"/") {
+			continue
+		}
+
+		if err := db.Close(); err != nil {
+			close_err = err
+		}
+		delete(connections, database_name)
+	}
+
+	return close_err
 }
 
 func (obj *BadgerDb) GetData(table_name string, params ...string) (interface{}, error) {
-	db, err := badger.Open(badger.DefaultOptions(obj.database_name))
+	db, err := obj.getConnection()
 
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
 	var fetched_data interface{}
 	err = db.View(func(txn *badger.Txn) error {
@@ -47,12 +136,11 @@ func (obj *BadgerDb) GetData(table_name string, params ...string) (interface{},
 	return fetched_data, nil
 }
 func (obj *BadgerDb) GetAllData(params ...string) ([]interface{}, error) {
-	db, err := badger.Open(badger.DefaultOptions(obj.database_name))
+	db, err := obj.getConnection()
 
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
 	var result_list []interface{}
 	err = db.View(func(txn *badger.Txn) error {
@@ -89,12 +177,11 @@ func (obj *BadgerDb) GetAllData(params ...string) ([]interface{}, error) {
 	return result_list, nil
 }
 func (obj *BadgerDb) AddData(table_name string, data interface{}) error {
-	db, err := badger.Open(badger.DefaultOptions(obj.database_name))
+	db, err := obj.getConnection()
 
 	if err != nil {
 		return err
 	}
-	defer db.Close()
 
 	data_bytes, err := json.Marshal(data)
 
@@ -114,16 +201,79 @@ func (obj *BadgerDb) AddData(table_name string, data interface{}) error {
 
 	return nil
 }
+
+/*
+Update existing data using read-modify-write.
+
+If a version is passed as the first param, update fails with ErrConflict when the stored data has a different version.
+*/
 func (obj *BadgerDb) UpdateData(table_name string, data interface{}, params ...string) error {
-	return nil
+	db, err := obj.getConnection()
+
+	if err != nil {
+		return err
+	}
+
+	data_bytes, err := json.Marshal(data)
+
+	if err != nil {
+		return err
+	}
+
+	err = db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(table_name))
+
+		if err != nil {
+			return err
+		}
+
+		if len(params) > 0 && params[0] != "" && params[0] != formatVersion(item.Version()) {
+			return ErrConflict
+		}
+
+		return txn.Set([]byte(table_name), data_bytes)
+	})
+
+	// Another write to the same key was committed while this update was in progress
+	if err == badger.ErrConflict {
+		return ErrConflict
+	}
+
+	return err
+}
+
+// Get version of stored data. Version changes on every write and can be used as an ETag
+func (obj *BadgerDb) GetVersion(table_name string) (string, error) {
+	db, err := obj.getConnection()
+
+	if err != nil {
+		return "", err
+	}
+
+	var version string
+	err = db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(table_name))
+
+		if err != nil {
+			return err
+		}
+
+		version = formatVersion(item.Version())
+		return nil
+	})
+
+	return version, err
+}
+
+func formatVersion(version uint64) string {
+	return strconv.FormatUint(version, 10)
 }
 func (obj *BadgerDb) DeleteData(table_name string, params ...string) error {
-	db, err := badger.Open(badger.DefaultOptions(obj.database_name))
+	db, err := obj.getConnection()
 
 	if err != nil {
 		return err
 	}
-	defer db.Close()
 
 	err = db.Update(func(txn *badger.Txn) error {
 		err := txn.Delete([]byte(table_name))
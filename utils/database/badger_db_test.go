@@ -0,0 +1,85 @@
+package database
+
+import (
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestUpdateData(t *testing.T) {
+	db := InitBadgerDb()
+	db.SetDatabase("UPDATE")
+
+	err := db.UpdateData("GITHUB", "new")
+
+	if err != badger.ErrKeyNotFound {
+		t.Errorf("Expected: %v\nActual: %v", badger.ErrKeyNotFound, err)
+	}
+
+	db.AddData("GITHUB", "old")
+
+	version, err := db.GetVersion("GITHUB")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	err = db.UpdateData("GITHUB", "new", version)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	//Update using a stale version
+	err = db.UpdateData("GITHUB", "newer", version)
+
+	if err != ErrConflict {
+		t.Errorf("Expected: %v\nActual: %v", ErrConflict, err)
+	}
+
+	fetched_data, err := db.GetData("GITHUB")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if fetched_data != "new" {
+		t.Errorf("Expected: %s\nActual: %v", "new", fetched_data)
+	}
+
+	t.Cleanup(badger_db_test_cleanup)
+}
+
+func TestInitBadgerDbAt(t *testing.T) {
+	storage_folder := t.TempDir()
+
+	db := InitBadgerDbAt(storage_folder)
+	db.SetDatabase("ISOLATED")
+
+	if err := db.AddData("GITHUB", "data"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, err := os.Stat(storage_folder + "/ISOLATED"); err != nil {
+		t.Error("Database should be created in given folder")
+	}
+
+	vault_db := InitBadgerDb()
+	vault_db.SetDatabase("ISOLATED")
+
+	if _, err := vault_db.GetData("GITHUB"); err != badger.ErrKeyNotFound {
+		t.Errorf("Expected: %v\nActual: %v", badger.ErrKeyNotFound, err)
+	}
+
+	if err := CloseStorageFolder(storage_folder); err != nil {
+		t.Error(err.Error())
+	}
+
+	t.Cleanup(badger_db_test_cleanup)
+}
+
+func badger_db_test_cleanup() {
+	Close()
+	os.RemoveAll(os.Getenv("STORAGE_FOLDER"))
+}
@@ -0,0 +1,264 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+const JOURNAL_FILE_NAME = "transaction.journal"
+
+// Only one transaction is committed at a time per storage folder, so a single journal file per folder is enough
+var commit_locks sync.Map
+
+func commitLock(storage_folder string) *sync.Mutex {
+	lock, _ := commit_locks.LoadOrStore(storage_folder, new(sync.Mutex))
+	return lock.(*sync.Mutex)
+}
+
+type transactionWrite struct {
+	DatabaseName string `json:"database_name"`
+	Key          string `json:"key"`
+	Value        []byte `json:"value"`
+	IsDelete     bool   `json:"is_delete"`
+}
+
+/*
+Transaction spanning multiple keys and databases.
+
+Writes are staged in memory until Commit. On commit the staged writes are saved to a journal file before being applied,
+if applying fails the previous values are restored. A journal left behind by a crash is replayed by Recover.
+*/
+type BadgerTransaction struct {
+	mutex_lock     sync.Mutex
+	storage_folder string
+	writes         []transactionWrite
+	is_done        bool
+}
+
+func (obj *BadgerTransaction) AddData(database IDatabase, table_name string, data interface{}) error {
+	data_bytes, err := json.Marshal(data)
+
+	if err != nil {
+		return err
+	}
+
+	return obj.stage(transactionWrite{DatabaseName: database.GetDatabase(), Key: table_name, Value: data_bytes})
+}
+
+func (obj *BadgerTransaction) DeleteData(database IDatabase, table_name string) error {
+	return obj.stage(transactionWrite{DatabaseName: database.GetDatabase(), Key: table_name, IsDelete: true})
+}
+
+func (obj *BadgerTransaction) stage(write transactionWrite) error {
+	obj.mutex_lock.Lock()
+	defer obj.mutex_lock.Unlock()
+
+	if obj.is_done {
+		return errors.New("transaction already completed")
+	}
+
+	obj.writes = append(obj.writes, write)
+	return nil
+}
+
+func (obj *BadgerTransaction) Rollback() {
+	obj.mutex_lock.Lock()
+	defer obj.mutex_lock.Unlock()
+
+	obj.writes = nil
+	obj.is_done = true
+}
+
+func (obj *BadgerTransaction) Commit() error {
+	obj.mutex_lock.Lock()
+	defer obj.mutex_lock.Unlock()
+
+	if obj.is_done {
+		return errors.New("transaction already completed")
+	}
+	obj.is_done = true
+
+	if len(obj.writes) == 0 {
+		return nil
+	}
+
+	storage_folder := obj.storage_folder
+	if storage_folder == "" {
+		storage_folder = os.Getenv("STORAGE_FOLDER")
+	}
+
+	commit_lock := commitLock(storage_folder)
+	commit_lock.Lock()
+	defer commit_lock.Unlock()
+
+	// Capture current values to restore them if applying fails
+	previous_writes, err := readPreviousValues(obj.writes)
+	if err != nil {
+		return err
+	}
+
+	if err := saveJournal(storage_folder, obj.writes); err != nil {
+		return err
+	}
+
+	if err := applyWrites(obj.writes); err != nil {
+		if rollback_err := applyWrites(previous_writes); rollback_err != nil {
+			// Leave journal in place so that Recover can complete the transaction
+			return errors.Join(err, rollback_err)
+		}
+		os.Remove(journalPath(storage_folder))
+		return err
+	}
+
+	return os.Remove(journalPath(storage_folder))
+}
+
+// Replay a transaction left behind in the journal by a crash during commit
+func Recover() error {
+	storage_folder := os.Getenv("STORAGE_FOLDER")
+
+	commit_lock := commitLock(storage_folder)
+	commit_lock.Lock()
+	defer commit_lock.Unlock()
+
+	data, err := os.ReadFile(journalPath(storage_folder))
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var writes []transactionWrite
+	if err := json.Unmarshal(data, &writes); err != nil {
+		return err
+	}
+
+	if err := applyWrites(writes); err != nil {
+		return err
+	}
+
+	return os.Remove(journalPath(storage_folder))
+}
+
+func journalPath(storage_folder string) string {
+	return storage_folder + "/" + JOURNAL_FILE_NAME
+}
+
+func saveJournal(storage_folder string, writes []transactionWrite) error {
+	data, err := json.Marshal(writes)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(storage_folder, os.ModePerm); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that a partially written journal is never replayed
+	temp_path := journalPath(storage_folder) + ".tmp"
+	file, err := os.OpenFile(temp_path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp_path, journalPath(storage_folder))
+}
+
+func readPreviousValues(writes []transactionWrite) ([]transactionWrite, error) {
+	var previous_writes []transactionWrite
+
+	for _, write := range writes {
+		db, err := (&BadgerDb{database_name: write.DatabaseName}).getConnection()
+		if err != nil {
+			return nil, err
+		}
+
+		previous_write := transactionWrite{DatabaseName: write.DatabaseName, Key: write.Key}
+
+		err = db.View(func(txn *badger.Txn) error {
+			item, err := txn.Get([]byte(write.Key))
+
+			if err == badger.ErrKeyNotFound {
+				previous_write.IsDelete = true
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			previous_write.Value, err = item.ValueCopy(nil)
+			return err
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		previous_writes = append(previous_writes, previous_write)
+	}
+
+	return previous_writes, nil
+}
+
+// Apply writes grouped by database. Writes are idempotent so they can be safely replayed
+func applyWrites(writes []transactionWrite) error {
+	var database_names []string
+	grouped_writes := make(map[string][]transactionWrite)
+
+	for _, write := range writes {
+		if _, exists := grouped_writes[write.DatabaseName]; !exists {
+			database_names = append(database_names, write.DatabaseName)
+		}
+		grouped_writes[write.DatabaseName] = append(grouped_writes[write.DatabaseName], write)
+	}
+
+	for _, database_name := range database_names {
+		db, err := (&BadgerDb{database_name: database_name}).getConnection()
+		if err != nil {
+			return err
+		}
+
+		batch := db.NewWriteBatch()
+
+		for _, write := range grouped_writes[database_name] {
+			if write.IsDelete {
+				err = batch.Delete([]byte(write.Key))
+			} else {
+				err = batch.Set([]byte(write.Key), write.Value)
+			}
+
+			if err != nil {
+				batch.Cancel()
+				return err
+			}
+		}
+
+		if err := batch.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package database
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTransactionCommit(t *testing.T) {
+	login_db := InitBadgerDb()
+	login_db.SetDatabase("TRANSACTION_LOGIN")
+
+	note_db := InitBadgerDb()
+	note_db.SetDatabase("TRANSACTION_NOTE")
+
+	login_db.AddData("GITHUB", "old")
+
+	transaction := BeginBadgerTransaction()
+	transaction.AddData(login_db, "GITHUB", "new")
+	transaction.AddData(note_db, "NOTE1", "note")
+
+	//Nothing should be written before commit
+	fetched_data, err := login_db.GetData("GITHUB")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if fetched_data != "old" {
+		t.Errorf("Expected: %s\nActual: %v", "old", fetched_data)
+	}
+
+	err = transaction.Commit()
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	fetched_data, err = login_db.GetData("GITHUB")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if fetched_data != "new" {
+		t.Errorf("Expected: %s\nActual: %v", "new", fetched_data)
+	}
+
+	fetched_data, err = note_db.GetData("NOTE1")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if fetched_data != "note" {
+		t.Errorf("Expected: %s\nActual: %v", "note", fetched_data)
+	}
+
+	if _, err := os.Stat(journalPath(os.Getenv("STORAGE_FOLDER"))); !os.IsNotExist(err) {
+		t.Error("Journal should be removed after commit")
+	}
+
+	t.Cleanup(transaction_test_cleanup)
+}
+
+func TestTransactionRollback(t *testing.T) {
+	login_db := InitBadgerDb()
+	login_db.SetDatabase("TRANSACTION_LOGIN")
+
+	transaction := BeginBadgerTransaction()
+	transaction.AddData(login_db, "GITHUB", "new")
+	transaction.Rollback()
+
+	_, err := login_db.GetData("GITHUB")
+
+	if err == nil {
+		t.Error("Rolled back data should not be written")
+	}
+
+	err = transaction.Commit()
+
+	if err == nil {
+		t.Error("Commit should fail after rollback")
+	}
+
+	t.Cleanup(transaction_test_cleanup)
+}
+
+func TestTransactionCommit_FailureHalfway(t *testing.T) {
+	login_db := InitBadgerDb()
+	login_db.SetDatabase("TRANSACTION_LOGIN")
+
+	note_db := InitBadgerDb()
+	note_db.SetDatabase("TRANSACTION_NOTE")
+
+	login_db.AddData("GITHUB", "old")
+
+	transaction := BeginBadgerTransaction()
+	transaction.AddData(login_db, "GITHUB", "new")
+	transaction.AddData(login_db, "GITLAB", "new")
+	//Oversized keys are rejected by badger, failing the commit after login database is written
+	transaction.AddData(note_db, strings.Repeat("A", 70000), "note")
+
+	err := transaction.Commit()
+
+	if err == nil {
+		t.Error("Commit should fail")
+	}
+
+	fetched_data, err := login_db.GetData("GITHUB")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if fetched_data != "old" {
+		t.Errorf("Expected: %s\nActual: %v", "old", fetched_data)
+	}
+
+	_, err = login_db.GetData("GITLAB")
+
+	if err == nil {
+		t.Error("New data should be removed on failure")
+	}
+
+	t.Cleanup(transaction_test_cleanup)
+}
+
+func TestRecover(t *testing.T) {
+	login_db := InitBadgerDb()
+	login_db.SetDatabase("TRANSACTION_LOGIN")
+
+	login_db.AddData("GITHUB", "old")
+
+	//Simulate a crash after the journal is saved
+	err := saveJournal(os.Getenv("STORAGE_FOLDER"), []transactionWrite{{DatabaseName: login_db.GetDatabase(), Key: "GITHUB", Value: []byte(`"new"`)}})
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	err = Recover()
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	fetched_data, err := login_db.GetData("GITHUB")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if fetched_data != "new" {
+		t.Errorf("Expected: %s\nActual: %v", "new", fetched_data)
+	}
+
+	if _, err := os.Stat(journalPath(os.Getenv("STORAGE_FOLDER"))); !os.IsNotExist(err) {
+		t.Error("Journal should be removed after recovery")
+	}
+
+	t.Cleanup(transaction_test_cleanup)
+}
+
+func transaction_test_cleanup() {
+	Close()
+	os.RemoveAll(os.Getenv("STORAGE_FOLDER"))
+}
@@ -2,13 +2,36 @@ package database
 
 type IDatabase interface {
 	SetDatabase(database_name string)
+	GetDatabase() string
 	GetData(table_name string, params ...string) (interface{}, error)
 	GetAllData(params ...string) ([]interface{}, error)
 	AddData(table_name string, data interface{}) error
 	UpdateData(table_name string, data interface{}, params ...string) error
+	GetVersion(table_name string) (string, error)
 	DeleteData(table_name string, params ...string) error
 }
 
+type ITransaction interface {
+	AddData(database IDatabase, table_name string, data interface{}) error
+	DeleteData(database IDatabase, table_name string) error
+	Commit() error
+	Rollback()
+}
+
 func InitBadgerDb() IDatabase {
 	return &BadgerDb{}
 }
+
+// Database kept in the given folder instead of STORAGE_FOLDER, used for stores isolated from the vault
+func InitBadgerDbAt(storage_folder string) IDatabase {
+	return &BadgerDb{storage_folder: storage_folder}
+}
+
+func BeginBadgerTransaction() ITransaction {
+	return &BadgerTransaction{}
+}
+
+// Transaction journaled in the given folder instead of STORAGE_FOLDER, for databases created with InitBadgerDbAt
+func BeginBadgerTransactionAt(storage_folder string) ITransaction {
+	return &BadgerTransaction{storage_folder: storage_folder}
+}
@@ -1,16 +1,29 @@
 package encryptor
 
 import (
-	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"io"
+	"strings"
 )
 
+const (
+	LEGACY_VERSION  byte = 1 // Un-prefixed AES-CBC ciphertext keyed by the unsalted SHA-256 master password hash
+	CBC_VERSION     byte = 2 // AES-CBC ciphertext keyed by the Argon2id derived master key
+	GCM_VERSION     byte = 3 // AES-GCM ciphertext keyed by the Argon2id derived master key
+	CURRENT_VERSION      = GCM_VERSION
+
+	VERSION_PREFIX = "$" // Never part of a hex string, used to tell versioned ciphertexts apart from legacy ones
+)
+
+// Returned when a ciphertext fails authentication - it was modified, moved to another entry or the key is incorrect
+var ErrTampered = errors.New("ciphertext has been tampered with")
+
 // Create a hash using SHA-256
 func CreateHash(data_to_hash string) string {
 	hasher := sha256.New()
@@ -18,73 +31,138 @@ func CreateHash(data_to_hash string) string {
 	return hex.EncodeToString(hasher.Sum(nil))
 }
 
-// Encrpyt plain text using SHA-256 hash as key
-func Encrypt(plaintext string, keyStr string) (string, error) {
+/*
+Encrpyt plain text with AES-GCM using SHA-256 hash as key.
+
+Associated data (e.g. login name and username) is authenticated but not encrypted,
+the same associated data has to be passed to Decrypt.
+*/
+func Encrypt(plaintext string, keyStr string, associated_data ...string) (string, error) {
 	key, err := hex.DecodeString(CreateHash(keyStr))
 
 	if err != nil {
 		return "", err
 	}
 
-	// Generate a random IV
-	iv := make([]byte, aes.BlockSize)
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+	block, err := aes.NewCipher(key)
+	if err != nil {
 		return "", err
 	}
 
-	block, err := aes.NewCipher(key)
+	gcm, err := cipher.NewGCM(block)
 	if err != nil {
 		return "", err
 	}
 
-	// Pad plaintext to be multiple of block size
-	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
-	padtext := bytes.Repeat([]byte{byte(padding)}, padding)
-	paddedPlaintext := append([]byte(plaintext), padtext...)
+	// Generate a random nonce
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	// Combine version, nonce and sealed ciphertext for output
+	combined := append([]byte{GCM_VERSION}, nonce...)
+	combined = gcm.Seal(combined, nonce, []byte(plaintext), encodeAssociatedData(GCM_VERSION, associated_data))
 
-	// Encrypt the plaintext
-	mode := cipher.NewCBCEncrypter(block, iv)
-	ciphertext := make([]byte, len(paddedPlaintext))
-	mode.CryptBlocks(ciphertext, paddedPlaintext)
+	return VERSION_PREFIX + hex.EncodeToString(combined), nil
+}
+
+// Get version of the given ciphertext
+func Version(ciphertextHex string) byte {
+	if !strings.HasPrefix(ciphertextHex, VERSION_PREFIX) || len(ciphertextHex) < len(VERSION_PREFIX)+2 {
+		return LEGACY_VERSION
+	}
 
-	// Combine IV and ciphertext for output
-	combined := append(iv, ciphertext...)
-	return hex.EncodeToString(combined), nil
+	version, err := hex.DecodeString(ciphertextHex[len(VERSION_PREFIX) : len(VERSION_PREFIX)+2])
+	if err != nil {
+		return LEGACY_VERSION
+	}
+
+	return version[0]
 }
 
-// Decrpyt encrypted text using hsa-256 hash
-func Decrypt(ciphertextHex string, keyStr string) (string, error) {
+// Decrpyt encrypted text using hsa-256 hash. Older AES-CBC ciphertexts carry no associated data and are decrypted without it
+func Decrypt(ciphertextHex string, keyStr string, associated_data ...string) (string, error) {
 	key, err := hex.DecodeString(CreateHash(keyStr))
 
 	if err != nil {
 		return "", err
 	}
 
-	// Decode the combined IV and ciphertext from hex
-	combined, err := hex.DecodeString(ciphertextHex)
+	// Decode the combined version, IV and ciphertext from hex
+	combined, err := hex.DecodeString(strings.TrimPrefix(ciphertextHex, VERSION_PREFIX))
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return "", err
 	}
 
-	if len(combined) < aes.BlockSize {
+	version := Version(ciphertextHex)
+	switch version {
+	case LEGACY_VERSION:
+		return decryptCBC(block, combined)
+	case CBC_VERSION:
+		return decryptCBC(block, combined[1:])
+	case GCM_VERSION:
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return "", err
+		}
+
+		combined = combined[1:]
+		if len(combined) < gcm.NonceSize()+gcm.Overhead() {
+			return "", errors.New("ciphertext too short")
+		}
+
+		nonce := combined[:gcm.NonceSize()]
+		plaintext, err := gcm.Open(nil, nonce, combined[gcm.NonceSize():], encodeAssociatedData(version, associated_data))
+		if err != nil {
+			return "", ErrTampered
+		}
+
+		return string(plaintext), nil
+	default:
+		return "", errors.New("unsupported ciphertext version")
+	}
+}
+
+func decryptCBC(block cipher.Block, combined []byte) (string, error) {
+	if len(combined) < 2*aes.BlockSize || len(combined)%aes.BlockSize != 0 {
 		return "", errors.New("ciphertext too short")
 	}
 
 	iv := combined[:aes.BlockSize]
 	ciphertext := combined[aes.BlockSize:]
 
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return "", err
-	}
-
 	// Decrypt the ciphertext
 	mode := cipher.NewCBCDecrypter(block, iv)
 	paddedPlaintext := make([]byte, len(ciphertext))
 	mode.CryptBlocks(paddedPlaintext, ciphertext)
 
-	// Remove padding
+	// Validate and remove padding
 	padding := int(paddedPlaintext[len(paddedPlaintext)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return "", ErrTampered
+	}
+	for _, value := range paddedPlaintext[len(paddedPlaintext)-padding:] {
+		if int(value) != padding {
+			return "", ErrTampered
+		}
+	}
+
 	plaintext := paddedPlaintext[:len(paddedPlaintext)-padding]
 	return string(plaintext), nil
 }
+
+// Length prefix every value so that ("ab", "c") and ("a", "bc") do not authenticate the same
+func encodeAssociatedData(version byte, associated_data []string) []byte {
+	encoded := []byte{version}
+	for _, data := range associated_data {
+		encoded = binary.BigEndian.AppendUint32(encoded, uint32(len(data)))
+		encoded = append(encoded, data...)
+	}
+	return encoded
+}
@@ -26,3 +26,127 @@ func TestDecrypt(t *testing.T) {
 		t.Errorf("Decryption failed")
 	}
 }
+
+func TestDecrypt_Legacy(t *testing.T) {
+	//Encrypted using unversioned AES-CBC with key - CreateHash("12345")+"github"+"abc"
+	legacy_encrypted_text := "2dc977633c2caaff637bf7d46a07c2ad1528a861f957272bbc49208ac8de61a5"
+
+	if Version(legacy_encrypted_text) != LEGACY_VERSION {
+		t.Errorf("Expected: %d\nActual: %d", LEGACY_VERSION, Version(legacy_encrypted_text))
+	}
+
+	decrypted_text, err := Decrypt(legacy_encrypted_text, CreateHash("12345")+"github"+"abc")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if decrypted_text != "123" {
+		t.Errorf("Decryption failed")
+	}
+}
+
+func TestVersion(t *testing.T) {
+	encrypted_text, _ := Encrypt("this is a secret", "some text")
+
+	if Version(encrypted_text) != CURRENT_VERSION {
+		t.Errorf("Expected: %d\nActual: %d", CURRENT_VERSION, Version(encrypted_text))
+	}
+}
+
+func TestKDFRecord(t *testing.T) {
+	params, err := NewKDFParams()
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	key, verifier := DeriveKey("12345", params)
+
+	record := EncodeKDFRecord(params, verifier)
+
+	parsed_params, parsed_verifier, err := ParseKDFRecord(record)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	parsed_key, _ := DeriveKey("12345", parsed_params)
+
+	if string(parsed_key) != string(key) || string(parsed_verifier) != string(verifier) {
+		t.Error("Mismatch in derived key")
+	}
+
+	if IsLegacyHash(record) || !IsLegacyHash(CreateHash("12345")) {
+		t.Error("Incorrect legacy hash detection")
+	}
+}
+
+func TestDecrypt_CBC(t *testing.T) {
+	//Encrypted using AES-CBC with key - "some text"
+	cbc_encrypted_text := "$02a7592b9e72d1256410763cd099459c2424a7175e9224f1447aea1e2d1bf32d2add101d94a182cb84dbbed5309da04bfe"
+
+	decrypted_text, err := Decrypt(cbc_encrypted_text, "some text")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if decrypted_text != "this is a secret" {
+		t.Errorf("Decryption failed")
+	}
+}
+
+func TestDecrypt_IncorrectKey(t *testing.T) {
+	encrypted_text, _ := Encrypt("this is a secret", "some text")
+
+	_, err := Decrypt(encrypted_text, "other text")
+
+	if err != ErrTampered {
+		t.Errorf("Expected: %v\nActual: %v", ErrTampered, err)
+	}
+
+	//Should not panic on invalid padding
+	_, err = Decrypt("2dc977633c2caaff637bf7d46a07c2ad1528a861f957272bbc49208ac8de61a5", "other text")
+
+	if err == nil {
+		t.Error("Should result in an error")
+	}
+}
+
+func TestDecrypt_Tampered(t *testing.T) {
+	encrypted_text, _ := Encrypt("this is a secret", "some text", "github", "abc")
+
+	decrypted_text, err := Decrypt(encrypted_text, "some text", "github", "abc")
+
+	if err != nil || decrypted_text != "this is a secret" {
+		t.Errorf("Decryption failed")
+	}
+
+	//Associated data of another entry
+	_, err = Decrypt(encrypted_text, "some text", "github", "pqr")
+
+	if err != ErrTampered {
+		t.Errorf("Expected: %v\nActual: %v", ErrTampered, err)
+	}
+
+	_, err = Decrypt(encrypted_text, "some text", "githu", "babc")
+
+	if err != ErrTampered {
+		t.Errorf("Expected: %v\nActual: %v", ErrTampered, err)
+	}
+
+	//Modified ciphertext
+	modified_text := []byte(encrypted_text)
+	if modified_text[len(modified_text)-1] == '0' {
+		modified_text[len(modified_text)-1] = '1'
+	} else {
+		modified_text[len(modified_text)-1] = '0'
+	}
+
+	_, err = Decrypt(string(modified_text), "some text", "github", "abc")
+
+	if err != ErrTampered {
+		t.Errorf("Expected: %v\nActual: %v", ErrTampered, err)
+	}
+}
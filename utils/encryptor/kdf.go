@@ -0,0 +1,116 @@
+package encryptor
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	KEY_LENGTH  = 32 // Length of the derived encryption key in bytes
+	SALT_LENGTH = 16
+
+	DEFAULT_KDF_MEMORY      = 64 * 1024 // 64 MiB
+	DEFAULT_KDF_ITERATIONS  = 3
+	DEFAULT_KDF_PARALLELISM = 4
+)
+
+var legacy_hash_pattern = regexp.MustCompile("^[0-9a-f]{64}$")
+
+// Cost parameters and salt used to derive keys from the master password using Argon2id
+type KDFParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	Salt        []byte
+}
+
+// Create KDF params with a random salt. Cost parameters can be tuned using KDF_MEMORY, KDF_ITERATIONS and KDF_PARALLELISM env variables
+func NewKDFParams() (KDFParams, error) {
+	params := KDFParams{
+		Memory:      uint32(getEnvInt("KDF_MEMORY", DEFAULT_KDF_MEMORY)),
+		Iterations:  uint32(getEnvInt("KDF_ITERATIONS", DEFAULT_KDF_ITERATIONS)),
+		Parallelism: uint8(getEnvInt("KDF_PARALLELISM", DEFAULT_KDF_PARALLELISM)),
+		Salt:        make([]byte, SALT_LENGTH),
+	}
+
+	if _, err := io.ReadFull(rand.Reader, params.Salt); err != nil {
+		return KDFParams{}, err
+	}
+
+	return params, nil
+}
+
+func getEnvInt(name string, default_value int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return default_value
+	}
+	return value
+}
+
+/*
+Derive key material from password.
+
+First KEY_LENGTH bytes are used as the encryption key and the remaining KEY_LENGTH bytes as the verifier stored in the database,
+so the stored value never reveals the encryption key.
+*/
+func DeriveKey(password string, params KDFParams) (key []byte, verifier []byte) {
+	derived := argon2.IDKey([]byte(password), params.Salt, params.Iterations, params.Memory, params.Parallelism, 2*KEY_LENGTH)
+	return derived[:KEY_LENGTH], derived[KEY_LENGTH:]
+}
+
+// Encode params and verifier in PHC string format - $argon2id$v=19$m=65536,t=3,p=4$<salt>$<verifier>
+func EncodeKDFRecord(params KDFParams, verifier []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(params.Salt),
+		base64.RawStdEncoding.EncodeToString(verifier))
+}
+
+// Parse PHC string created by EncodeKDFRecord
+func ParseKDFRecord(record string) (KDFParams, []byte, error) {
+	parts := strings.Split(record, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return KDFParams{}, nil, errors.New("invalid kdf record")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return KDFParams{}, nil, err
+	}
+	if version != argon2.Version {
+		return KDFParams{}, nil, errors.New("unsupported argon2 version")
+	}
+
+	var params KDFParams
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return KDFParams{}, nil, err
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return KDFParams{}, nil, err
+	}
+	params.Salt = salt
+
+	verifier, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return KDFParams{}, nil, err
+	}
+
+	return params, verifier, nil
+}
+
+// Check if stored master password is an unsalted SHA-256 hash created before Argon2id was introduced
+func IsLegacyHash(record string) bool {
+	return legacy_hash_pattern.MatchString(record)
+}
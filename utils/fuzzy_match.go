@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"ncrypt/models"
+	"unicode"
+)
+
+const (
+	EXACT_MATCH_SCORE       = 1.0
+	PREFIX_MATCH_SCORE      = 0.9
+	WORD_MATCH_SCORE        = 0.8
+	SUBSTRING_MATCH_SCORE   = 0.7
+	TYPO_MATCH_SCORE        = 0.6 // Reduced by TYPO_PENALTY per edit
+	TYPO_PENALTY            = 0.1
+	SUBSEQUENCE_MATCH_SCORE = 0.4 // Scaled by how close together the matched characters are
+
+	MIN_TYPO_TERM_LENGTH     = 4 // Shorter terms have to match without typos
+	MIN_TWO_TYPO_TERM_LENGTH = 8
+)
+
+/*
+Match a search term against text, ignoring case.
+
+Returns a score between 0 (no match) and 1 (text equals term) along with the matched ranges. In order of preference, text can be
+equal to, start with, contain at the start of a word or contain the term, have a word within one edit of it (two for terms of
+MIN_TWO_TYPO_TERM_LENGTH or more) or, if is_subsequence_allowed, contain its characters in order.
+*/
+func FuzzyMatch(text string, term string, is_subsequence_allowed bool) (float64, []models.TextRange) {
+	text_runes := lowerRunes(text)
+	term_runes := lowerRunes(term)
+
+	if len(term_runes) == 0 || len(text_runes) == 0 {
+		return 0, nil
+	}
+
+	// Substring matches, preferring one at the start of a word
+	first_index := -1
+	for index := 0; index+len(term_runes) <= len(text_runes); index++ {
+		if !hasRunesAt(text_runes, term_runes, index) {
+			continue
+		}
+
+		match_range := []models.TextRange{{Start: index, End: index + len(term_runes)}}
+
+		if index == 0 && len(term_runes) == len(text_runes) {
+			return EXACT_MATCH_SCORE, match_range
+		}
+		if index == 0 {
+			return PREFIX_MATCH_SCORE, match_range
+		}
+		if !isWordRune(text_runes[index-1]) {
+			return WORD_MATCH_SCORE, match_range
+		}
+		if first_index == -1 {
+			first_index = index
+		}
+	}
+
+	if first_index != -1 {
+		return SUBSTRING_MATCH_SCORE, []models.TextRange{{Start: first_index, End: first_index + len(term_runes)}}
+	}
+
+	if score, match_range := typoMatch(text_runes, term_runes); score > 0 {
+		return score, match_range
+	}
+
+	if is_subsequence_allowed {
+		return subsequenceMatch(text_runes, term_runes)
+	}
+
+	return 0, nil
+}
+
+// Convert to lower case rune by rune, so indexes stay the same as in the original text
+func lowerRunes(text string) []rune {
+	runes := []rune(text)
+	for index := range runes {
+		runes[index] = unicode.ToLower(runes[index])
+	}
+	return runes
+}
+
+func hasRunesAt(text []rune, term []rune, index int) bool {
+	for offset := range term {
+		if text[index+offset] != term[offset] {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordRune(character rune) bool {
+	return unicode.IsLetter(character) || unicode.IsDigit(character)
+}
+
+// Find the word closest to term, also comparing term against the start of longer words as it may be partially typed
+func typoMatch(text []rune, term []rune) (float64, []models.TextRange) {
+	if len(term) < MIN_TYPO_TERM_LENGTH {
+		return 0, nil
+	}
+
+	max_edits := 1
+	if len(term) >= MIN_TWO_TYPO_TERM_LENGTH {
+		max_edits = 2
+	}
+
+	best_edits := max_edits + 1
+	var best_range models.TextRange
+
+	for start := 0; start < len(text); {
+		if !isWordRune(text[start]) {
+			start++
+			continue
+		}
+
+		end := start
+		for end < len(text) && isWordRune(text[end]) {
+			end++
+		}
+
+		if edits := editDistance(text[start:end], term); edits < best_edits {
+			best_edits, best_range = edits, models.TextRange{Start: start, End: end}
+		}
+
+		if end-start > len(term) {
+			if edits := editDistance(text[start:start+len(term)], term); edits < best_edits {
+				best_edits, best_range = edits, models.TextRange{Start: start, End: start + len(term)}
+			}
+		}
+
+		start = end
+	}
+
+	if best_edits > max_edits {
+		return 0, nil
+	}
+
+	return TYPO_MATCH_SCORE - TYPO_PENALTY*float64(best_edits), []models.TextRange{best_range}
+}
+
+// Levenshtein distance
+func editDistance(first []rune, second []rune) int {
+	previous := make([]int, len(second)+1)
+	current := make([]int, len(second)+1)
+
+	for index := range previous {
+		previous[index] = index
+	}
+
+	for i := 1; i <= len(first); i++ {
+		current[0] = i
+		for j := 1; j <= len(second); j++ {
+			cost := 1
+			if first[i-1] == second[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(second)]
+}
+
+func subsequenceMatch(text []rune, term []rune) (float64, []models.TextRange) {
+	var ranges []models.TextRange
+	term_index := 0
+
+	for index := 0; index < len(text) && term_index < len(term); index++ {
+		if text[index] != term[term_index] {
+			continue
+		}
+
+		// Consecutive matched characters are highlighted as one range
+		if len(ranges) > 0 && ranges[len(ranges)-1].End == index {
+			ranges[len(ranges)-1].End++
+		} else {
+			ranges = append(ranges, models.TextRange{Start: index, End: index + 1})
+		}
+		term_index++
+	}
+
+	if term_index < len(term) {
+		return 0, nil
+	}
+
+	span := ranges[len(ranges)-1].End - ranges[0].Start
+	return SUBSEQUENCE_MATCH_SCORE * float64(len(term)) / float64(span), ranges
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"math"
+	"ncrypt/models"
+	"slices"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	test_cases := []struct {
+		text            string
+		term            string
+		expected_score  float64
+		expected_ranges []models.TextRange
+	}{
+		{"GitHub", "github", EXACT_MATCH_SCORE, []models.TextRange{{Start: 0, End: 6}}},
+		{"GitHub", "git", PREFIX_MATCH_SCORE, []models.TextRange{{Start: 0, End: 3}}},
+		{"https://github.com", "github", WORD_MATCH_SCORE, []models.TextRange{{Start: 8, End: 14}}},
+		{"mygithub", "hub", SUBSTRING_MATCH_SCORE, []models.TextRange{{Start: 5, End: 8}}},
+		// Word start is preferred over an earlier match inside a word
+		{"subhub hub", "hub", WORD_MATCH_SCORE, []models.TextRange{{Start: 7, End: 10}}},
+		{"my gitbub account", "github", TYPO_MATCH_SCORE - TYPO_PENALTY, []models.TextRange{{Start: 3, End: 9}}},
+		// Partially typed word with a typo
+		{"Amazon", "amaz0", TYPO_MATCH_SCORE - TYPO_PENALTY, []models.TextRange{{Start: 0, End: 5}}},
+		{"stackoverflow", "stakcoverflow", TYPO_MATCH_SCORE - 2*TYPO_PENALTY, []models.TextRange{{Start: 0, End: 13}}},
+		{"Google Mail", "gml", SUBSEQUENCE_MATCH_SCORE * 3 / 11, []models.TextRange{{Start: 0, End: 1}, {Start: 7, End: 8}, {Start: 10, End: 11}}},
+		{"GitHub", "gitlab", 0, nil},
+		{"abc", "", 0, nil},
+		{"", "abc", 0, nil},
+	}
+
+	for _, test_case := range test_cases {
+		score, ranges := FuzzyMatch(test_case.text, test_case.term, true)
+
+		if math.Abs(score-test_case.expected_score) > 1e-9 || !slices.Equal(ranges, test_case.expected_ranges) {
+			t.Errorf("%s / %s\nExpected: %f %v\nActual: %f %v", test_case.text, test_case.term, test_case.expected_score, test_case.expected_ranges, score, ranges)
+		}
+	}
+}
+
+func TestFuzzyMatch_WithoutSubsequence(t *testing.T) {
+	if score, _ := FuzzyMatch("Google Mail", "gml", false); score != 0 {
+		t.Errorf("Expected no match, got %f", score)
+	}
+}
+
+// Short terms match only without typos, so they do not match almost everything
+func TestFuzzyMatch_ShortTermTypo(t *testing.T) {
+	if score, _ := FuzzyMatch("bank", "bonk", false); score == 0 {
+		t.Error("Expected typo match for 4 character term")
+	}
+
+	if score, _ := FuzzyMatch("bank", "bnk", false); score != 0 {
+		t.Errorf("Expected no typo match for 3 character term, got %f", score)
+	}
+}
@@ -0,0 +1,75 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"ncrypt/models"
+)
+
+const (
+	BITWARDEN_LOGIN       = 1
+	BITWARDEN_SECURE_NOTE = 2
+)
+
+// Unencrypted JSON export of Bitwarden
+type bitwardenParser struct{}
+
+type bitwardenExport struct {
+	Encrypted bool            `json:"encrypted"`
+	Items     []bitwardenItem `json:"items"`
+}
+
+type bitwardenItem struct {
+	Type     int    `json:"type"`
+	Name     string `json:"name"`
+	Notes    string `json:"notes"`
+	Favorite bool   `json:"favorite"`
+	Login    *struct {
+		URIs []struct {
+			URI string `json:"uri"`
+		} `json:"uris"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+		TOTP     string `json:"totp"`
+	} `json:"login"`
+}
+
+func (obj *bitwardenParser) Parse(data []byte) ([]Record, []models.ImportIssue, error) {
+	var export bitwardenExport
+
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, nil, err
+	}
+
+	if export.Encrypted {
+		return nil, nil, errors.New("encrypted bitwarden exports are not supported, export as unencrypted JSON")
+	}
+
+	var records []Record
+	var skipped []models.ImportIssue
+
+	for index, item := range export.Items {
+		record := Record{Row: index + 1, Name: item.Name, Notes: item.Notes, IsFavourite: item.Favorite}
+
+		switch {
+		case item.Type == BITWARDEN_LOGIN && item.Login != nil:
+			record.Username = item.Login.Username
+			record.Password = item.Login.Password
+			record.TOTPSecret = item.Login.TOTP
+
+			if len(item.Login.URIs) > 0 {
+				record.URL = item.Login.URIs[0].URI
+			}
+		case item.Type == BITWARDEN_SECURE_NOTE:
+			record.IsNote = true
+		default:
+			// Cards and identities have no equivalent
+			skipped = append(skipped, models.ImportIssue{Row: record.Row, Name: item.Name, Reason: "unsupported item type"})
+			continue
+		}
+
+		records = append(records, record)
+	}
+
+	return records, skipped, nil
+}
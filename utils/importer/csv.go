@@ -0,0 +1,94 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"ncrypt/models"
+	"strconv"
+	"strings"
+)
+
+// CSV export with a header row. Columns are matched by name (case insensitive), empty column names are not read
+type csvParser struct {
+	name_column      string
+	url_column       string
+	username_column  string
+	password_column  string
+	totp_column      string
+	notes_column     string
+	favourite_column string
+	archived_column  string
+}
+
+func (obj *csvParser) Parse(data []byte) ([]Record, []models.ImportIssue, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+
+	rows, err := reader.ReadAll()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, nil, errors.New("missing header row")
+	}
+
+	columns := make(map[string]int)
+	for index, column := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(column))] = index
+	}
+
+	for _, required_column := range []string{obj.url_column, obj.username_column, obj.password_column} {
+		if _, exists := columns[required_column]; !exists {
+			return nil, nil, errors.New("missing column " + required_column)
+		}
+	}
+
+	var records []Record
+	var skipped []models.ImportIssue
+
+	for index, row := range rows[1:] {
+		if len(row) != len(rows[0]) {
+			skipped = append(skipped, models.ImportIssue{Row: index + 1, Reason: "expected " + strconv.Itoa(len(rows[0])) + " columns, found " + strconv.Itoa(len(row))})
+			continue
+		}
+
+		value := func(column string) string {
+			if column_index, exists := columns[column]; column != "" && exists {
+				return row[column_index]
+			}
+			return ""
+		}
+
+		record := Record{
+			Row:         index + 1,
+			Name:        value(obj.name_column),
+			URL:         value(obj.url_column),
+			Username:    value(obj.username_column),
+			Password:    value(obj.password_column),
+			TOTPSecret:  value(obj.totp_column),
+			Notes:       value(obj.notes_column),
+			IsFavourite: isTrue(value(obj.favourite_column)),
+		}
+
+		if isTrue(value(obj.archived_column)) {
+			skipped = append(skipped, models.ImportIssue{Row: record.Row, Name: record.Name, Username: record.Username, Reason: "archived item"})
+			continue
+		}
+
+		records = append(records, record)
+	}
+
+	return records, skipped, nil
+}
+
+func isTrue(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "1", "yes":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,19 @@
+package importer
+
+import "ncrypt/models"
+
+// Parser of an export file of another password manager
+type IParser interface {
+	// Read login and note records from export. Entries that cannot be read are returned as issues instead of failing the whole import
+	Parse(data []byte) ([]Record, []models.ImportIssue, error)
+}
+
+func GetParser(format string) (IParser, error) {
+	parser, exists := parsers[format]
+
+	if !exists {
+		return nil, ErrUnsupportedFormat
+	}
+
+	return parser, nil
+}
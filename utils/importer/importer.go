@@ -0,0 +1,169 @@
+package importer
+
+import (
+	"errors"
+	"ncrypt/models"
+	"ncrypt/utils/totp"
+	"net/url"
+	"strings"
+)
+
+const (
+	BITWARDEN_JSON   = "BITWARDEN_JSON"
+	ONEPASSWORD_1PUX = "ONEPASSWORD_1PUX"
+	ONEPASSWORD_CSV  = "ONEPASSWORD_CSV"
+	KEEPASS_XML      = "KEEPASS_XML"
+	CHROME_CSV       = "CHROME_CSV"
+	FIREFOX_CSV      = "FIREFOX_CSV"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported import format")
+
+var parsers = map[string]IParser{
+	BITWARDEN_JSON:   &bitwardenParser{},
+	ONEPASSWORD_1PUX: &onePasswordParser{},
+	ONEPASSWORD_CSV:  &csvParser{name_column: "title", url_column: "url", username_column: "username", password_column: "password", totp_column: "otpauth", notes_column: "notes", favourite_column: "favorite", archived_column: "archived"},
+	KEEPASS_XML:      &keePassParser{},
+	CHROME_CSV:       &csvParser{name_column: "name", url_column: "url", username_column: "username", password_column: "password", notes_column: "note"},
+	FIREFOX_CSV:      &csvParser{url_column: "url", username_column: "username", password_column: "password"},
+}
+
+// Login or note read from an export, before logins are grouped by site
+type Record struct {
+	Row         int // Position in the export, used when reporting issues
+	Name        string
+	URL         string
+	Username    string
+	Password    string
+	TOTPSecret  string
+	Notes       string
+	IsFavourite bool
+	IsNote      bool
+}
+
+// Logins and notes ready to be added. Passwords are in plain text
+type Result struct {
+	Logins    []models.Login
+	Notes     []models.Note
+	Conflicts []models.ImportIssue
+	Skipped   []models.ImportIssue
+}
+
+/*
+Group records into logins by site name, accounts of the same site are merged into one login.
+
+Site name is the record name, or host of the URL if the record has no name. An account that appears more than once is
+imported once, if the passwords differ the first one is kept and the rest are reported as conflicts. Invalid TOTP secrets are
+dropped, the account is still imported.
+*/
+func Build(records []Record, skipped []models.ImportIssue) Result {
+	result := Result{Skipped: skipped}
+	login_indexes := make(map[string]int)
+
+	for _, record := range records {
+		if record.IsNote {
+			if strings.TrimSpace(record.Notes) == "" {
+				result.Skipped = append(result.Skipped, models.ImportIssue{Row: record.Row, Name: record.Name, Reason: "empty note"})
+				continue
+			}
+
+			result.Notes = append(result.Notes, models.Note{Title: record.Name, Content: record.Notes, Attributes: models.Attributes{IsFavourite: record.IsFavourite}})
+			continue
+		}
+
+		name := siteName(record)
+
+		if name == "" {
+			result.Skipped = append(result.Skipped, models.ImportIssue{Row: record.Row, Username: record.Username, Reason: "missing name and url"})
+			continue
+		}
+
+		if record.Username == "" && record.Password == "" {
+			result.Skipped = append(result.Skipped, models.ImportIssue{Row: record.Row, Name: name, Reason: "missing username and password"})
+			continue
+		}
+
+		account := models.Account{Username: record.Username, Password: record.Password}
+
+		if record.TOTPSecret != "" {
+			if isValidTOTPSecret(record.TOTPSecret) {
+				account.TOTPSecret = &models.TOTPSecret{Secret: record.TOTPSecret}
+			} else {
+				result.Skipped = append(result.Skipped, models.ImportIssue{Row: record.Row, Name: name, Username: record.Username, Reason: "invalid TOTP secret, account imported without it"})
+			}
+		}
+
+		// Logins are stored by upper case name
+		key := strings.ToUpper(name)
+		index, exists := login_indexes[key]
+
+		if !exists {
+			login_indexes[key] = len(result.Logins)
+			result.Logins = append(result.Logins, models.Login{Name: name, URL: record.URL, Attributes: models.Attributes{IsFavourite: record.IsFavourite}})
+			index = len(result.Logins) - 1
+		}
+
+		login := &result.Logins[index]
+
+		if login.URL == "" {
+			login.URL = record.URL
+		}
+		login.Attributes.IsFavourite = login.Attributes.IsFavourite || record.IsFavourite
+
+		if existing_index := findAccount(login.Accounts, account.Username); existing_index != -1 {
+			if login.Accounts[existing_index].Password == account.Password {
+				result.Skipped = append(result.Skipped, models.ImportIssue{Row: record.Row, Name: name, Username: record.Username, Reason: "duplicate entry"})
+			} else {
+				result.Conflicts = append(result.Conflicts, models.ImportIssue{Row: record.Row, Name: name, Username: record.Username, Reason: "username appears more than once with different passwords, first one is kept"})
+			}
+			continue
+		}
+
+		login.Accounts = append(login.Accounts, account)
+	}
+
+	return result
+}
+
+func siteName(record Record) string {
+	if name := strings.TrimSpace(record.Name); name != "" {
+		return name
+	}
+
+	value := strings.TrimSpace(record.URL)
+	if value == "" {
+		return ""
+	}
+
+	if !strings.Contains(value, "://") {
+		value = "https://" + value
+	}
+
+	parsed_url, err := url.Parse(value)
+
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimPrefix(parsed_url.Hostname(), "www.")
+}
+
+func isValidTOTPSecret(secret string) bool {
+	if totp.IsURI(secret) {
+		_, err := totp.ParseURI(secret)
+		return err == nil
+	}
+
+	key := totp.Key{Secret: secret}
+	return key.Normalize() == nil
+}
+
+func findAccount(accounts []models.Account, username string) int {
+	for index, account := range accounts {
+		if account.Username == username {
+			return index
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,228 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+)
+
+func parse(t *testing.T, format string, data []byte) Result {
+	parser, err := GetParser(format)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	records, skipped, err := parser.Parse(data)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return Build(records, skipped)
+}
+
+func checkAccount(t *testing.T, result Result, name string, username string, password string) {
+	for _, login := range result.Logins {
+		if login.Name != name {
+			continue
+		}
+
+		if index := findAccount(login.Accounts, username); index != -1 {
+			if login.Accounts[index].Password != password {
+				t.Errorf("%s/%s\nExpected: %s\nActual: %s", name, username, password, login.Accounts[index].Password)
+			}
+			return
+		}
+	}
+
+	t.Errorf("%s/%s not imported", name, username)
+}
+
+func TestParse_BitwardenJSON(t *testing.T) {
+	data := []byte(`{
+		"encrypted": false,
+		"items": [
+			{"type": 1, "name": "GitHub", "favorite": true, "login": {"uris": [{"uri": "https://github.com"}], "username": "abc", "password": "123", "totp": "JBSWY3DPEHPK3PXP"}},
+			{"type": 1, "name": "github", "favorite": false, "login": {"uris": [], "username": "pqr", "password": "456", "totp": null}},
+			{"type": 2, "name": "Wifi", "notes": "password is 789", "secureNote": {"type": 0}},
+			{"type": 3, "name": "Visa", "card": {}}
+		]
+	}`)
+
+	result := parse(t, BITWARDEN_JSON, data)
+
+	if len(result.Logins) != 1 || len(result.Logins[0].Accounts) != 2 {
+		t.Fatalf("Accounts of same site should be merged\n%+v", result.Logins)
+	}
+
+	login := result.Logins[0]
+	if login.URL != "https://github.com" || !login.Attributes.IsFavourite || login.Accounts[0].TOTPSecret == nil {
+		t.Errorf("Incorrect login %+v", login)
+	}
+
+	checkAccount(t, result, "GitHub", "abc", "123")
+	checkAccount(t, result, "GitHub", "pqr", "456")
+
+	if len(result.Notes) != 1 || result.Notes[0].Title != "Wifi" || result.Notes[0].Content != "password is 789" {
+		t.Errorf("Incorrect notes %+v", result.Notes)
+	}
+
+	if len(result.Skipped) != 1 || result.Skipped[0].Row != 4 {
+		t.Errorf("Card should be skipped\n%+v", result.Skipped)
+	}
+
+	if _, _, err := parsers[BITWARDEN_JSON].Parse([]byte(`{"encrypted": true, "items": []}`)); err == nil {
+		t.Error("Should fail for encrypted export")
+	}
+}
+
+func TestParse_OnePassword1PUX(t *testing.T) {
+	export_data := `{"accounts": [{"vaults": [{"items": [
+		{"favIndex": 1, "state": "active", "categoryUuid": "001", "overview": {"title": "GitHub", "url": "https://github.com"},
+			"details": {"loginFields": [{"value": "abc", "designation": "username"}, {"value": "123", "designation": "password"}],
+				"sections": [{"fields": [{"value": {"totp": "otpauth://totp/GitHub:abc?secret=JBSWY3DPEHPK3PXP"}}]}]}},
+		{"favIndex": 0, "state": "archived", "categoryUuid": "001", "overview": {"title": "Old"}, "details": {}},
+		{"favIndex": 0, "state": "active", "categoryUuid": "003", "overview": {"title": "Wifi"}, "details": {"notesPlain": "password is 789"}}
+	]}]}]}`
+
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	file, _ := writer.Create("export.data")
+	file.Write([]byte(export_data))
+	writer.Close()
+
+	result := parse(t, ONEPASSWORD_1PUX, archive.Bytes())
+
+	checkAccount(t, result, "GitHub", "abc", "123")
+
+	if len(result.Logins) != 1 || !result.Logins[0].Attributes.IsFavourite || result.Logins[0].Accounts[0].TOTPSecret == nil {
+		t.Errorf("Incorrect logins %+v", result.Logins)
+	}
+
+	if len(result.Notes) != 1 || len(result.Skipped) != 1 {
+		t.Errorf("Incorrect notes %+v\nskipped %+v", result.Notes, result.Skipped)
+	}
+}
+
+func TestParse_OnePasswordCSV(t *testing.T) {
+	data := []byte("Title,Url,Username,Password,OTPAuth,Favorite,Archived,Tags,Notes\n" +
+		"GitHub,https://github.com,abc,123,,true,false,,\n" +
+		"Old,https://old.com,abc,123,,false,true,,\n")
+
+	result := parse(t, ONEPASSWORD_CSV, data)
+
+	checkAccount(t, result, "GitHub", "abc", "123")
+
+	if len(result.Logins) != 1 || len(result.Skipped) != 1 {
+		t.Errorf("Archived item should be skipped\n%+v", result)
+	}
+}
+
+func TestParse_KeePassXML(t *testing.T) {
+	data := []byte(`<?xml version="1.0" encoding="utf-8" standalone="yes"?>
+<KeePassFile>
+	<Meta><RecycleBinUUID>BIN</RecycleBinUUID></Meta>
+	<Root>
+		<Group>
+			<UUID>ROOT</UUID>
+			<Name>Database</Name>
+			<Entry>
+				<String><Key>Title</Key><Value>GitHub</Value></String>
+				<String><Key>UserName</Key><Value>abc</Value></String>
+				<String><Key>Password</Key><Value ProtectInMemory="True">123</Value></String>
+				<String><Key>URL</Key><Value>https://github.com</Value></String>
+				<String><Key>otp</Key><Value>otpauth://totp/GitHub:abc?secret=JBSWY3DPEHPK3PXP</Value></String>
+				<History>
+					<Entry>
+						<String><Key>Title</Key><Value>GitHub</Value></String>
+						<String><Key>UserName</Key><Value>abc</Value></String>
+						<String><Key>Password</Key><Value>old</Value></String>
+					</Entry>
+				</History>
+			</Entry>
+			<Group>
+				<UUID>EMAIL</UUID>
+				<Name>Email</Name>
+				<Entry>
+					<String><Key>Title</Key><Value></Value></String>
+					<String><Key>UserName</Key><Value>pqr</Value></String>
+					<String><Key>Password</Key><Value>456</Value></String>
+					<String><Key>URL</Key><Value>https://www.mail.com/login</Value></String>
+				</Entry>
+				<Entry>
+					<String><Key>Title</Key><Value>Wifi</Value></String>
+					<String><Key>Notes</Key><Value>password is 789</Value></String>
+				</Entry>
+			</Group>
+			<Group>
+				<UUID>BIN</UUID>
+				<Name>Recycle Bin</Name>
+				<Entry>
+					<String><Key>Title</Key><Value>Deleted</Value></String>
+					<String><Key>Password</Key><Value>000</Value></String>
+				</Entry>
+			</Group>
+		</Group>
+	</Root>
+</KeePassFile>`)
+
+	result := parse(t, KEEPASS_XML, data)
+
+	checkAccount(t, result, "GitHub", "abc", "123")
+	checkAccount(t, result, "mail.com", "pqr", "456")
+
+	if len(result.Logins) != 2 || len(result.Logins[0].Accounts) != 1 || len(result.Notes) != 1 {
+		t.Errorf("History and recycle bin should be ignored\n%+v", result)
+	}
+}
+
+func TestParse_ChromeAndFirefoxCSV(t *testing.T) {
+	chrome_data := []byte("\xef\xbb\xbfname,url,username,password,note\n" +
+		"github.com,https://github.com/login,abc,123,\n" +
+		"github.com,https://github.com/login,abc,123,\n" +
+		"github.com,https://github.com/login,abc,456,\n" +
+		"github.com,https://github.com/login\n" +
+		",,,,\n")
+
+	result := parse(t, CHROME_CSV, chrome_data)
+
+	checkAccount(t, result, "github.com", "abc", "123")
+
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Row != 3 {
+		t.Errorf("Different password for same username should be a conflict\n%+v", result.Conflicts)
+	}
+
+	// Duplicate, invalid row and empty row
+	if len(result.Skipped) != 3 {
+		t.Errorf("Expected: %d\nActual: %d\n%+v", 3, len(result.Skipped), result.Skipped)
+	}
+
+	firefox_data := []byte(`"url","username","password","httpRealm","formActionOrigin","guid","timeCreated","timeLastUsed","timePasswordChanged"
+"https://github.com","abc","123",,"https://github.com","{1}","1","1","1"
+"https://gitlab.com","abc","456",,"https://gitlab.com","{2}","1","1","1"
+`)
+
+	result = parse(t, FIREFOX_CSV, firefox_data)
+
+	checkAccount(t, result, "github.com", "abc", "123")
+	checkAccount(t, result, "gitlab.com", "abc", "456")
+
+	if _, _, err := parsers[FIREFOX_CSV].Parse([]byte("name,user\n")); err == nil {
+		t.Error("Should fail for missing columns")
+	}
+}
+
+func TestBuild_InvalidTOTPSecret(t *testing.T) {
+	result := Build([]Record{{Row: 1, Name: "github", Username: "abc", Password: "123", TOTPSecret: "not base32!"}}, nil)
+
+	if len(result.Logins) != 1 || result.Logins[0].Accounts[0].TOTPSecret != nil || len(result.Skipped) != 1 {
+		t.Errorf("Invalid TOTP secret should be dropped\n%+v", result)
+	}
+}
+
+func TestGetParser_UnsupportedFormat(t *testing.T) {
+	if _, err := GetParser("LASTPASS"); err != ErrUnsupportedFormat {
+		t.Errorf("Expected: %v\nActual: %v", ErrUnsupportedFormat, err)
+	}
+}
@@ -0,0 +1,81 @@
+package importer
+
+import (
+	"encoding/xml"
+	"ncrypt/models"
+	"strings"
+)
+
+// Unencrypted XML export of KeePass 2.x and KeePassXC
+type keePassParser struct{}
+
+type keePassFile struct {
+	Meta struct {
+		RecycleBinUUID string `xml:"RecycleBinUUID"`
+	} `xml:"Meta"`
+	Root struct {
+		Groups []keePassGroup `xml:"Group"`
+	} `xml:"Root"`
+}
+
+type keePassGroup struct {
+	UUID    string         `xml:"UUID"`
+	Name    string         `xml:"Name"`
+	Entries []keePassEntry `xml:"Entry"`
+	Groups  []keePassGroup `xml:"Group"`
+}
+
+// Only direct String children are read so older versions of the entry kept under History are ignored
+type keePassEntry struct {
+	Strings []struct {
+		Key   string `xml:"Key"`
+		Value string `xml:"Value"`
+	} `xml:"String"`
+}
+
+func (obj *keePassParser) Parse(data []byte) ([]Record, []models.ImportIssue, error) {
+	var file keePassFile
+
+	if err := xml.Unmarshal(data, &file); err != nil {
+		return nil, nil, err
+	}
+
+	var records []Record
+
+	var readGroup func(group keePassGroup)
+	readGroup = func(group keePassGroup) {
+		if file.Meta.RecycleBinUUID != "" && group.UUID == file.Meta.RecycleBinUUID {
+			return
+		}
+
+		for _, entry := range group.Entries {
+			values := make(map[string]string)
+			for _, value := range entry.Strings {
+				values[value.Key] = value.Value
+			}
+
+			record := Record{Row: len(records) + 1, Name: values["Title"], URL: values["URL"], Username: values["UserName"], Password: values["Password"], Notes: values["Notes"]}
+
+			// KeePassXC stores an otpauth:// URI, KeePass 2.47+ stores the base32 secret
+			record.TOTPSecret = values["otp"]
+			if record.TOTPSecret == "" {
+				record.TOTPSecret = values["TimeOtp-Secret-Base32"]
+			}
+
+			// Entries with only notes are notes
+			record.IsNote = record.Username == "" && record.Password == "" && strings.TrimSpace(record.Notes) != ""
+
+			records = append(records, record)
+		}
+
+		for _, child_group := range group.Groups {
+			readGroup(child_group)
+		}
+	}
+
+	for _, group := range file.Root.Groups {
+		readGroup(group)
+	}
+
+	return records, nil, nil
+}
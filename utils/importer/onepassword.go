@@ -0,0 +1,127 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"ncrypt/models"
+)
+
+const (
+	ONEPASSWORD_LOGIN       = "001"
+	ONEPASSWORD_SECURE_NOTE = "003"
+	ONEPASSWORD_PASSWORD    = "005"
+)
+
+// 1PUX export of 1Password, a zip archive with all vaults in export.data
+type onePasswordParser struct{}
+
+type onePasswordExport struct {
+	Accounts []struct {
+		Vaults []struct {
+			Items []onePasswordItem `json:"items"`
+		} `json:"vaults"`
+	} `json:"accounts"`
+}
+
+type onePasswordItem struct {
+	FavIndex     int    `json:"favIndex"`
+	State        string `json:"state"`
+	CategoryUUID string `json:"categoryUuid"`
+	Overview     struct {
+		Title string `json:"title"`
+		URL   string `json:"url"`
+	} `json:"overview"`
+	Details struct {
+		LoginFields []struct {
+			Value       string `json:"value"`
+			Designation string `json:"designation"`
+		} `json:"loginFields"`
+		NotesPlain string `json:"notesPlain"`
+		Password   string `json:"password"`
+		Sections   []struct {
+			Fields []struct {
+				Value map[string]interface{} `json:"value"`
+			} `json:"fields"`
+		} `json:"sections"`
+	} `json:"details"`
+}
+
+func (obj *onePasswordParser) Parse(data []byte) ([]Record, []models.ImportIssue, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	export_file, err := archive.Open("export.data")
+
+	if err != nil {
+		return nil, nil, errors.New("export.data not found in 1pux archive")
+	}
+	defer export_file.Close()
+
+	export_data, err := io.ReadAll(export_file)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var export onePasswordExport
+
+	if err := json.Unmarshal(export_data, &export); err != nil {
+		return nil, nil, err
+	}
+
+	var records []Record
+	var skipped []models.ImportIssue
+
+	row := 0
+	for _, account := range export.Accounts {
+		for _, vault := range account.Vaults {
+			for _, item := range vault.Items {
+				row++
+				record := Record{Row: row, Name: item.Overview.Title, URL: item.Overview.URL, Notes: item.Details.NotesPlain, IsFavourite: item.FavIndex > 0}
+
+				if item.State == "archived" {
+					skipped = append(skipped, models.ImportIssue{Row: row, Name: record.Name, Reason: "archived item"})
+					continue
+				}
+
+				switch item.CategoryUUID {
+				case ONEPASSWORD_LOGIN, ONEPASSWORD_PASSWORD:
+					record.Password = item.Details.Password
+
+					for _, field := range item.Details.LoginFields {
+						switch field.Designation {
+						case "username":
+							record.Username = field.Value
+						case "password":
+							record.Password = field.Value
+						}
+					}
+
+					// One time password is a section field with a totp value
+					for _, section := range item.Details.Sections {
+						for _, field := range section.Fields {
+							if totp_secret, exists := field.Value["totp"].(string); exists && record.TOTPSecret == "" {
+								record.TOTPSecret = totp_secret
+							}
+						}
+					}
+				case ONEPASSWORD_SECURE_NOTE:
+					record.IsNote = true
+				default:
+					skipped = append(skipped, models.ImportIssue{Row: row, Name: record.Name, Reason: "unsupported item type"})
+					continue
+				}
+
+				records = append(records, record)
+			}
+		}
+	}
+
+	return records, skipped, nil
+}
@@ -2,59 +2,50 @@ package jwt
 
 import (
 	"errors"
-	"fmt"
 	"ncrypt/utils/logger"
 	"net/http"
-	"os"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Key of the session ID (jti) of the request in gin context
+const SESSION_ID = "SESSION_ID"
+
+var ErrSessionRevoked = errors.New("session expired or logged out\nplease login")
+
 func ValidateAuthorization() gin.HandlerFunc {
 	logger.Log.Println("Validating JWT token")
 	return func(context *gin.Context) {
-		//Get authorization token from cookies
 		header := context.Request.Header.Get("Authorization")
+		token_string, found := strings.CutPrefix(header, "Bearer ")
 
-		if header == "" {
-			context.AbortWithError(http.StatusUnauthorized, errors.New("authorization token not found"))
+		if header == "" || !found {
+			context.AbortWithStatusJSON(http.StatusUnauthorized, "authorization token not found")
+			return
 		}
 
-		token_string := strings.Split(header, " ")[1]
-
-		// Parse the token
-		token, err := jwt.Parse(token_string, func(token *jwt.Token) (interface{}, error) {
-			// Don't forget to validate the alg is what you expect:
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-
-			// hmacSampleSecret is a []byte containing your secret, e.g. []byte("my_secret_key")
-			return []byte(os.Getenv("MASTER_PASSWORD_KEY")), nil
-		})
+		// Parse the token, exp and iat are checked by the parser
+		var claims jwt.RegisteredClaims
+		_, err := jwt.ParseWithClaims(token_string, &claims, func(token *jwt.Token) (interface{}, error) {
+			return getSecret(), nil
+		}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired(), jwt.WithIssuedAt())
 
 		if err != nil {
-			context.AbortWithError(http.StatusUnauthorized, errors.New("error parsing token"))
+			logger.Log.Printf("ERROR: %s", err.Error())
+			context.AbortWithStatusJSON(http.StatusUnauthorized, "invalid or expired token")
+			return
 		}
 
-		// Read parsed token
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			//Check for expired token
-			if float64(time.Now().Unix()) > claims["expiry"].(float64) {
-				context.AbortWithError(http.StatusUnauthorized, errors.New("token expired"))
-			} else {
-				authorization := claims["is_authorized"].(bool)
-
-				//Check for authorization
-				if !authorization {
-					context.AbortWithError(http.StatusUnauthorized, errors.New("not authorized\nplease login"))
-				}
-			}
-		} else {
-			context.AbortWithError(http.StatusUnauthorized, errors.New("error parsing token"))
+		// Revoked on logout or master password change
+		if !useSession(claims.ID, context.ClientIP(), context.Request.UserAgent()) {
+			logger.Log.Printf("ERROR: %s", ErrSessionRevoked.Error())
+			context.AbortWithStatusJSON(http.StatusUnauthorized, ErrSessionRevoked.Error())
+			return
 		}
+
+		context.Set(SESSION_ID, claims.ID)
+		context.Next()
 	}
 }
@@ -0,0 +1,137 @@
+package jwt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func authorizedRequest(token string) int {
+	server := gin.New()
+	server.Use(ValidateAuthorization())
+	server.GET("/", func(ctx *gin.Context) {
+		if ctx.GetString(SESSION_ID) == "" {
+			ctx.Status(http.StatusInternalServerError)
+			return
+		}
+		ctx.Status(http.StatusOK)
+	})
+
+	test := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	server.ServeHTTP(test, req)
+	return test.Code
+}
+
+func TestValidateAuthorization(t *testing.T) {
+	t.Cleanup(RevokeAllSessions)
+
+	token, err := GenerateToken(5)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if code := authorizedRequest(token); code != http.StatusOK {
+		t.Errorf("Expected: %d\nActual: %d", http.StatusOK, code)
+	}
+
+	if code := authorizedRequest(""); code != http.StatusUnauthorized {
+		t.Errorf("Missing token\nExpected: %d\nActual: %d", http.StatusUnauthorized, code)
+	}
+
+	if code := authorizedRequest(token + "x"); code != http.StatusUnauthorized {
+		t.Errorf("Modified token\nExpected: %d\nActual: %d", http.StatusUnauthorized, code)
+	}
+
+	// Validly signed but never issued by the server
+	unknown_token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{ID: "unknown", IssuedAt: jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}).SignedString(getSecret())
+
+	if code := authorizedRequest(unknown_token); code != http.StatusUnauthorized {
+		t.Errorf("Unknown session\nExpected: %d\nActual: %d", http.StatusUnauthorized, code)
+	}
+
+	expired_token, _ := GenerateToken(-1)
+
+	if code := authorizedRequest(expired_token); code != http.StatusUnauthorized {
+		t.Errorf("Expired token\nExpected: %d\nActual: %d", http.StatusUnauthorized, code)
+	}
+}
+
+func TestRevokeSessions(t *testing.T) {
+	t.Cleanup(RevokeAllSessions)
+
+	token, _ := GenerateToken(5)
+	other_token, _ := GenerateToken(5)
+
+	sessions := GetSessions()
+
+	if len(sessions) != 2 {
+		t.Fatalf("Expected 2 sessions\n%+v", sessions)
+	}
+
+	RevokeSession(sessions[0].ID)
+
+	if len(GetSessions()) != 1 {
+		t.Errorf("Expected revoked session to be removed\n%+v", GetSessions())
+	}
+
+	if authorizedRequest(token) == http.StatusOK && authorizedRequest(other_token) == http.StatusOK {
+		t.Error("Revoked token should be rejected")
+	}
+
+	RevokeAllSessions()
+
+	if code := authorizedRequest(other_token); code != http.StatusUnauthorized {
+		t.Errorf("Expected: %d\nActual: %d", http.StatusUnauthorized, code)
+	}
+
+	if len(GetSessions()) != 0 {
+		t.Errorf("Expected no sessions\n%+v", GetSessions())
+	}
+}
+
+func TestValidateElevatedToken(t *testing.T) {
+	t.Cleanup(RevokeAllSessions)
+
+	scope := Scope(LOGIN_SCOPE, "github")
+	elevated_token, _ := GenerateElevatedToken("session", scope, time.Minute)
+
+	if err := ValidateElevatedToken(elevated_token, "session", scope); err != nil {
+		t.Error(err.Error())
+	}
+
+	if err := ValidateElevatedToken(elevated_token, "session", Scope(LOGIN_SCOPE, "gitlab")); err != ErrReauthRequired {
+		t.Errorf("Mis-scoped token\nExpected: %v\nActual: %v", ErrReauthRequired, err)
+	}
+
+	if err := ValidateElevatedToken(elevated_token, "other session", scope); err != ErrReauthRequired {
+		t.Errorf("Other session\nExpected: %v\nActual: %v", ErrReauthRequired, err)
+	}
+
+	expired_token, _ := GenerateElevatedToken("session", scope, -time.Minute)
+
+	if err := ValidateElevatedToken(expired_token, "session", scope); err != ErrReauthRequired {
+		t.Errorf("Expired token\nExpected: %v\nActual: %v", ErrReauthRequired, err)
+	}
+
+	// Elevated tokens are not session tokens
+	if code := authorizedRequest(elevated_token); code != http.StatusUnauthorized {
+		t.Errorf("Expected: %d\nActual: %d", http.StatusUnauthorized, code)
+	}
+
+	RevokeAllSessions()
+
+	if err := ValidateElevatedToken(elevated_token, "session", scope); err != ErrReauthRequired {
+		t.Errorf("Token signed before revocation\nExpected: %v\nActual: %v", ErrReauthRequired, err)
+	}
+}
@@ -0,0 +1,75 @@
+package jwt
+
+import (
+	"errors"
+	"ncrypt/utils/logger"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Header carrying an elevated token when revealing an entry that requires the master password
+const ELEVATED_TOKEN_HEADER = "Reauth-Token"
+
+// Elevated tokens are meant to be used right after the master password is re-entered
+const ELEVATED_TOKEN_VALIDITY = 2 * time.Minute
+
+const (
+	LOGIN_SCOPE = "LOGIN"
+	NOTE_SCOPE  = "NOTE"
+)
+
+var ErrReauthRequired = errors.New("master password has to be re-entered for this entry")
+
+// Issued by POST /system/reauth. Only valid for a single entry and along with the session it was issued to
+type elevatedClaims struct {
+	SessionID string `json:"sid"`
+	Scope     string `json:"scope"`
+	jwt.RegisteredClaims
+}
+
+// Get scope of an entry, e.g. LOGIN:github
+func Scope(entry_type string, entry_id string) string {
+	return entry_type + ":" + entry_id
+}
+
+func GenerateElevatedToken(session_id string, scope string, validity time.Duration) (string, error) {
+	logger.Log.Println("Generating elevated token")
+	now := time.Now()
+
+	// No jti, so elevated tokens are never accepted as session tokens
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, elevatedClaims{
+		SessionID: session_id,
+		Scope:     scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(validity)),
+		},
+	})
+
+	return token.SignedString(getSecret())
+}
+
+// Check elevated token was issued to the session for the given scope and has not expired
+func ValidateElevatedToken(token_string string, session_id string, scope string) error {
+	if token_string == "" {
+		return ErrReauthRequired
+	}
+
+	var claims elevatedClaims
+	_, err := jwt.ParseWithClaims(token_string, &claims, func(token *jwt.Token) (interface{}, error) {
+		return getSecret(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired(), jwt.WithIssuedAt())
+
+	if err != nil {
+		logger.Log.Printf("ERROR: %s", err.Error())
+		return ErrReauthRequired
+	}
+
+	if claims.SessionID != session_id || claims.Scope != scope {
+		logger.Log.Printf("ERROR: elevated token issued for another session or entry")
+		return ErrReauthRequired
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Active session as tracked by the server. Tokens whose jti is not in the store are rejected
+type Session struct {
+	ID         string
+	IssuedAt   time.Time
+	ExpiresAt  time.Time
+	LastUsedAt time.Time
+	ClientIP   string
+	UserAgent  string
+}
+
+var (
+	sessions_lock sync.RWMutex
+	sessions      = make(map[string]*Session)
+	// Tokens are signed with a random secret that never leaves the process and is replaced when all sessions are revoked
+	signing_secret = newSecret()
+)
+
+func newSecret() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+
+	return secret
+}
+
+func newSessionID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+func getSecret() []byte {
+	sessions_lock.RLock()
+	defer sessions_lock.RUnlock()
+
+	return signing_secret
+}
+
+func addSession(session *Session) {
+	sessions_lock.Lock()
+	defer sessions_lock.Unlock()
+
+	sessions[session.ID] = session
+}
+
+// Check session is active and record its use
+func useSession(id string, client_ip string, user_agent string) bool {
+	sessions_lock.Lock()
+	defer sessions_lock.Unlock()
+
+	session, exists := sessions[id]
+
+	if !exists {
+		return false
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		delete(sessions, id)
+		return false
+	}
+
+	session.LastUsedAt = time.Now()
+	session.ClientIP = client_ip
+	session.UserAgent = user_agent
+
+	return true
+}
+
+// Get active sessions, most recently issued first
+func GetSessions() []Session {
+	sessions_lock.Lock()
+	defer sessions_lock.Unlock()
+
+	now := time.Now()
+	active_sessions := []Session{}
+
+	for id, session := range sessions {
+		if now.After(session.ExpiresAt) {
+			delete(sessions, id)
+			continue
+		}
+
+		active_sessions = append(active_sessions, *session)
+	}
+
+	sort.Slice(active_sessions, func(i, j int) bool {
+		return active_sessions[i].IssuedAt.After(active_sessions[j].IssuedAt)
+	})
+
+	return active_sessions
+}
+
+func RevokeSession(id string) {
+	sessions_lock.Lock()
+	defer sessions_lock.Unlock()
+
+	delete(sessions, id)
+}
+
+// Revoke every session, e.g. on logout or master password change. Tokens signed before are rejected even if the store is bypassed
+func RevokeAllSessions() {
+	sessions_lock.Lock()
+	defer sessions_lock.Unlock()
+
+	sessions = make(map[string]*Session)
+	signing_secret = newSecret()
+}
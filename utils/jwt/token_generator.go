@@ -2,18 +2,36 @@ package jwt
 
 import (
 	"ncrypt/utils/logger"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Generate token for a new session valid for the given minutes
 func GenerateToken(token_validity_in_minutes int) (string, error) {
 	logger.Log.Println("Generating JWT token")
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"is_authorized": true,
-		"expiry":        time.Now().Add(time.Duration(token_validity_in_minutes) * time.Minute).Unix(), //Token valid for 20 mins
+	session_id, err := newSessionID()
+
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	session := &Session{ID: session_id, IssuedAt: now, ExpiresAt: now.Add(time.Duration(token_validity_in_minutes) * time.Minute), LastUsedAt: now}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		ID:        session.ID,
+		IssuedAt:  jwt.NewNumericDate(session.IssuedAt),
+		ExpiresAt: jwt.NewNumericDate(session.ExpiresAt),
 	})
 
-	return token.SignedString([]byte(os.Getenv("MASTER_PASSWORD_KEY")))
+	token_string, err := token.SignedString(getSecret())
+
+	if err != nil {
+		return "", err
+	}
+
+	addSession(session)
+
+	return token_string, nil
 }
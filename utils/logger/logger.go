@@ -1,40 +1,236 @@
 package logger
 
 import (
+	"context"
+	"errors"
+	"io"
 	"log"
+	"log/slog"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+const (
+	DEFAULT_LOG_FOLDER           = "logs"
+	DEFAULT_LOG_MAX_SIZE_IN_MB   = 10
+	DEFAULT_LOG_MAX_AGE_IN_HOURS = 24
+	DEFAULT_LOG_MAX_FILES        = 5
+)
+
 var (
-	Log  *log.Logger
-	file *os.File
+	// Printf style logger. Messages starting with "ERROR:", "WARN:" or "DEBUG:" are logged at that level, others at INFO
+	Log *log.Logger
+	// Structured logger, used for new code
+	Slog *slog.Logger
+
+	level = new(slog.LevelVar)
+	file  *rotatingFile
+
+	handler_lock    sync.RWMutex
+	current_handler slog.Handler
 )
 
+// Logger settings read from LOG_LEVEL, LOG_FORMAT, LOG_FOLDER, LOG_MAX_SIZE_IN_MB, LOG_MAX_AGE_IN_HOURS and LOG_MAX_FILES
+type config struct {
+	level     slog.Level
+	format    string
+	folder    string
+	max_size  int64
+	max_age   time.Duration
+	max_files int
+}
+
 func init() {
-	dir := "logs"
-	os.Mkdir(dir, os.ModePerm)
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		err := os.Mkdir(dir, os.ModePerm)
-		if err != nil {
-			panic("ERROR creating logs directory")
+	config, _ := configFromEnv()
+
+	var err error
+	file, err = openRotatingFile(config.folder, config.max_size, config.max_age, config.max_files)
+
+	if err != nil {
+		panic(err)
+	}
+
+	level.Set(config.level)
+	setFormat(config.format)
+
+	Slog = slog.New(currentHandler{})
+	Log = log.New(levelWriter{level: slog.LevelInfo, is_level_parsed: true}, "", log.Lshortfile)
+	Log.Println("LogFile : " + file.Path())
+}
+
+// Read logger settings from env variables. Invalid values are reported and replaced by defaults
+func configFromEnv() (config, error) {
+	result := config{
+		level:     slog.LevelInfo,
+		format:    "TEXT",
+		folder:    DEFAULT_LOG_FOLDER,
+		max_size:  DEFAULT_LOG_MAX_SIZE_IN_MB * 1024 * 1024,
+		max_age:   DEFAULT_LOG_MAX_AGE_IN_HOURS * time.Hour,
+		max_files: DEFAULT_LOG_MAX_FILES,
+	}
+	var errs []error
+
+	if value := os.Getenv("LOG_LEVEL"); value != "" {
+		if err := result.level.UnmarshalText([]byte(value)); err != nil {
+			errs = append(errs, errors.New("invalid LOG_LEVEL "+value))
 		}
 	}
 
-	logpath := dir + "\\log-" + time.Now().Format(time.RFC3339) + ".log"
-	logpath = strings.ReplaceAll(logpath, ":", "-")
+	if value := strings.ToUpper(os.Getenv("LOG_FORMAT")); value != "" {
+		if value == "TEXT" || value == "JSON" {
+			result.format = value
+		} else {
+			errs = append(errs, errors.New("invalid LOG_FORMAT "+value))
+		}
+	}
 
-	//    flag.Parse()
-	file, err := os.Create(logpath)
+	if value := os.Getenv("LOG_FOLDER"); value != "" {
+		result.folder = value
+	}
 
-	if err != nil {
-		panic(err)
+	for _, setting := range []struct {
+		name  string
+		apply func(value int)
+	}{
+		{"LOG_MAX_SIZE_IN_MB", func(value int) { result.max_size = int64(value) * 1024 * 1024 }},
+		{"LOG_MAX_AGE_IN_HOURS", func(value int) { result.max_age = time.Duration(value) * time.Hour }},
+		{"LOG_MAX_FILES", func(value int) { result.max_files = value }},
+	} {
+		value := os.Getenv(setting.name)
+		if value == "" {
+			continue
+		}
+
+		parsed_value, err := strconv.Atoi(value)
+		if err != nil || parsed_value < 0 {
+			errs = append(errs, errors.New("invalid "+setting.name+" "+value))
+			continue
+		}
+
+		setting.apply(parsed_value)
+	}
+
+	return result, errors.Join(errs...)
+}
+
+/*
+Apply logger settings from env variables.
+
+Logging starts with settings available when the process starts, this is called again once .env is loaded. Invalid values are
+returned as an error and left at their defaults.
+*/
+func Configure() error {
+	config, config_err := configFromEnv()
+
+	level.Set(config.level)
+	setFormat(config.format)
+	err := file.configure(config.folder, config.max_size, config.max_age, config.max_files)
+
+	return errors.Join(config_err, err)
+}
+
+func setFormat(format string) {
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler = slog.NewTextHandler(file, options)
+	if format == "JSON" {
+		handler = slog.NewJSONHandler(file, options)
 	}
-	Log = log.New(file, "", log.LstdFlags|log.Lshortfile)
-	Log.Println("LogFile : " + logpath)
+
+	handler_lock.Lock()
+	defer handler_lock.Unlock()
+
+	current_handler = redactingHandler{handler: handler}
+}
+
+func getHandler() slog.Handler {
+	handler_lock.RLock()
+	defer handler_lock.RUnlock()
+
+	return current_handler
+}
+
+// Handler forwarding to the handler of the current format, so loggers created before Configure follow it
+type currentHandler struct{}
+
+func (obj currentHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return getHandler().Enabled(ctx, level)
+}
+
+func (obj currentHandler) Handle(ctx context.Context, record slog.Record) error {
+	return getHandler().Handle(ctx, record)
+}
+
+func (obj currentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return getHandler().WithAttrs(attrs)
+}
+
+func (obj currentHandler) WithGroup(name string) slog.Handler {
+	return getHandler().WithGroup(name)
+}
+
+// Writer turning each write into a log record, used to route log.Logger and gin output through slog
+type levelWriter struct {
+	level           slog.Level
+	is_level_parsed bool // Take level from an "ERROR:" style prefix of the message
+}
+
+func (obj levelWriter) Write(data []byte) (int, error) {
+	message := strings.TrimRight(string(data), "\n")
+	record_level := obj.level
+
+	// file.go:12: prefix added by log.Lshortfile
+	var source string
+	if index := strings.Index(message, ": "); index != -1 && strings.Contains(message[:index], ".go:") {
+		source = message[:index]
+		message = message[index+2:]
+	}
+
+	if obj.is_level_parsed {
+		record_level, message = parseLevel(message, record_level)
+	}
+
+	handler := getHandler()
+	if !handler.Enabled(context.Background(), record_level) {
+		return len(data), nil
+	}
+
+	record := slog.NewRecord(time.Now(), record_level, message, 0)
+	if source != "" {
+		record.AddAttrs(slog.String("source", source))
+	}
+
+	return len(data), handler.Handle(context.Background(), record)
+}
+
+func parseLevel(message string, default_level slog.Level) (slog.Level, string) {
+	for _, prefix := range []struct {
+		text  string
+		level slog.Level
+	}{
+		{"ERROR:", slog.LevelError},
+		{"WARNING:", slog.LevelWarn},
+		{"WARN:", slog.LevelWarn},
+		{"DEBUG:", slog.LevelDebug},
+		{"INFO:", slog.LevelInfo},
+	} {
+		if strings.HasPrefix(message, prefix.text) {
+			return prefix.level, strings.TrimSpace(message[len(prefix.text):])
+		}
+	}
+
+	return default_level, message
+}
+
+// Writer logging every write at the given level
+func Writer(level slog.Level) io.Writer {
+	return levelWriter{level: level}
 }
 
+// Flush and close the log file. Nothing is logged afterwards
 func Close() {
 	file.Close()
 }
@@ -0,0 +1,225 @@
+package logger
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func readLogFile(t *testing.T) string {
+	data, err := os.ReadFile(file.Path())
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return string(data)
+}
+
+// Apply given env variables until the test ends
+func configureForTest(t *testing.T, env map[string]string) {
+	for key, value := range env {
+		os.Setenv(key, value)
+	}
+
+	if err := Configure(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	t.Cleanup(func() {
+		for key := range env {
+			os.Unsetenv(key)
+		}
+		Configure()
+	})
+}
+
+func TestRedact(t *testing.T) {
+	tests := map[string]string{
+		`{"master_password": "hunter2", "name": "github"}`:         `{"master_password": "[REDACTED]", "name": "github"}`,
+		`{"old_master_password":"a\"b","new_master_password":"c"}`: `{"old_master_password":"[REDACTED]","new_master_password":"[REDACTED]"}`,
+		`{"content":"my note","title":"wifi"}`:                     `{"content":"[REDACTED]","title":"wifi"}`,
+		`{"PASSWORD": 12345}`:                                      `{"PASSWORD": "[REDACTED]"}`,
+		`/system/reauth?password=hunter2&type=LOGIN`:               `/system/reauth?password=[REDACTED]&type=LOGIN`,
+		`map[master_password:hunter2 type:LOGIN]`:                  `map[master_password:[REDACTED] type:LOGIN]`,
+		`ERROR: invalid password`:                                  `ERROR: invalid password`,
+	}
+
+	for text, expected := range tests {
+		if result := Redact(text); result != expected {
+			t.Errorf("Expected: %s\nActual: %s", expected, result)
+		}
+	}
+}
+
+func TestLog_Redacted(t *testing.T) {
+	Log.Printf(`ERROR: failed to bind {"master_password": "hunter2"}`)
+	Slog.Info("request body", "password", "hunter3", "content", "hunter4", "body", `{"password":"hunter5"}`)
+	Slog.With("new_master_password", "hunter6").Info("updating")
+
+	log_data := readLogFile(t)
+
+	for _, secret := range []string{"hunter2", "hunter3", "hunter4", "hunter5", "hunter6"} {
+		if strings.Contains(log_data, secret) {
+			t.Errorf("%s should be redacted", secret)
+		}
+	}
+
+	if !strings.Contains(log_data, "level=ERROR") || !strings.Contains(log_data, "source=logger_test.go") {
+		t.Errorf("Level and source should be taken from Log output\n%s", log_data)
+	}
+}
+
+func TestConfigure_Level(t *testing.T) {
+	configureForTest(t, map[string]string{"LOG_LEVEL": "WARN"})
+
+	Log.Printf("hidden info message")
+	Log.Printf("WARN: shown warning message")
+
+	log_data := readLogFile(t)
+
+	if strings.Contains(log_data, "hidden info message") {
+		t.Error("INFO should not be logged at WARN level")
+	}
+
+	if !strings.Contains(log_data, "shown warning message") {
+		t.Error("WARN should be logged at WARN level")
+	}
+}
+
+func TestConfigure_JSON(t *testing.T) {
+	configureForTest(t, map[string]string{"LOG_FORMAT": "json"})
+
+	Log.Printf("ERROR: json message")
+
+	lines := strings.Split(strings.TrimSpace(readLogFile(t)), "\n")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &record); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if record["level"] != "ERROR" || record["msg"] != "json message" {
+		t.Errorf("Unexpected record %v", record)
+	}
+}
+
+func TestConfigure_Invalid(t *testing.T) {
+	os.Setenv("LOG_MAX_FILES", "many")
+	defer os.Unsetenv("LOG_MAX_FILES")
+
+	if err := Configure(); err == nil {
+		t.Error("Should fail for invalid LOG_MAX_FILES")
+	}
+}
+
+func TestRotatingFile_Size(t *testing.T) {
+	folder := t.TempDir()
+	rotating_file, err := openRotatingFile(folder, 100, 0, 2)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer rotating_file.Close()
+
+	first_path := rotating_file.Path()
+	line := []byte(strings.Repeat("a", 59) + "\n")
+
+	for range 5 {
+		if _, err := rotating_file.Write(line); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	if rotating_file.Path() == first_path {
+		t.Error("Should rotate once max size is reached")
+	}
+
+	files, _ := filepath.Glob(filepath.Join(folder, "*.log"))
+
+	if len(files) != 2 {
+		t.Errorf("Expected 2 log files, got %d", len(files))
+	}
+
+	for _, path := range files {
+		if info, _ := os.Stat(path); info.Size() > 100 {
+			t.Errorf("%s is larger than max size", path)
+		}
+	}
+}
+
+func TestRotatingFile_Age(t *testing.T) {
+	rotating_file, err := openRotatingFile(t.TempDir(), 0, time.Hour, 0)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer rotating_file.Close()
+
+	first_path := rotating_file.Path()
+	rotating_file.Write([]byte("first\n"))
+
+	if rotating_file.Path() != first_path {
+		t.Error("Should not rotate before max age")
+	}
+
+	rotating_file.opened_at = time.Now().Add(-2 * time.Hour)
+	rotating_file.Write([]byte("second\n"))
+
+	if rotating_file.Path() == first_path {
+		t.Error("Should rotate once max age is reached")
+	}
+}
+
+func TestRequestLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	server.Use(RequestLogger())
+	server.POST("/system/signin", func(ctx *gin.Context) {
+		ctx.Status(http.StatusBadRequest)
+	})
+
+	test := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/system/signin?master_password=hunter7", strings.NewReader(`{"master_password":"hunter8"}`))
+	req.Header.Set(REQUEST_ID_HEADER, "test-request")
+	server.ServeHTTP(test, req)
+
+	if test.Header().Get(REQUEST_ID_HEADER) != "test-request" {
+		t.Errorf("Expected request ID to be returned, got %s", test.Header().Get(REQUEST_ID_HEADER))
+	}
+
+	log_data := readLogFile(t)
+
+	if !strings.Contains(log_data, "request_id=test-request") || !strings.Contains(log_data, "status=400") || !strings.Contains(log_data, "level="+slog.LevelWarn.String()) {
+		t.Errorf("Request not logged\n%s", log_data)
+	}
+
+	if strings.Contains(log_data, "hunter7") || strings.Contains(log_data, "hunter8") {
+		t.Error("Request secrets should not be logged")
+	}
+
+	// Generated when not sent by the client
+	test = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/system/signin", nil)
+	server.ServeHTTP(test, req)
+
+	if test.Header().Get(REQUEST_ID_HEADER) == "" {
+		t.Error("Request ID should be generated")
+	}
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+
+	Close()
+	os.RemoveAll(DEFAULT_LOG_FOLDER)
+	os.Exit(code)
+}
@@ -0,0 +1,115 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const REDACTED = "[REDACTED]"
+
+// Keys whose values are never written to the log, matched case-insensitively. Any key containing "password" is also redacted
+var SENSITIVE_KEYS = []string{"content", "secret", "totp_secret", "token", "authorization", "reauth-token"}
+
+var (
+	sensitive_key_pattern = `[a-z_\-]*password[a-z_\-]*|` + strings.Join(quoteAll(SENSITIVE_KEYS), "|")
+
+	// "password": "value" in JSON bodies
+	json_value_regex = regexp.MustCompile(`(?i)("(?:` + sensitive_key_pattern + `)"\s*:\s*)("(?:[^"\\]|\\.)*"|[^,}\s]+)`)
+	// password=value in query strings and form bodies
+	query_value_regex = regexp.MustCompile(`(?i)\b(` + sensitive_key_pattern + `)=([^&\s"]+)`)
+	// map[password:value] from printing maps
+	map_value_regex = regexp.MustCompile(`(?i)\b(` + sensitive_key_pattern + `):([^\s\]]+)`)
+)
+
+func quoteAll(values []string) []string {
+	quoted := make([]string, len(values))
+	for index, value := range values {
+		quoted[index] = regexp.QuoteMeta(value)
+	}
+	return quoted
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	if strings.Contains(key, "password") {
+		return true
+	}
+
+	for _, sensitive_key := range SENSITIVE_KEYS {
+		if key == sensitive_key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Replace values of sensitive keys found in JSON, query strings and printed maps
+func Redact(text string) string {
+	text = json_value_regex.ReplaceAllString(text, `${1}"`+REDACTED+`"`)
+	text = query_value_regex.ReplaceAllString(text, `${1}=`+REDACTED)
+	text = map_value_regex.ReplaceAllString(text, `${1}:`+REDACTED)
+	return text
+}
+
+func redactAttr(attr slog.Attr) slog.Attr {
+	if isSensitiveKey(attr.Key) {
+		return slog.String(attr.Key, REDACTED)
+	}
+
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, Redact(value.String()))
+	case slog.KindGroup:
+		group_attrs := value.Group()
+		redacted_attrs := make([]any, len(group_attrs))
+		for index, group_attr := range group_attrs {
+			redacted_attrs[index] = redactAttr(group_attr)
+		}
+		return slog.Group(attr.Key, redacted_attrs...)
+	case slog.KindAny:
+		return slog.String(attr.Key, Redact(value.String()))
+	}
+
+	return slog.Attr{Key: attr.Key, Value: value}
+}
+
+/*
+Handler removing secrets before records reach the wrapped handler.
+
+Every log line written to disk passes through it, so request bodies carrying master_password, password or content never reach
+the log file even if they are logged by mistake.
+*/
+type redactingHandler struct {
+	handler slog.Handler
+}
+
+func (obj redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return obj.handler.Enabled(ctx, level)
+}
+
+func (obj redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted_record := slog.NewRecord(record.Time, record.Level, Redact(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted_record.AddAttrs(redactAttr(attr))
+		return true
+	})
+
+	return obj.handler.Handle(ctx, redacted_record)
+}
+
+func (obj redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted_attrs := make([]slog.Attr, len(attrs))
+	for index, attr := range attrs {
+		redacted_attrs[index] = redactAttr(attr)
+	}
+
+	return redactingHandler{handler: obj.handler.WithAttrs(redacted_attrs)}
+}
+
+func (obj redactingHandler) WithGroup(name string) slog.Handler {
+	return redactingHandler{handler: obj.handler.WithGroup(name)}
+}
@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const REQUEST_ID_HEADER = "X-Request-ID"
+
+// Key of the request ID in gin context
+const REQUEST_ID = "request_id"
+
+// Longest request ID accepted from a client, longer ones are replaced
+const MAX_REQUEST_ID_LENGTH = 64
+
+func newRequestID() string {
+	id_bytes := make([]byte, 8)
+	rand.Read(id_bytes)
+	return hex.EncodeToString(id_bytes)
+}
+
+/*
+Log every request once it completes, replacing gin's default logger.
+
+Requests are tagged with the X-Request-ID header if the client sends one or a random ID otherwise, which is returned in the response
+header. Bodies are never logged. Server errors are logged at ERROR, client errors at WARN and everything else at INFO.
+*/
+func RequestLogger() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+
+		request_id := ctx.GetHeader(REQUEST_ID_HEADER)
+		if request_id == "" || len(request_id) > MAX_REQUEST_ID_LENGTH {
+			request_id = newRequestID()
+		}
+
+		ctx.Set(REQUEST_ID, request_id)
+		ctx.Header(REQUEST_ID_HEADER, request_id)
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		record_level := slog.LevelInfo
+		if status >= 500 {
+			record_level = slog.LevelError
+		} else if status >= 400 {
+			record_level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String(REQUEST_ID, request_id),
+			slog.String("method", ctx.Request.Method),
+			slog.String("path", ctx.Request.URL.Path),
+			slog.String("query", ctx.Request.URL.RawQuery),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", ctx.ClientIP()),
+		}
+
+		if len(ctx.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", ctx.Errors.String()))
+		}
+
+		Slog.LogAttrs(ctx, record_level, "request", attrs...)
+	}
+}
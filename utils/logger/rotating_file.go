@@ -0,0 +1,188 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Log file that is replaced by a new one once it grows past max_size bytes or has been open for max_age.
+
+Only the max_files most recent .log files in the folder are kept. A limit of 0 disables that check.
+*/
+type rotatingFile struct {
+	mutex_lock sync.Mutex
+	folder     string
+	max_size   int64
+	max_age    time.Duration
+	max_files  int
+	file       *os.File
+	size       int64
+	opened_at  time.Time
+	is_closed  bool
+}
+
+func openRotatingFile(folder string, max_size int64, max_age time.Duration, max_files int) (*rotatingFile, error) {
+	rotating_file := &rotatingFile{folder: folder, max_size: max_size, max_age: max_age, max_files: max_files}
+
+	if err := rotating_file.rotate(); err != nil {
+		return nil, err
+	}
+
+	return rotating_file, nil
+}
+
+func (obj *rotatingFile) Write(data []byte) (int, error) {
+	obj.mutex_lock.Lock()
+	defer obj.mutex_lock.Unlock()
+
+	if obj.is_closed {
+		return 0, os.ErrClosed
+	}
+
+	is_full := obj.max_size > 0 && obj.size > 0 && obj.size+int64(len(data)) > obj.max_size
+	is_old := obj.max_age > 0 && time.Since(obj.opened_at) >= obj.max_age
+
+	// Keep writing to the current file if a new one cannot be created
+	if is_full || is_old {
+		obj.rotate()
+	}
+
+	written, err := obj.file.Write(data)
+	obj.size += int64(written)
+
+	return written, err
+}
+
+// Close the current file and start a new one. Caller holds the lock unless the file is not shared yet
+func (obj *rotatingFile) rotate() error {
+	if err := os.MkdirAll(obj.folder, os.ModePerm); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	// Colons are not allowed in file names on Windows
+	base_name := "log-" + strings.ReplaceAll(now.Format("2006-01-02T15:04:05.000"), ":", "-")
+
+	var file *os.File
+	var err error
+	for attempt := 0; ; attempt++ {
+		path := filepath.Join(obj.folder, base_name+".log")
+		if attempt > 0 {
+			path = filepath.Join(obj.folder, fmt.Sprintf("%s-%d.log", base_name, attempt))
+		}
+
+		file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if !os.IsExist(err) {
+			break
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if obj.file != nil {
+		obj.file.Sync()
+		obj.file.Close()
+	}
+
+	obj.file = file
+	obj.size = 0
+	obj.opened_at = now
+
+	obj.prune()
+	return nil
+}
+
+// Delete all but the max_files most recently modified log files
+func (obj *rotatingFile) prune() {
+	if obj.max_files <= 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(obj.folder)
+
+	if err != nil {
+		return
+	}
+
+	type logFile struct {
+		name     string
+		mod_time time.Time
+	}
+
+	var log_files []logFile
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".log") {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		log_files = append(log_files, logFile{name: entry.Name(), mod_time: info.ModTime()})
+	}
+
+	current_name := filepath.Base(obj.file.Name())
+	sort.Slice(log_files, func(i, j int) bool {
+		// Current file is always kept
+		if log_files[i].name == current_name || log_files[j].name == current_name {
+			return log_files[i].name == current_name
+		}
+		if !log_files[i].mod_time.Equal(log_files[j].mod_time) {
+			return log_files[i].mod_time.After(log_files[j].mod_time)
+		}
+		return log_files[i].name > log_files[j].name
+	})
+
+	for index := obj.max_files; index < len(log_files); index++ {
+		os.Remove(filepath.Join(obj.folder, log_files[index].name))
+	}
+}
+
+// Change limits, a different folder starts a new file there
+func (obj *rotatingFile) configure(folder string, max_size int64, max_age time.Duration, max_files int) error {
+	obj.mutex_lock.Lock()
+	defer obj.mutex_lock.Unlock()
+
+	is_moved := folder != obj.folder && !obj.is_closed
+	obj.folder = folder
+	obj.max_size = max_size
+	obj.max_age = max_age
+	obj.max_files = max_files
+
+	if is_moved {
+		return obj.rotate()
+	}
+
+	obj.prune()
+	return nil
+}
+
+func (obj *rotatingFile) Path() string {
+	obj.mutex_lock.Lock()
+	defer obj.mutex_lock.Unlock()
+
+	return obj.file.Name()
+}
+
+func (obj *rotatingFile) Close() error {
+	obj.mutex_lock.Lock()
+	defer obj.mutex_lock.Unlock()
+
+	if obj.is_closed {
+		return nil
+	}
+	obj.is_closed = true
+
+	obj.file.Sync()
+	return obj.file.Close()
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	_ "embed"
+	"errors"
+	"math"
+	"ncrypt/models"
+	"ncrypt/utils/logger"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const MAX_WORD_COUNT = 32
+
+// Short, common words that are easy to read aloud or type. 1296 (6^4) words, so each word adds ~10.3 bits of entropy
+//
+//go:embed wordlist/words.txt
+var wordlist_data string
+
+var wordlist = strings.Fields(wordlist_data)
+
+/*
+Generate passphrase of random words from the embedded wordlist, joined by the configured separator.
+
+Words are optionally capitalised and a random digit can be appended to one random word.
+Returns the passphrase along with an entropy estimate in bits.
+*/
+func GeneratePassphrase(preference models.PasswordGeneratorPreference) (string, float64, error) {
+	logger.Log.Println("Generating passphrase")
+
+	if err := validatePassphrasePolicy(preference); err != nil {
+		return "", 0, err
+	}
+
+	words := make([]string, preference.WordCount)
+
+	for index := range words {
+		word_index, err := randomInt(len(wordlist))
+
+		if err != nil {
+			return "", 0, err
+		}
+
+		words[index] = wordlist[word_index]
+
+		if preference.CapitalizeWords {
+			words[index] = strings.ToUpper(words[index][:1]) + words[index][1:]
+		}
+	}
+
+	entropy_bits := float64(preference.WordCount) * math.Log2(float64(len(wordlist)))
+
+	if preference.IncludeDigit {
+		logger.Log.Println("Adding digit")
+		word_index, err := randomInt(len(words))
+
+		if err != nil {
+			return "", 0, err
+		}
+
+		digit, err := randomInt(10)
+
+		if err != nil {
+			return "", 0, err
+		}
+
+		words[word_index] += strconv.Itoa(digit)
+		entropy_bits += math.Log2(float64(10 * len(words)))
+	}
+
+	return strings.Join(words, preference.WordSeparator), entropy_bits, nil
+}
+
+func validatePassphrasePolicy(preference models.PasswordGeneratorPreference) error {
+	if preference.WordCount <= 0 || preference.WordCount > MAX_WORD_COUNT {
+		return errors.New("word count must be between 1 and 32")
+	}
+
+	// Separator should not be confused with the words or the injected digit
+	for _, character := range preference.WordSeparator {
+		if unicode.IsLetter(character) || unicode.IsDigit(character) || !unicode.IsPrint(character) {
+			return errors.New("word separator cannot contain letters or digits")
+		}
+	}
+
+	return nil
+}
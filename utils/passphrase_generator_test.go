@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"math"
+	"ncrypt/models"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestWordlist(t *testing.T) {
+	if len(wordlist) != 1296 {
+		t.Errorf("Expected: %d\nActual: %d", 1296, len(wordlist))
+	}
+
+	if !slices.IsSorted(wordlist) || len(slices.Compact(slices.Clone(wordlist))) != len(wordlist) {
+		t.Error("Wordlist should be sorted without duplicates")
+	}
+
+	for _, word := range wordlist {
+		if !regexp.MustCompile("^[a-z]{3,8}$").MatchString(word) {
+			t.Errorf("Invalid word %s", word)
+		}
+	}
+
+	t.Cleanup(password_generator_test_cleanup)
+}
+
+func TestGeneratePassphrase(t *testing.T) {
+	preference := models.PasswordGeneratorPreference{Mode: models.PASSPHRASE_MODE, WordCount: 5, WordSeparator: " "}
+
+	for range 100 {
+		passphrase, entropy_bits, err := GeneratePassphrase(preference)
+
+		if err != nil {
+			t.Error(err.Error())
+		}
+
+		words := strings.Split(passphrase, " ")
+
+		if len(words) != 5 {
+			t.Errorf("Expected: %d\nActual: %d", 5, len(words))
+		}
+
+		for _, word := range words {
+			if _, found := slices.BinarySearch(wordlist, word); !found {
+				t.Errorf("%s is not in wordlist", word)
+			}
+		}
+
+		if expected_entropy_bits := 5 * math.Log2(1296); entropy_bits != expected_entropy_bits {
+			t.Errorf("Expected: %f\nActual: %f", expected_entropy_bits, entropy_bits)
+		}
+	}
+
+	t.Cleanup(password_generator_test_cleanup)
+}
+
+func TestGeneratePassphrase_CapitalizeAndDigit(t *testing.T) {
+	preference := models.PasswordGeneratorPreference{Mode: models.PASSPHRASE_MODE, WordCount: 4, WordSeparator: "-", CapitalizeWords: true, IncludeDigit: true}
+
+	for range 100 {
+		passphrase, entropy_bits, err := GeneratePassphrase(preference)
+
+		if err != nil {
+			t.Error(err.Error())
+		}
+
+		words := strings.Split(passphrase, "-")
+
+		if len(words) != 4 {
+			t.Errorf("Expected: %d\nActual: %d", 4, len(words))
+		}
+
+		for _, word := range words {
+			if !unicode.IsUpper(rune(word[0])) {
+				t.Errorf("%s should be capitalized", word)
+			}
+
+			if _, found := slices.BinarySearch(wordlist, strings.ToLower(strings.TrimRightFunc(word, unicode.IsDigit))); !found {
+				t.Errorf("%s is not in wordlist", word)
+			}
+		}
+
+		if digit_count := len(regexp.MustCompile("[0-9]").FindAllString(passphrase, -1)); digit_count != 1 {
+			t.Errorf("Exactly one digit should be added to %s", passphrase)
+		}
+
+		if expected_entropy_bits := 4*math.Log2(1296) + math.Log2(40); entropy_bits != expected_entropy_bits {
+			t.Errorf("Expected: %f\nActual: %f", expected_entropy_bits, entropy_bits)
+		}
+	}
+
+	t.Cleanup(password_generator_test_cleanup)
+}
+
+func TestGeneratePassphrase_InvalidPolicy(t *testing.T) {
+	invalid_preferences := []models.PasswordGeneratorPreference{
+		{Mode: models.PASSPHRASE_MODE, WordCount: 0, WordSeparator: "-"},
+		{Mode: models.PASSPHRASE_MODE, WordCount: MAX_WORD_COUNT + 1, WordSeparator: "-"},
+		{Mode: models.PASSPHRASE_MODE, WordCount: 4, WordSeparator: "a"},
+		{Mode: models.PASSPHRASE_MODE, WordCount: 4, WordSeparator: "1"},
+	}
+
+	for _, preference := range invalid_preferences {
+		if _, _, err := GeneratePassphrase(preference); err == nil {
+			t.Errorf("Should fail for %+v", preference)
+		}
+
+		if err := ValidatePasswordPolicy(preference); err == nil {
+			t.Errorf("Should fail for %+v", preference)
+		}
+	}
+
+	if err := ValidatePasswordPolicy(models.PasswordGeneratorPreference{Mode: "UNKNOWN", Length: 8}); err == nil {
+		t.Error("Should fail for unknown mode")
+	}
+
+	t.Cleanup(password_generator_test_cleanup)
+}
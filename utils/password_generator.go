@@ -1,89 +1,201 @@
 package utils
 
 import (
-	"math/rand"
+	"crypto/rand"
+	"errors"
+	"math"
+	"math/big"
+	"ncrypt/models"
 	"ncrypt/utils/logger"
-	"strconv"
 	"strings"
+	"unicode"
 )
 
-func GeneratePassword(digits bool, uppercase bool, special_char bool, length int) string {
+const (
+	MAX_PASSWORD_LENGTH = 512
+
+	LOWERCASE_CHARACTERS = "abcdefghijklmnopqrstuvwxyz"
+	UPPERCASE_CHARACTERS = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	DIGIT_CHARACTERS     = "0123456789"
+	AMBIGUOUS_CHARACTERS = "il1Lo0O|"
+)
+
+type characterClass struct {
+	characters []rune
+	min_count  int
+}
+
+/*
+Generate password satisfying the given policy.
+
+Lower case characters are always allowed. Digits, upper case and special characters are only used when enabled, at least one
+(or the configured minimum) of each enabled class is included. Characters are picked using crypto/rand without modulo bias.
+Returns the password along with an entropy estimate in bits. Characters added for minimum counts only add the entropy of their
+own class, the rest that of the whole pool. Positions gained by shuffling are not counted, so the estimate errs on the low side.
+*/
+func GeneratePassword(preference models.PasswordGeneratorPreference) (string, float64, error) {
 	logger.Log.Println("Generating password")
-	generated_password := ""
 
-	special_char_list := []rune{'!', '@', '#', '$', '%', '^', '&', '*'}
+	character_classes, err := getCharacterClasses(preference)
+
+	if err != nil {
+		return "", 0, err
+	}
 
-	character_list := []rune{
-		'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h', 'j', 'k', 'm', 'n', 'p', 'q', 'r', 's', 't', 'u', 'v', 'w', 'x', 'y', 'z',
-		'1', '2', '3', '4', '5', '6', '7', '8', '9',
-	} //Ambiguous characters like i,l,o and 0 are avoided
+	var character_pool []rune
+	var generated_password []rune
+	entropy_bits := 0.0
 
 	logger.Log.Println("Setting initial characters as per constraints")
-	if digits {
-		generated_password += strconv.Itoa(rand.Intn(9) + 1) //Generates random digits between 1 - 9
+	for _, character_class := range character_classes {
+		character_pool = append(character_pool, character_class.characters...)
+		entropy_bits += float64(character_class.min_count) * math.Log2(float64(len(character_class.characters)))
+
+		for range character_class.min_count {
+			character, err := randomCharacter(character_class.characters)
+
+			if err != nil {
+				return "", 0, err
+			}
+
+			generated_password = append(generated_password, character)
+		}
 	}
 
-	if uppercase {
-		generated_password += strings.ToUpper(string(character_list[rand.Intn(23)])) // Consider only available characters
+	entropy_bits += float64(preference.Length-len(generated_password)) * math.Log2(float64(len(character_pool)))
+
+	logger.Log.Println("Randomly generating characters to match required password length")
+	for len(generated_password) < preference.Length {
+		character, err := randomCharacter(character_pool)
+
+		if err != nil {
+			return "", 0, err
+		}
+
+		generated_password = append(generated_password, character)
 	}
 
-	if special_char {
-		generated_password += string(special_char_list[rand.Intn(len(special_char_list))])
+	logger.Log.Println("Shuffling characters")
+	// Fisher-Yates shuffle so that characters added for minimum counts are not at the start
+	for i := len(generated_password) - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+
+		if err != nil {
+			return "", 0, err
+		}
+
+		generated_password[i], generated_password[j] = generated_password[j], generated_password[i]
 	}
 
-	logger.Log.Println("Randomly generating characters to match required password length")
-	for len(generated_password) <= length {
-		i := rand.Intn(len(character_list))
-
-		should_be_special := rand.Intn(2)
-
-		//Random character is a digit
-		if character_list[i] >= '1' && character_list[i] <= '9' {
-			//Would map to special character above respective digit in keyboard
-			if should_be_special == 1 && special_char && character_list[i] >= '1' && character_list[i] <= '8' {
-				generated_password += string(special_char_list[(character_list[i]-'0')-1])
-			} else if digits {
-				generated_password += string(character_list[i])
+	return string(generated_password), entropy_bits, nil
+}
+
+// Check if the policy can be satisfied in the selected mode
+func ValidatePasswordPolicy(preference models.PasswordGeneratorPreference) error {
+	switch preference.Mode {
+	case models.PASSWORD_MODE:
+		_, err := getCharacterClasses(preference)
+		return err
+	case models.PASSPHRASE_MODE:
+		return validatePassphrasePolicy(preference)
+	default:
+		return errors.New("invalid mode " + preference.Mode)
+	}
+}
+
+func getCharacterClasses(preference models.PasswordGeneratorPreference) ([]characterClass, error) {
+	if preference.Length <= 0 || preference.Length > MAX_PASSWORD_LENGTH {
+		return nil, errors.New("password length must be between 1 and 512")
+	}
+
+	if preference.MinLowerCase < 0 || preference.MinDigits < 0 || preference.MinUpperCase < 0 || preference.MinSpecialChar < 0 {
+		return nil, errors.New("minimum counts cannot be negative")
+	}
+
+	character_classes := []characterClass{{characters: filterCharacters(LOWERCASE_CHARACTERS, preference.ExcludeAmbiguous), min_count: preference.MinLowerCase}}
+
+	classes := []struct {
+		name       string
+		is_enabled bool
+		characters string
+		min_count  int
+	}{
+		{"digits", preference.HasDigits, DIGIT_CHARACTERS, preference.MinDigits},
+		{"upper case characters", preference.HasUpperCase, UPPERCASE_CHARACTERS, preference.MinUpperCase},
+		{"special characters", preference.HasSpecialChar, preference.SpecialCharSet, preference.MinSpecialChar},
+	}
+
+	for _, class := range classes {
+		if !class.is_enabled {
+			if class.min_count > 0 {
+				return nil, errors.New("minimum count set for " + class.name + " but " + class.name + " are disabled")
 			}
-		} else if character_list[i] >= 'a' && character_list[i] <= 'z' { //Random character is an alphabet
-			if should_be_special == 1 && uppercase {
-				generated_password += strings.ToUpper(string(character_list[i]))
-			} else {
-				generated_password += string(character_list[i])
+			continue
+		}
+
+		characters := filterCharacters(class.characters, preference.ExcludeAmbiguous)
+
+		if len(characters) == 0 {
+			return nil, errors.New("no " + class.name + " available")
+		}
+
+		character_classes = append(character_classes, characterClass{characters: characters, min_count: max(class.min_count, 1)})
+	}
+
+	if preference.HasSpecialChar {
+		for _, character := range preference.SpecialCharSet {
+			if unicode.IsLetter(character) || unicode.IsDigit(character) || unicode.IsSpace(character) || !unicode.IsPrint(character) {
+				return nil, errors.New("special characters cannot contain letters, digits or spaces")
 			}
 		}
 	}
 
-	logger.Log.Println("Shuffling characters")
-	return shuffule(generated_password, rand.Intn(len(generated_password)))
+	total_min_count := 0
+	for _, character_class := range character_classes {
+		total_min_count += character_class.min_count
+	}
+
+	if total_min_count > preference.Length {
+		return nil, errors.New("sum of minimum counts exceeds password length")
+	}
+
+	return character_classes, nil
 }
 
-func shuffule(generated_password string, shuffle_count int) string {
-	generated_password_rune := []rune(generated_password)
+// Remove duplicate characters and optionally characters that are easily confused with one another
+func filterCharacters(characters string, exclude_ambiguous bool) []rune {
+	var filtered_characters []rune
+	seen := make(map[rune]bool)
 
-	//Shuffle first three characters which was generated as per constraints
-	random_index := rand.Intn(len(generated_password_rune))
-	generated_password_rune[0], generated_password_rune[random_index] = generated_password_rune[random_index], generated_password_rune[0]
-	generated_password_rune[0], generated_password_rune[random_index] = generated_password_rune[random_index], generated_password_rune[0]
-	generated_password_rune[0], generated_password_rune[random_index] = generated_password_rune[random_index], generated_password_rune[0]
+	for _, character := range characters {
+		if seen[character] || (exclude_ambiguous && strings.ContainsRune(AMBIGUOUS_CHARACTERS, character)) {
+			continue
+		}
 
-	random_index = rand.Intn(len(generated_password_rune))
-	generated_password_rune[1], generated_password_rune[random_index] = generated_password_rune[random_index], generated_password_rune[1]
-	generated_password_rune[1], generated_password_rune[random_index] = generated_password_rune[random_index], generated_password_rune[1]
-	generated_password_rune[1], generated_password_rune[random_index] = generated_password_rune[random_index], generated_password_rune[1]
+		seen[character] = true
+		filtered_characters = append(filtered_characters, character)
+	}
 
-	random_index = rand.Intn(len(generated_password_rune))
-	generated_password_rune[2], generated_password_rune[random_index] = generated_password_rune[random_index], generated_password_rune[2]
-	generated_password_rune[2], generated_password_rune[random_index] = generated_password_rune[random_index], generated_password_rune[2]
-	generated_password_rune[2], generated_password_rune[random_index] = generated_password_rune[random_index], generated_password_rune[2]
+	return filtered_characters
+}
+
+func randomCharacter(characters []rune) (rune, error) {
+	index, err := randomInt(len(characters))
+
+	if err != nil {
+		return 0, err
+	}
+
+	return characters[index], nil
+}
 
-	//Random shuffle
-	for range shuffle_count {
-		random_index_1 := rand.Intn(len(generated_password_rune))
-		random_index_2 := rand.Intn(len(generated_password_rune))
+// Uniformly distributed random number in [0, n)
+func randomInt(n int) (int, error) {
+	value, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
 
-		generated_password_rune[random_index_1], generated_password_rune[random_index_2] = generated_password_rune[random_index_2], generated_password_rune[random_index_1]
+	if err != nil {
+		return 0, err
 	}
 
-	return string(generated_password_rune)
+	return int(value.Int64()), nil
 }
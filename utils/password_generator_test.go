@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"math"
+	"math/rand"
+	"ncrypt/models"
+	"os"
+	"strings"
+	"testing"
+	"testing/quick"
+	"unicode"
+)
+
+// Random policy that can always be satisfied
+func randomPasswordPolicy(random *rand.Rand) models.PasswordGeneratorPreference {
+	special_char_sets := []string{models.DEFAULT_SPECIAL_CHAR_SET, "-_", "|", "!@#$%^&*()[]{}<>?|~"}
+
+	preference := models.PasswordGeneratorPreference{
+		HasDigits:        random.Intn(2) == 1,
+		HasUpperCase:     random.Intn(2) == 1,
+		HasSpecialChar:   random.Intn(2) == 1,
+		SpecialCharSet:   special_char_sets[random.Intn(len(special_char_sets))],
+		ExcludeAmbiguous: random.Intn(2) == 1,
+		MinLowerCase:     random.Intn(4),
+	}
+
+	if preference.SpecialCharSet == "|" && preference.ExcludeAmbiguous {
+		preference.SpecialCharSet = models.DEFAULT_SPECIAL_CHAR_SET
+	}
+
+	total_min_count := preference.MinLowerCase
+	if preference.HasDigits {
+		preference.MinDigits = random.Intn(4)
+		total_min_count += max(preference.MinDigits, 1)
+	}
+	if preference.HasUpperCase {
+		preference.MinUpperCase = random.Intn(4)
+		total_min_count += max(preference.MinUpperCase, 1)
+	}
+	if preference.HasSpecialChar {
+		preference.MinSpecialChar = random.Intn(4)
+		total_min_count += max(preference.MinSpecialChar, 1)
+	}
+
+	preference.Length = max(total_min_count, 1) + random.Intn(64)
+
+	return preference
+}
+
+func checkPasswordPolicy(t *testing.T, preference models.PasswordGeneratorPreference, password string) bool {
+	counts := make(map[string]int)
+
+	for _, character := range password {
+		switch {
+		case unicode.IsLower(character):
+			counts["lowercase"]++
+		case unicode.IsUpper(character):
+			counts["uppercase"]++
+		case unicode.IsDigit(character):
+			counts["digits"]++
+		case strings.ContainsRune(preference.SpecialCharSet, character):
+			counts["special"]++
+		default:
+			t.Logf("Unexpected character %q in %s for %+v", character, password, preference)
+			return false
+		}
+
+		if preference.ExcludeAmbiguous && strings.ContainsRune(AMBIGUOUS_CHARACTERS, character) {
+			t.Logf("Ambiguous character %q in %s for %+v", character, password, preference)
+			return false
+		}
+	}
+
+	is_valid := len([]rune(password)) == preference.Length &&
+		counts["lowercase"] >= preference.MinLowerCase &&
+		(preference.HasDigits && counts["digits"] >= max(preference.MinDigits, 1) || !preference.HasDigits && counts["digits"] == 0) &&
+		(preference.HasUpperCase && counts["uppercase"] >= max(preference.MinUpperCase, 1) || !preference.HasUpperCase && counts["uppercase"] == 0) &&
+		(preference.HasSpecialChar && counts["special"] >= max(preference.MinSpecialChar, 1) || !preference.HasSpecialChar && counts["special"] == 0)
+
+	if !is_valid {
+		t.Logf("%s does not satisfy %+v", password, preference)
+	}
+
+	return is_valid
+}
+
+func TestGeneratePassword_SatisfiesPolicy(t *testing.T) {
+	property := func(seed int64) bool {
+		preference := randomPasswordPolicy(rand.New(rand.NewSource(seed)))
+
+		password, entropy_bits, err := GeneratePassword(preference)
+
+		if err != nil {
+			t.Logf("%s for %+v", err.Error(), preference)
+			return false
+		}
+
+		// Zero when every character is forced from a single character class, e.g. "|||"
+		if entropy_bits < 0 {
+			t.Logf("Invalid entropy %f for %+v", entropy_bits, preference)
+			return false
+		}
+
+		return checkPasswordPolicy(t, preference, password)
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+
+	t.Cleanup(password_generator_test_cleanup)
+}
+
+func TestGeneratePassword_Entropy(t *testing.T) {
+	preference := models.PasswordGeneratorPreference{HasDigits: true, HasUpperCase: true, Length: 10}
+
+	_, entropy_bits, err := GeneratePassword(preference)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	// One digit and one upper case character are always included, the other 8 are from 26 lower case + 26 upper case + 10 digits
+	if expected_entropy_bits := math.Log2(10) + math.Log2(26) + 8*math.Log2(62); math.Abs(entropy_bits-expected_entropy_bits) > 1e-9 {
+		t.Errorf("Expected: %f\nActual: %f", expected_entropy_bits, entropy_bits)
+	}
+
+	t.Cleanup(password_generator_test_cleanup)
+}
+
+// Characters forced by minimum counts come from smaller pools, so they add less entropy than random characters
+func TestGeneratePassword_EntropyWithMinimums(t *testing.T) {
+	preference := models.PasswordGeneratorPreference{HasDigits: true, HasUpperCase: true, Length: 16, MinDigits: 12, MinUpperCase: 2}
+
+	_, entropy_bits, err := GeneratePassword(preference)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if expected_entropy_bits := 12*math.Log2(10) + 2*math.Log2(26) + 2*math.Log2(62); math.Abs(entropy_bits-expected_entropy_bits) > 1e-9 {
+		t.Errorf("Expected: %f\nActual: %f", expected_entropy_bits, entropy_bits)
+	}
+
+	if entropy_bits >= 16*math.Log2(62) {
+		t.Errorf("Entropy %f should be below %f", entropy_bits, 16*math.Log2(62))
+	}
+
+	t.Cleanup(password_generator_test_cleanup)
+}
+
+func TestGeneratePassword_InvalidPolicy(t *testing.T) {
+	invalid_preferences := []models.PasswordGeneratorPreference{
+		{Length: 0},
+		{Length: MAX_PASSWORD_LENGTH + 1},
+		{Length: 8, MinDigits: 1},
+		{Length: 8, MinLowerCase: -1},
+		{Length: 4, HasDigits: true, MinDigits: 3, MinLowerCase: 2},
+		{Length: 8, HasSpecialChar: true, SpecialCharSet: ""},
+		{Length: 8, HasSpecialChar: true, SpecialCharSet: "|", ExcludeAmbiguous: true},
+		{Length: 8, HasSpecialChar: true, SpecialCharSet: "a!"},
+	}
+
+	for _, preference := range invalid_preferences {
+		if _, _, err := GeneratePassword(preference); err == nil {
+			t.Errorf("Should fail for %+v", preference)
+		}
+	}
+
+	t.Cleanup(password_generator_test_cleanup)
+}
+
+// Every character of the pool should be picked roughly equally often
+func TestGeneratePassword_Distribution(t *testing.T) {
+	preference := models.PasswordGeneratorPreference{HasDigits: true, Length: MAX_PASSWORD_LENGTH}
+	counts := make(map[rune]int)
+
+	for range 100 {
+		password, _, err := GeneratePassword(preference)
+
+		if err != nil {
+			t.Error(err.Error())
+		}
+
+		for _, character := range password {
+			counts[character]++
+		}
+	}
+
+	expected_count := float64(100*MAX_PASSWORD_LENGTH) / 36
+	for character, count := range counts {
+		if float64(count) < expected_count*0.8 || float64(count) > expected_count*1.2 {
+			t.Errorf("Character %q picked %d times, expected around %.0f", character, count, expected_count)
+		}
+	}
+
+	if len(counts) != 36 {
+		t.Errorf("Expected: %d\nActual: %d", 36, len(counts))
+	}
+
+	t.Cleanup(password_generator_test_cleanup)
+}
+
+func password_generator_test_cleanup() {
+	os.RemoveAll("logs")
+}
@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const (
+	VERY_WEAK_PASSWORD   = 0
+	WEAK_PASSWORD        = 1
+	FAIR_PASSWORD        = 2
+	STRONG_PASSWORD      = 3
+	VERY_STRONG_PASSWORD = 4
+
+	MIN_PATTERN_LENGTH = 3
+	MIN_WORD_LENGTH    = 4
+)
+
+// Frequently used passwords, matched case-insensitively anywhere in the password
+var common_passwords = []string{
+	"123456", "password", "12345678", "qwerty", "123456789", "12345", "1234", "111111", "1234567", "dragon",
+	"123123", "baseball", "abc123", "football", "monkey", "letmein", "696969", "shadow", "master", "666666",
+	"qwertyuiop", "123321", "mustang", "1234567890", "michael", "654321", "superman", "1qaz2wsx", "7777777", "121212",
+	"000000", "qazwsx", "123qwe", "killer", "trustno1", "jordan", "jennifer", "zxcvbnm", "asdfgh", "hunter",
+	"buster", "soccer", "harley", "batman", "andrew", "tigger", "sunshine", "iloveyou", "charlie", "robert",
+	"thomas", "hockey", "ranger", "daniel", "starwars", "112233", "george", "computer", "michelle", "jessica",
+	"pepper", "zxcvbn", "555555", "11111111", "131313", "freedom", "777777", "pass", "maggie", "159753",
+	"aaaaaa", "ginger", "princess", "joshua", "cheese", "amanda", "summer", "love", "ashley", "nicole",
+	"chelsea", "matthew", "access", "yankees", "987654321", "dallas", "austin", "thunder", "taylor", "matrix",
+	"welcome", "admin", "login", "passw0rd", "p@ssword", "p@ssw0rd", "password1", "qwerty123", "secret", "hello",
+	"whatever", "changeme", "default", "guest", "root", "test", "flower", "lovely", "qazxsw", "asdf",
+}
+
+// Keyboard rows and sequences that are typed by sliding across keys
+var sequences = []string{
+	"abcdefghijklmnopqrstuvwxyz",
+	"01234567890",
+	"qwertyuiop",
+	"asdfghjkl",
+	"zxcvbnm",
+}
+
+/*
+Estimate password strength in the spirit of zxcvbn.
+
+Password is split into patterns - common passwords and dictionary words, repeated characters and sequences (abc, 123, qwerty).
+Each pattern adds the bits needed to guess it rather than the bits of its individual characters, remaining characters add
+bits based on the character classes used. Returns entropy estimate in bits and a score between 0 (very weak) and 4 (very strong).
+*/
+func EstimatePasswordStrength(password string) (float64, int) {
+	characters := []rune(password)
+	lower_characters := []rune(strings.ToLower(password))
+	character_bits := math.Log2(float64(max(characterSetSize(password), 1)))
+	dictionary_bits := math.Log2(float64(len(common_passwords) + len(wordlist)))
+
+	entropy_bits := 0.0
+
+	for index := 0; index < len(characters); {
+		if length := dictionaryMatch(lower_characters[index:]); length > 0 {
+			entropy_bits += dictionary_bits
+			// Capitalising a word adds little
+			if string(characters[index:index+length]) != string(lower_characters[index:index+length]) {
+				entropy_bits += 1
+			}
+			index += length
+		} else if length := repeatMatch(characters[index:]); length > 0 {
+			entropy_bits += character_bits + math.Log2(float64(length))
+			index += length
+		} else if length := sequenceMatch(lower_characters[index:]); length > 0 {
+			entropy_bits += math.Log2(float64(len(sequences))) + math.Log2(float64(length)) + 1
+			index += length
+		} else {
+			entropy_bits += character_bits
+			index++
+		}
+	}
+
+	return entropy_bits, passwordScore(entropy_bits)
+}
+
+func passwordScore(entropy_bits float64) int {
+	switch {
+	case entropy_bits < 28:
+		return VERY_WEAK_PASSWORD
+	case entropy_bits < 36:
+		return WEAK_PASSWORD
+	case entropy_bits < 60:
+		return FAIR_PASSWORD
+	case entropy_bits < 80:
+		return STRONG_PASSWORD
+	default:
+		return VERY_STRONG_PASSWORD
+	}
+}
+
+func characterSetSize(password string) int {
+	has_lower, has_upper, has_digit, has_other := false, false, false, false
+
+	for _, character := range password {
+		switch {
+		case unicode.IsLower(character):
+			has_lower = true
+		case unicode.IsUpper(character):
+			has_upper = true
+		case unicode.IsDigit(character):
+			has_digit = true
+		default:
+			has_other = true
+		}
+	}
+
+	size := 0
+	if has_lower {
+		size += 26
+	}
+	if has_upper {
+		size += 26
+	}
+	if has_digit {
+		size += 10
+	}
+	if has_other {
+		size += 33
+	}
+
+	return size
+}
+
+// Length of the longest common password or wordlist word at the start of characters, 0 if none
+func dictionaryMatch(characters []rune) int {
+	longest := 0
+
+	for _, value := range []string{string(characters), unleet(string(characters))} {
+		for _, word := range common_passwords {
+			if len(word) > longest && strings.HasPrefix(value, word) {
+				longest = len(word)
+			}
+		}
+
+		for _, word := range wordlist {
+			if len(word) >= MIN_WORD_LENGTH && len(word) > longest && strings.HasPrefix(value, word) {
+				longest = len(word)
+			}
+		}
+	}
+
+	return longest
+}
+
+// Undo common character substitutions like p@ssw0rd. Each substitution maps a single rune to a single rune so lengths match
+func unleet(value string) string {
+	return strings.NewReplacer("@", "a", "4", "a", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t").Replace(value)
+}
+
+// Length of run of the same character at the start of characters, 0 if shorter than MIN_PATTERN_LENGTH
+func repeatMatch(characters []rune) int {
+	length := 1
+	for length < len(characters) && characters[length] == characters[0] {
+		length++
+	}
+
+	if length < MIN_PATTERN_LENGTH {
+		return 0
+	}
+	return length
+}
+
+// Length of ascending or descending sequence at the start of characters, 0 if shorter than MIN_PATTERN_LENGTH
+func sequenceMatch(characters []rune) int {
+	longest := 0
+
+	for _, sequence := range sequences {
+		for _, direction := range []string{sequence, reverse(sequence)} {
+			start := strings.IndexRune(direction, characters[0])
+			if start == -1 {
+				continue
+			}
+
+			length := 1
+			for length < len(characters) && start+length < len(direction) && rune(direction[start+length]) == characters[length] {
+				length++
+			}
+
+			longest = max(longest, length)
+		}
+	}
+
+	if longest < MIN_PATTERN_LENGTH {
+		return 0
+	}
+	return longest
+}
+
+func reverse(value string) string {
+	characters := []rune(value)
+	slices.Reverse(characters)
+	return string(characters)
+}
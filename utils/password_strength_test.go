@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestEstimatePasswordStrength(t *testing.T) {
+	test_cases := []struct {
+		password       string
+		expected_score int
+	}{
+		{"", VERY_WEAK_PASSWORD},
+		{"password", VERY_WEAK_PASSWORD},
+		{"P@ssw0rd", VERY_WEAK_PASSWORD},
+		{"123456789", VERY_WEAK_PASSWORD},
+		{"qwertyuiop", VERY_WEAK_PASSWORD},
+		{"aaaaaaaaaaaa", VERY_WEAK_PASSWORD},
+		{"abcdefgh1234", VERY_WEAK_PASSWORD},
+		{"x7Kp2mQz", FAIR_PASSWORD},
+		{"t8#Kd9$vQ2!mZr4&", VERY_STRONG_PASSWORD},
+	}
+
+	for _, test_case := range test_cases {
+		entropy_bits, score := EstimatePasswordStrength(test_case.password)
+
+		if score != test_case.expected_score {
+			t.Errorf("%s\nExpected: %d\nActual: %d (%f bits)", test_case.password, test_case.expected_score, score, entropy_bits)
+		}
+	}
+
+	t.Cleanup(password_generator_test_cleanup)
+}
+
+// Patterns should be weaker than random characters of the same length
+func TestEstimatePasswordStrength_Patterns(t *testing.T) {
+	random_entropy_bits, _ := EstimatePasswordStrength("kq8zr3wx")
+
+	for _, password := range []string{"abcdefgh", "zzzzzzzz", "87654321", "asdfghjk", "sunshine"} {
+		if entropy_bits, _ := EstimatePasswordStrength(password); entropy_bits >= random_entropy_bits {
+			t.Errorf("%s should be weaker than random password, %f >= %f", password, entropy_bits, random_entropy_bits)
+		}
+	}
+
+	t.Cleanup(password_generator_test_cleanup)
+}
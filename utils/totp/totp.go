@@ -0,0 +1,170 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_DIGITS    = 6
+	DEFAULT_PERIOD    = 30
+	DEFAULT_ALGORITHM = "SHA1"
+
+	URI_SCHEME = "otpauth://"
+)
+
+// TOTP key as described in RFC 6238
+type Key struct {
+	Secret    string // Base32 encoded shared secret
+	Digits    int
+	Period    int
+	Algorithm string
+}
+
+// Check if given value is an otpauth:// URI rather than a plain base32 secret
+func IsURI(value string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(value)), URI_SCHEME)
+}
+
+/*
+Parse key from an otpauth:// URI as exported by authenticator apps.
+
+	otpauth://totp/Issuer:username?secret=BASE32SECRET&issuer=Issuer&algorithm=SHA1&digits=6&period=30
+*/
+func ParseURI(uri string) (Key, error) {
+	parsed_uri, err := url.Parse(strings.TrimSpace(uri))
+
+	if err != nil {
+		return Key{}, err
+	}
+
+	if !strings.EqualFold(parsed_uri.Scheme, "otpauth") {
+		return Key{}, errors.New("invalid otpauth uri")
+	}
+
+	if !strings.EqualFold(parsed_uri.Host, "totp") {
+		return Key{}, errors.New("only totp uris are supported")
+	}
+
+	query := parsed_uri.Query()
+
+	key := Key{Secret: query.Get("secret"), Algorithm: query.Get("algorithm")}
+
+	if digits := query.Get("digits"); digits != "" {
+		if key.Digits, err = strconv.Atoi(digits); err != nil {
+			return Key{}, errors.New("invalid digits")
+		}
+	}
+
+	if period := query.Get("period"); period != "" {
+		if key.Period, err = strconv.Atoi(period); err != nil {
+			return Key{}, errors.New("invalid period")
+		}
+	}
+
+	err = key.Normalize()
+
+	return key, err
+}
+
+// Fill in default parameters and validate the key. Secret is converted to upper case without spaces or padding
+func (obj *Key) Normalize() error {
+	obj.Secret = strings.ToUpper(strings.TrimRight(strings.ReplaceAll(obj.Secret, " ", ""), "="))
+
+	if obj.Secret == "" {
+		return errors.New("totp secret is empty")
+	}
+
+	if _, err := obj.decodeSecret(); err != nil {
+		return errors.New("totp secret is not base32 encoded")
+	}
+
+	if obj.Digits == 0 {
+		obj.Digits = DEFAULT_DIGITS
+	}
+	if obj.Digits < 6 || obj.Digits > 8 {
+		return errors.New("totp digits must be between 6 and 8")
+	}
+
+	if obj.Period == 0 {
+		obj.Period = DEFAULT_PERIOD
+	}
+	if obj.Period < 0 {
+		return errors.New("totp period must be positive")
+	}
+
+	if obj.Algorithm == "" {
+		obj.Algorithm = DEFAULT_ALGORITHM
+	}
+	obj.Algorithm = strings.ToUpper(obj.Algorithm)
+	if _, err := obj.hashFunction(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Generate code for the given time. Returns code along with seconds remaining until the code expires
+func (obj *Key) GenerateCode(at time.Time) (string, int, error) {
+	if err := obj.Normalize(); err != nil {
+		return "", 0, err
+	}
+
+	secret, err := obj.decodeSecret()
+
+	if err != nil {
+		return "", 0, err
+	}
+
+	hash_function, err := obj.hashFunction()
+
+	if err != nil {
+		return "", 0, err
+	}
+
+	counter := uint64(at.Unix()) / uint64(obj.Period)
+	remaining := obj.Period - int(uint64(at.Unix())%uint64(obj.Period))
+
+	mac := hmac.New(hash_function, secret)
+	mac.Write(binary.BigEndian.AppendUint64(nil, counter))
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation (RFC 4226 section 5.3)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for range obj.Digits {
+		modulo *= 10
+	}
+
+	return fmt.Sprintf("%0*d", obj.Digits, value%modulo), remaining, nil
+}
+
+func (obj *Key) decodeSecret() ([]byte, error) {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(obj.Secret)
+}
+
+func (obj *Key) hashFunction() (func() hash.Hash, error) {
+	switch obj.Algorithm {
+	case "SHA1":
+		return sha1.New, nil
+	case "SHA256":
+		return sha256.New, nil
+	case "SHA512":
+		return sha512.New, nil
+	default:
+		return nil, errors.New("unsupported totp algorithm " + obj.Algorithm)
+	}
+}
@@ -0,0 +1,92 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// Test vectors from RFC 6238 appendix B
+func TestGenerateCode(t *testing.T) {
+	secrets := map[string]string{
+		"SHA1":   "12345678901234567890",
+		"SHA256": "12345678901234567890123456789012",
+		"SHA512": "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+
+	test_cases := []struct {
+		time      int64
+		algorithm string
+		code      string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1111111109, "SHA256", "68084774"},
+		{1111111109, "SHA512", "25091201"},
+		{20000000000, "SHA1", "65353130"},
+		{20000000000, "SHA256", "77737706"},
+		{20000000000, "SHA512", "47863826"},
+	}
+
+	for _, test_case := range test_cases {
+		key := Key{Secret: base32.StdEncoding.EncodeToString([]byte(secrets[test_case.algorithm])), Digits: 8, Algorithm: test_case.algorithm}
+
+		code, remaining, err := key.GenerateCode(time.Unix(test_case.time, 0))
+
+		if err != nil {
+			t.Error(err.Error())
+		}
+
+		if code != test_case.code {
+			t.Errorf("Expected: %s\nActual: %s", test_case.code, code)
+		}
+
+		expected_remaining := 30 - int(test_case.time%30)
+		if remaining != expected_remaining {
+			t.Errorf("Expected: %d\nActual: %d", expected_remaining, remaining)
+		}
+	}
+}
+
+func TestParseURI(t *testing.T) {
+	key, err := ParseURI("otpauth://totp/GitHub:abc?secret=jbsw%20y3dp%20ehpk%203pxp&issuer=GitHub&algorithm=sha256&digits=8&period=60")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	expected_key := Key{Secret: "JBSWY3DPEHPK3PXP", Digits: 8, Period: 60, Algorithm: "SHA256"}
+	if key != expected_key {
+		t.Errorf("Expected: %v\nActual: %v", expected_key, key)
+	}
+
+	key, err = ParseURI("otpauth://totp/abc?secret=JBSWY3DPEHPK3PXP")
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	expected_key = Key{Secret: "JBSWY3DPEHPK3PXP", Digits: DEFAULT_DIGITS, Period: DEFAULT_PERIOD, Algorithm: DEFAULT_ALGORITHM}
+	if key != expected_key {
+		t.Errorf("Expected: %v\nActual: %v", expected_key, key)
+	}
+}
+
+func TestParseURI_Invalid(t *testing.T) {
+	invalid_uris := []string{
+		"https://github.com",
+		"otpauth://hotp/abc?secret=JBSWY3DPEHPK3PXP&counter=1",
+		"otpauth://totp/abc",
+		"otpauth://totp/abc?secret=not-base32!",
+		"otpauth://totp/abc?secret=JBSWY3DPEHPK3PXP&algorithm=MD5",
+		"otpauth://totp/abc?secret=JBSWY3DPEHPK3PXP&digits=4",
+	}
+
+	for _, uri := range invalid_uris {
+		if _, err := ParseURI(uri); err == nil {
+			t.Errorf("Should fail for %s", uri)
+		}
+	}
+}